@@ -3,8 +3,10 @@ package apifakes
 
 import (
 	"sync"
+	"time"
 
 	"github.com/cloudfoundry-incubator/switchboard/api"
+	"github.com/cloudfoundry-incubator/switchboard/domain"
 )
 
 type FakeClusterManager struct {
@@ -28,6 +30,36 @@ type FakeClusterManager struct {
 	enableTrafficArgsForCall []struct {
 		arg1 string
 	}
+	FailbackStub        func()
+	failbackMutex       sync.RWMutex
+	failbackArgsForCall []struct {
+	}
+	PinActiveBackendStub        func(*domain.Backend, string, string, time.Duration)
+	pinActiveBackendMutex       sync.RWMutex
+	pinActiveBackendArgsForCall []struct {
+		arg1 *domain.Backend
+		arg2 string
+		arg3 string
+		arg4 time.Duration
+	}
+	SetBackendAdminStateStub        func(*domain.Backend, string, string, string) error
+	setBackendAdminStateMutex       sync.RWMutex
+	setBackendAdminStateArgsForCall []struct {
+		arg1 *domain.Backend
+		arg2 string
+		arg3 string
+		arg4 string
+	}
+	setBackendAdminStateReturns struct {
+		result1 error
+	}
+	setBackendAdminStateReturnsOnCall map[int]struct {
+		result1 error
+	}
+	UnpinActiveBackendStub        func()
+	unpinActiveBackendMutex       sync.RWMutex
+	unpinActiveBackendArgsForCall []struct {
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -146,6 +178,149 @@ func (fake *FakeClusterManager) EnableTrafficArgsForCall(i int) string {
 	return argsForCall.arg1
 }
 
+func (fake *FakeClusterManager) Failback() {
+	fake.failbackMutex.Lock()
+	fake.failbackArgsForCall = append(fake.failbackArgsForCall, struct {
+	}{})
+	fake.recordInvocation("Failback", []interface{}{})
+	fake.failbackMutex.Unlock()
+	if fake.FailbackStub != nil {
+		fake.FailbackStub()
+	}
+}
+
+func (fake *FakeClusterManager) FailbackCallCount() int {
+	fake.failbackMutex.RLock()
+	defer fake.failbackMutex.RUnlock()
+	return len(fake.failbackArgsForCall)
+}
+
+func (fake *FakeClusterManager) FailbackCalls(stub func()) {
+	fake.failbackMutex.Lock()
+	defer fake.failbackMutex.Unlock()
+	fake.FailbackStub = stub
+}
+
+func (fake *FakeClusterManager) PinActiveBackend(arg1 *domain.Backend, arg2 string, arg3 string, arg4 time.Duration) {
+	fake.pinActiveBackendMutex.Lock()
+	fake.pinActiveBackendArgsForCall = append(fake.pinActiveBackendArgsForCall, struct {
+		arg1 *domain.Backend
+		arg2 string
+		arg3 string
+		arg4 time.Duration
+	}{arg1, arg2, arg3, arg4})
+	fake.recordInvocation("PinActiveBackend", []interface{}{arg1, arg2, arg3, arg4})
+	fake.pinActiveBackendMutex.Unlock()
+	if fake.PinActiveBackendStub != nil {
+		fake.PinActiveBackendStub(arg1, arg2, arg3, arg4)
+	}
+}
+
+func (fake *FakeClusterManager) PinActiveBackendCallCount() int {
+	fake.pinActiveBackendMutex.RLock()
+	defer fake.pinActiveBackendMutex.RUnlock()
+	return len(fake.pinActiveBackendArgsForCall)
+}
+
+func (fake *FakeClusterManager) PinActiveBackendCalls(stub func(*domain.Backend, string, string, time.Duration)) {
+	fake.pinActiveBackendMutex.Lock()
+	defer fake.pinActiveBackendMutex.Unlock()
+	fake.PinActiveBackendStub = stub
+}
+
+func (fake *FakeClusterManager) PinActiveBackendArgsForCall(i int) (*domain.Backend, string, string, time.Duration) {
+	fake.pinActiveBackendMutex.RLock()
+	defer fake.pinActiveBackendMutex.RUnlock()
+	argsForCall := fake.pinActiveBackendArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeClusterManager) SetBackendAdminState(arg1 *domain.Backend, arg2 string, arg3 string, arg4 string) error {
+	fake.setBackendAdminStateMutex.Lock()
+	ret, specificReturn := fake.setBackendAdminStateReturnsOnCall[len(fake.setBackendAdminStateArgsForCall)]
+	fake.setBackendAdminStateArgsForCall = append(fake.setBackendAdminStateArgsForCall, struct {
+		arg1 *domain.Backend
+		arg2 string
+		arg3 string
+		arg4 string
+	}{arg1, arg2, arg3, arg4})
+	fake.recordInvocation("SetBackendAdminState", []interface{}{arg1, arg2, arg3, arg4})
+	fake.setBackendAdminStateMutex.Unlock()
+	if fake.SetBackendAdminStateStub != nil {
+		return fake.SetBackendAdminStateStub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.setBackendAdminStateReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeClusterManager) SetBackendAdminStateCallCount() int {
+	fake.setBackendAdminStateMutex.RLock()
+	defer fake.setBackendAdminStateMutex.RUnlock()
+	return len(fake.setBackendAdminStateArgsForCall)
+}
+
+func (fake *FakeClusterManager) SetBackendAdminStateCalls(stub func(*domain.Backend, string, string, string) error) {
+	fake.setBackendAdminStateMutex.Lock()
+	defer fake.setBackendAdminStateMutex.Unlock()
+	fake.SetBackendAdminStateStub = stub
+}
+
+func (fake *FakeClusterManager) SetBackendAdminStateArgsForCall(i int) (*domain.Backend, string, string, string) {
+	fake.setBackendAdminStateMutex.RLock()
+	defer fake.setBackendAdminStateMutex.RUnlock()
+	argsForCall := fake.setBackendAdminStateArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeClusterManager) SetBackendAdminStateReturns(result1 error) {
+	fake.setBackendAdminStateMutex.Lock()
+	defer fake.setBackendAdminStateMutex.Unlock()
+	fake.SetBackendAdminStateStub = nil
+	fake.setBackendAdminStateReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClusterManager) SetBackendAdminStateReturnsOnCall(i int, result1 error) {
+	fake.setBackendAdminStateMutex.Lock()
+	defer fake.setBackendAdminStateMutex.Unlock()
+	fake.SetBackendAdminStateStub = nil
+	if fake.setBackendAdminStateReturnsOnCall == nil {
+		fake.setBackendAdminStateReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.setBackendAdminStateReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClusterManager) UnpinActiveBackend() {
+	fake.unpinActiveBackendMutex.Lock()
+	fake.unpinActiveBackendArgsForCall = append(fake.unpinActiveBackendArgsForCall, struct {
+	}{})
+	fake.recordInvocation("UnpinActiveBackend", []interface{}{})
+	fake.unpinActiveBackendMutex.Unlock()
+	if fake.UnpinActiveBackendStub != nil {
+		fake.UnpinActiveBackendStub()
+	}
+}
+
+func (fake *FakeClusterManager) UnpinActiveBackendCallCount() int {
+	fake.unpinActiveBackendMutex.RLock()
+	defer fake.unpinActiveBackendMutex.RUnlock()
+	return len(fake.unpinActiveBackendArgsForCall)
+}
+
+func (fake *FakeClusterManager) UnpinActiveBackendCalls(stub func()) {
+	fake.unpinActiveBackendMutex.Lock()
+	defer fake.unpinActiveBackendMutex.Unlock()
+	fake.UnpinActiveBackendStub = stub
+}
+
 func (fake *FakeClusterManager) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -155,6 +330,14 @@ func (fake *FakeClusterManager) Invocations() map[string][][]interface{} {
 	defer fake.disableTrafficMutex.RUnlock()
 	fake.enableTrafficMutex.RLock()
 	defer fake.enableTrafficMutex.RUnlock()
+	fake.failbackMutex.RLock()
+	defer fake.failbackMutex.RUnlock()
+	fake.pinActiveBackendMutex.RLock()
+	defer fake.pinActiveBackendMutex.RUnlock()
+	fake.setBackendAdminStateMutex.RLock()
+	defer fake.setBackendAdminStateMutex.RUnlock()
+	fake.unpinActiveBackendMutex.RLock()
+	defer fake.unpinActiveBackendMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
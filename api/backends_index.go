@@ -3,7 +3,10 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
+	"time"
 
+	"code.cloudfoundry.org/lager"
 	"github.com/cloudfoundry-incubator/switchboard/domain"
 )
 
@@ -25,16 +28,82 @@ var BackendsIndex = func(backends []*domain.Backend, clusterManager ClusterManag
 	})
 }
 
+// BackendEndpoint sets a backend's admin state on PATCH, taking it out of
+// rotation for drain or maintenance, or returning it with active.
+var BackendEndpoint = func(backends []*domain.Backend, clusterManager ClusterManager, logger lager.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "PATCH" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		name := strings.TrimPrefix(req.URL.Path, "/v0/backends/")
+		var backend *domain.Backend
+		for _, b := range backends {
+			if b.AsJSON().Name == name {
+				backend = b
+			}
+		}
+		if backend == nil {
+			http.Error(w, "Unknown backend", http.StatusNotFound)
+			return
+		}
+
+		err := req.ParseForm()
+		if err != nil {
+			http.Error(w, "Failed to parse form", http.StatusBadRequest)
+			return
+		}
+
+		logger.Debug("API /backends req form", lager.Data{"backend": name, "form": req.Form})
+
+		state := req.Form.Get("adminState")
+		message := req.Form.Get("message")
+		if state != domain.AdminStateActive && message == "" {
+			http.Error(w, "message must not be empty", http.StatusBadRequest)
+			return
+		}
+
+		changedBy, _, _ := req.BasicAuth()
+		err = clusterManager.SetBackendAdminState(backend, state, message, changedBy)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		backendJSON, err := json.Marshal(Backends{backend}.AsV0JSON(clusterManager)[0])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, err = w.Write(backendJSON)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
 type Backends []*domain.Backend
 
 type V0BackendResponse struct {
-	Host                string `json:"host"`
-	Port                uint   `json:"port"`
-	Healthy             bool   `json:"healthy"`
-	Name                string `json:"name"`
-	CurrentSessionCount uint   `json:"currentSessionCount"`
-	Active              bool   `json:"active"`         // For Backwards Compatibility
-	TrafficEnabled      bool   `json:"trafficEnabled"` // For Backwards Compatibility
+	Host                string             `json:"host"`
+	Port                uint               `json:"port"`
+	Healthy             bool               `json:"healthy"`
+	Name                string             `json:"name"`
+	CurrentSessionCount uint               `json:"currentSessionCount"`
+	Active              bool               `json:"active"`         // For Backwards Compatibility
+	TrafficEnabled      bool               `json:"trafficEnabled"` // For Backwards Compatibility
+	ClosedSessions      map[string]uint64  `json:"closedSessions"`
+	Drains              []domain.DrainJSON `json:"drains"`
+	Flapping            bool               `json:"flapping"`
+	FlapPenaltyDeadline *time.Time         `json:"flapPenaltyDeadline,omitempty"`
+	AdminState          string             `json:"adminState"`
+	AdminMessage        string             `json:"adminMessage,omitempty"`
+	AdminChangedBy      string             `json:"adminChangedBy,omitempty"`
+	AdminChangedAt      *time.Time         `json:"adminChangedAt,omitempty"`
 }
 
 func (bs Backends) AsV0JSON(cluster ClusterManager) (json []V0BackendResponse) {
@@ -52,6 +121,14 @@ func (bs Backends) AsV0JSON(cluster ClusterManager) (json []V0BackendResponse) {
 			CurrentSessionCount: j.CurrentSessionCount,
 			Active:              activeBackend != nil && j.Name == activeBackend.Name,
 			TrafficEnabled:      cj.TrafficEnabled,
+			ClosedSessions:      j.ClosedSessions,
+			Drains:              j.Drains,
+			Flapping:            j.Flapping,
+			FlapPenaltyDeadline: j.FlapPenaltyDeadline,
+			AdminState:          j.AdminState,
+			AdminMessage:        j.AdminMessage,
+			AdminChangedBy:      j.AdminChangedBy,
+			AdminChangedAt:      j.AdminChangedAt,
 		})
 	}
 
@@ -1,6 +1,13 @@
 package api_test
 
 import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagertest"
 	"github.com/cloudfoundry-incubator/switchboard/api"
@@ -8,6 +15,7 @@ import (
 	"github.com/cloudfoundry-incubator/switchboard/domain"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
 )
 
 var _ = Describe("BackendsIndex", func() {
@@ -92,5 +100,105 @@ var _ = Describe("BackendsIndex", func() {
 			Expect(v0backendResponses[0].Active).To(BeFalse())
 			Expect(v0backendResponses[1].Active).To(BeTrue())
 		})
+
+		It("returns whether each backend is held out for flapping", func() {
+			deadline := time.Now().Add(time.Minute)
+			backend1.SetFlapPenalty(deadline)
+
+			v0backendResponses := backends.AsV0JSON(fakeClusterManager)
+			Expect(v0backendResponses).To(HaveLen(2))
+
+			Expect(v0backendResponses[0].Flapping).To(BeFalse())
+			Expect(v0backendResponses[0].FlapPenaltyDeadline).To(BeNil())
+
+			Expect(v0backendResponses[1].Flapping).To(BeTrue())
+			Expect(*v0backendResponses[1].FlapPenaltyDeadline).To(Equal(deadline))
+		})
+
+		It("returns each backend's admin state", func() {
+			Expect(backend1.SetAdminState(domain.AdminStateMaintenance, "upgrading", "operator")).To(Succeed())
+
+			v0backendResponses := backends.AsV0JSON(fakeClusterManager)
+			Expect(v0backendResponses).To(HaveLen(2))
+
+			Expect(v0backendResponses[0].AdminState).To(Equal("active"))
+			Expect(v0backendResponses[0].AdminChangedAt).To(BeNil())
+
+			Expect(v0backendResponses[1].AdminState).To(Equal("maintenance"))
+			Expect(v0backendResponses[1].AdminMessage).To(Equal("upgrading"))
+			Expect(v0backendResponses[1].AdminChangedBy).To(Equal("operator"))
+			Expect(v0backendResponses[1].AdminChangedAt).NotTo(BeNil())
+		})
+	})
+
+	Describe("BackendEndpoint", func() {
+		var server *ghttp.Server
+
+		BeforeEach(func() {
+			server = ghttp.NewServer()
+			server.AppendHandlers(api.BackendEndpoint(backends, fakeClusterManager, logger).ServeHTTP)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		patch := func(path string, form url.Values) *http.Response {
+			req, err := http.NewRequest("PATCH", server.URL()+path, strings.NewReader(form.Encode()))
+			Expect(err).NotTo(HaveOccurred())
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.SetBasicAuth("operator", "password")
+
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			return resp
+		}
+
+		It("sets the backend's admin state", func() {
+			resp := patch("/v0/backends/backend-1", url.Values{"adminState": {"drain"}, "message": {"upgrading"}})
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			Expect(fakeClusterManager.SetBackendAdminStateCallCount()).To(Equal(1))
+			backend, state, message, changedBy := fakeClusterManager.SetBackendAdminStateArgsForCall(0)
+			Expect(backend).To(Equal(backend1))
+			Expect(state).To(Equal("drain"))
+			Expect(message).To(Equal("upgrading"))
+			Expect(changedBy).To(Equal("operator"))
+
+			var backendResponse api.V0BackendResponse
+			Expect(json.NewDecoder(resp.Body).Decode(&backendResponse)).To(Succeed())
+			Expect(backendResponse.Name).To(Equal("backend-1"))
+		})
+
+		It("does not require a message to return the backend to active", func() {
+			resp := patch("/v0/backends/backend-1", url.Values{"adminState": {"active"}})
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(fakeClusterManager.SetBackendAdminStateCallCount()).To(Equal(1))
+		})
+
+		It("requires a message to take the backend out of rotation", func() {
+			resp := patch("/v0/backends/backend-1", url.Values{"adminState": {"maintenance"}})
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(fakeClusterManager.SetBackendAdminStateCallCount()).To(Equal(0))
+		})
+
+		It("returns 400 - Bad request for an invalid admin state", func() {
+			fakeClusterManager.SetBackendAdminStateReturns(errors.New("Unknown admin state"))
+
+			resp := patch("/v0/backends/backend-1", url.Values{"adminState": {"retired"}, "message": {"upgrading"}})
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		})
+
+		It("returns 404 - Not found for an unknown backend", func() {
+			resp := patch("/v0/backends/backend-9", url.Values{"adminState": {"drain"}, "message": {"upgrading"}})
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+			Expect(fakeClusterManager.SetBackendAdminStateCallCount()).To(Equal(0))
+		})
+
+		It("returns http 405 - Method not allowed for other methods", func() {
+			resp, err := http.Get(server.URL() + "/v0/backends/backend-1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+		})
 	})
 })
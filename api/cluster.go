@@ -5,8 +5,10 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"strconv"
+	"time"
 
 	"code.cloudfoundry.org/lager"
+	"github.com/cloudfoundry-incubator/switchboard/domain"
 )
 
 //go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 .  ClusterManager
@@ -14,6 +16,10 @@ type ClusterManager interface {
 	AsJSON() ClusterJSON
 	EnableTraffic(string)
 	DisableTraffic(string)
+	Failback()
+	PinActiveBackend(backend *domain.Backend, message string, pinnedBy string, expiresIn time.Duration)
+	UnpinActiveBackend()
+	SetBackendAdminState(backend *domain.Backend, state string, message string, changedBy string) error
 }
 
 var ClusterEndpoint = func(clusterManager ClusterManager, logger lager.Logger) http.HandlerFunc {
@@ -32,6 +38,88 @@ var ClusterEndpoint = func(clusterManager ClusterManager, logger lager.Logger) h
 	})
 }
 
+// FailbackEndpoint moves sticky listeners to the backend their selection
+// policy prefers.
+var FailbackEndpoint = func(clusterManager ClusterManager, logger lager.Logger) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		logger.Debug("API /cluster/failback")
+		clusterManager.Failback()
+		writeClusterResponse(w, clusterManager)
+	})
+}
+
+// ActiveBackendEndpoint pins the active listener to a named backend on PUT,
+// and releases the pin on DELETE.
+var ActiveBackendEndpoint = func(clusterManager ClusterManager, backends []*domain.Backend, logger lager.Logger) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case "PUT":
+			if handlePin(w, req, clusterManager, backends, logger) {
+				writeClusterResponse(w, clusterManager)
+			}
+		case "DELETE":
+			logger.Debug("API /cluster/active release")
+			clusterManager.UnpinActiveBackend()
+			writeClusterResponse(w, clusterManager)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func handlePin(
+	w http.ResponseWriter,
+	req *http.Request,
+	cluster ClusterManager,
+	backends []*domain.Backend,
+	logger lager.Logger,
+) bool {
+	err := req.ParseForm()
+	if err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return false
+	}
+
+	logger.Debug("API /cluster/active req form", lager.Data{"form": req.Form})
+
+	message := req.Form.Get("message")
+	if message == "" {
+		http.Error(w, "message must not be empty", http.StatusBadRequest)
+		return false
+	}
+
+	var expiresIn time.Duration
+	if expiresInStr := req.Form.Get("expiresInSeconds"); expiresInStr != "" {
+		expiresInSeconds, err := strconv.ParseUint(expiresInStr, 10, 32)
+		if err != nil {
+			http.Error(w, "Failed to parse expiresInSeconds", http.StatusBadRequest)
+			return false
+		}
+		expiresIn = time.Duration(expiresInSeconds) * time.Second
+	}
+
+	name := req.Form.Get("backend")
+	var backend *domain.Backend
+	for _, b := range backends {
+		if b.AsJSON().Name == name {
+			backend = b
+		}
+	}
+	if backend == nil {
+		http.Error(w, "Unknown backend", http.StatusNotFound)
+		return false
+	}
+
+	pinnedBy, _, _ := req.BasicAuth()
+	cluster.PinActiveBackend(backend, message, pinnedBy, expiresIn)
+	return true
+}
+
 func writeClusterResponse(w http.ResponseWriter, cluster ClusterManager) {
 	clusterJSON, err := json.Marshal(cluster.AsJSON())
 	if err != nil {
@@ -6,6 +6,7 @@ import (
 
 	"code.cloudfoundry.org/lager"
 	"github.com/cloudfoundry-incubator/switchboard/domain"
+	"github.com/cloudfoundry-incubator/switchboard/events"
 )
 
 type ClusterAPI struct {
@@ -15,6 +16,12 @@ type ClusterAPI struct {
 	lastUpdated         time.Time
 	trafficEnabled      bool
 	trafficEnabledChans []chan<- bool
+	failbacks           []func()
+	pins                []func(*domain.Backend)
+	reselects           []func()
+	eventLog            *events.Log
+	activePin           *ActivePinJSON
+	pinExpiry           *time.Timer
 	ActiveBackendChan   chan *domain.Backend
 	activeBackend       *BackendJSON
 }
@@ -29,10 +36,34 @@ func NewClusterAPI(
 	}
 }
 
+// SetEventLog records each time traffic is enabled or disabled, and the
+// message given, in the event log.
+func (c *ClusterAPI) SetEventLog(eventLog *events.Log) {
+	c.eventLog = eventLog
+}
+
 func (c *ClusterAPI) RegisterTrafficEnabledChan(chanToRegister chan bool) {
 	c.trafficEnabledChans = append(c.trafficEnabledChans, chanToRegister)
 }
 
+// RegisterFailback adds a function that Failback calls, e.g. to make a
+// ClusterMonitor choose its active backend again.
+func (c *ClusterAPI) RegisterFailback(failback func()) {
+	c.failbacks = append(c.failbacks, failback)
+}
+
+// RegisterPin adds a function that is given the backend pinned as active, or
+// nil when the pin is released, e.g. ClusterMonitor.Pin.
+func (c *ClusterAPI) RegisterPin(pin func(*domain.Backend)) {
+	c.pins = append(c.pins, pin)
+}
+
+// RegisterReselect adds a function that is called when a backend's admin state
+// changes, e.g. ClusterMonitor.Reselect.
+func (c *ClusterAPI) RegisterReselect(reselect func()) {
+	c.reselects = append(c.reselects, reselect)
+}
+
 func (c *ClusterAPI) ListenForActiveBackend() {
 	for b := range c.ActiveBackendChan {
 		c.mutex.Lock()
@@ -62,6 +93,7 @@ func (c *ClusterAPI) AsJSON() ClusterJSON {
 		Message:        c.message,
 		LastUpdated:    c.lastUpdated,
 		ActiveBackend:  c.activeBackend,
+		ActivePin:      c.activePin,
 	}
 }
 
@@ -74,6 +106,7 @@ func (c *ClusterAPI) EnableTraffic(message string) {
 	c.message = message
 	c.lastUpdated = time.Now()
 	c.trafficEnabled = true
+	c.eventLog.TrafficChanged(true, message)
 
 	for _, trafficEnabledChan := range c.trafficEnabledChans {
 		trafficEnabledChan <- c.trafficEnabled
@@ -89,17 +122,123 @@ func (c *ClusterAPI) DisableTraffic(message string) {
 	c.message = message
 	c.lastUpdated = time.Now()
 	c.trafficEnabled = false
+	c.eventLog.TrafficChanged(false, message)
 
 	for _, trafficEnabledChan := range c.trafficEnabledChans {
 		trafficEnabledChan <- c.trafficEnabled
 	}
 }
 
+func (c *ClusterAPI) Failback() {
+	c.logger.Info("Failing back cluster")
+
+	for _, failback := range c.failbacks {
+		failback()
+	}
+}
+
+// SetBackendAdminState takes the backend out of rotation for drain or
+// maintenance, or returns it with active, and has the active backends chosen
+// again.
+func (c *ClusterAPI) SetBackendAdminState(backend *domain.Backend, state string, message string, changedBy string) error {
+	err := backend.SetAdminState(state, message, changedBy)
+	if err != nil {
+		return err
+	}
+
+	for _, reselect := range c.reselects {
+		reselect()
+	}
+	return nil
+}
+
+// PinActiveBackend makes the backend active for as long as it is healthy,
+// until the pin is released or, if expiresIn is not zero, expires.
+func (c *ClusterAPI) PinActiveBackend(backend *domain.Backend, message string, pinnedBy string, expiresIn time.Duration) {
+	c.mutex.Lock()
+
+	j := backend.AsJSON()
+	c.logger.Info("Pinning active backend", lager.Data{
+		"backend":   j.Name,
+		"message":   message,
+		"pinnedBy":  pinnedBy,
+		"expiresIn": expiresIn.String(),
+	})
+
+	c.stopPinExpiry()
+	pin := &ActivePinJSON{
+		Backend:  j.Name,
+		Message:  message,
+		PinnedBy: pinnedBy,
+		PinnedAt: time.Now(),
+	}
+	if expiresIn > 0 {
+		expiresAt := pin.PinnedAt.Add(expiresIn)
+		pin.ExpiresAt = &expiresAt
+		c.pinExpiry = time.AfterFunc(expiresIn, func() {
+			c.unpin(pin)
+		})
+	}
+	c.activePin = pin
+	c.mutex.Unlock()
+
+	// the pins are told outside the lock, since they may wait on a monitor
+	// that is publishing a new active backend to this ClusterAPI
+	for _, pinFunc := range c.pins {
+		pinFunc(backend)
+	}
+}
+
+// UnpinActiveBackend releases the pin, leaving the choice of active backend
+// to the selection policy again.
+func (c *ClusterAPI) UnpinActiveBackend() {
+	c.mutex.RLock()
+	pin := c.activePin
+	c.mutex.RUnlock()
+
+	c.unpin(pin)
+}
+
+func (c *ClusterAPI) unpin(pin *ActivePinJSON) {
+	c.mutex.Lock()
+	// an expiry that fires after the pin was replaced leaves the new one
+	if pin == nil || c.activePin != pin {
+		c.mutex.Unlock()
+		return
+	}
+
+	c.logger.Info("Releasing active backend pin", lager.Data{"backend": pin.Backend})
+	c.stopPinExpiry()
+	c.activePin = nil
+	c.mutex.Unlock()
+
+	for _, pinFunc := range c.pins {
+		pinFunc(nil)
+	}
+}
+
+func (c *ClusterAPI) stopPinExpiry() {
+	if c.pinExpiry != nil {
+		c.pinExpiry.Stop()
+		c.pinExpiry = nil
+	}
+}
+
 type ClusterJSON struct {
-	ActiveBackend  *BackendJSON `json:"activeBackend"`
-	TrafficEnabled bool         `json:"trafficEnabled"`
-	Message        string       `json:"message"`
-	LastUpdated    time.Time    `json:"lastUpdated"`
+	ActiveBackend  *BackendJSON   `json:"activeBackend"`
+	TrafficEnabled bool           `json:"trafficEnabled"`
+	Message        string         `json:"message"`
+	LastUpdated    time.Time      `json:"lastUpdated"`
+	ActivePin      *ActivePinJSON `json:"activePin"`
+}
+
+// ActivePinJSON describes a backend pinned as active through the API.
+type ActivePinJSON struct {
+	Backend   string     `json:"backend"`
+	Message   string     `json:"message"`
+	PinnedBy  string     `json:"pinnedBy"`
+	PinnedAt  time.Time  `json:"pinnedAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
 }
 
 type BackendJSON struct {
@@ -10,6 +10,7 @@ import (
 	"code.cloudfoundry.org/lager/lagertest"
 	"github.com/cloudfoundry-incubator/switchboard/api"
 	"github.com/cloudfoundry-incubator/switchboard/domain"
+	"github.com/cloudfoundry-incubator/switchboard/events"
 )
 
 var _ = Describe("ClusterAPI", func() {
@@ -146,5 +147,126 @@ var _ = Describe("ClusterAPI", func() {
 			Expect(clusterJSON.LastUpdated.After(beforeTime)).To(BeTrue())
 			Expect(clusterJSON.LastUpdated.Before(afterTime)).To(BeTrue())
 		})
+
+		It("records the change in the event log", func() {
+			eventLog, err := events.NewLog(10, "", logger)
+			Expect(err).NotTo(HaveOccurred())
+			cluster.SetEventLog(eventLog)
+
+			cluster.DisableTraffic(message)
+			cluster.EnableTraffic("back")
+
+			recorded := eventLog.Between(time.Time{}, time.Time{})
+			Expect(recorded).To(HaveLen(2))
+			Expect(recorded[0].Kind).To(Equal(events.KindTrafficDisabled))
+			Expect(recorded[0].Message).To(Equal(message))
+			Expect(recorded[1].Kind).To(Equal(events.KindTrafficEnabled))
+			Expect(recorded[1].Message).To(Equal("back"))
+		})
+	})
+
+	Describe("Failback", func() {
+		It("calls each registered failback", func() {
+			var calls []string
+			cluster.RegisterFailback(func() { calls = append(calls, "active") })
+			cluster.RegisterFailback(func() { calls = append(calls, "inactive") })
+
+			cluster.Failback()
+
+			Expect(calls).To(Equal([]string{"active", "inactive"}))
+		})
+	})
+	Describe("PinActiveBackend", func() {
+		var (
+			backend *domain.Backend
+			pinned  chan *domain.Backend
+		)
+
+		BeforeEach(func() {
+			backend = domain.NewBackend("backend-1", "192.0.2.11", 3306, 9200, "", lagertest.NewTestLogger("Cluster test"))
+			pinned = make(chan *domain.Backend, 10)
+		})
+
+		JustBeforeEach(func() {
+			cluster.RegisterPin(func(b *domain.Backend) { pinned <- b })
+		})
+
+		It("records the pin", func() {
+			cluster.PinActiveBackend(backend, "some message", "operator", 10*time.Minute)
+
+			pin := cluster.AsJSON().ActivePin
+			Expect(pin).NotTo(BeNil())
+			Expect(pin.Backend).To(Equal("backend-1"))
+			Expect(pin.Message).To(Equal("some message"))
+			Expect(pin.PinnedBy).To(Equal("operator"))
+			Expect(pin.PinnedAt).To(BeTemporally("~", time.Now(), time.Second))
+			Expect(*pin.ExpiresAt).To(Equal(pin.PinnedAt.Add(10 * time.Minute)))
+		})
+
+		It("passes the backend to each registered pin", func() {
+			cluster.PinActiveBackend(backend, "some message", "operator", 0)
+
+			Expect(pinned).To(Receive(Equal(backend)))
+			Expect(cluster.AsJSON().ActivePin.ExpiresAt).To(BeNil())
+		})
+
+		It("releases the pin when it expires", func() {
+			cluster.PinActiveBackend(backend, "some message", "operator", 50*time.Millisecond)
+			Expect(pinned).To(Receive(Equal(backend)))
+
+			Eventually(pinned).Should(Receive(BeNil()))
+			Expect(cluster.AsJSON().ActivePin).To(BeNil())
+		})
+
+		It("does not release a newer pin when an earlier one would have expired", func() {
+			cluster.PinActiveBackend(backend, "first", "operator", 50*time.Millisecond)
+			cluster.PinActiveBackend(backend, "second", "operator", 0)
+
+			Consistently(func() *api.ActivePinJSON { return cluster.AsJSON().ActivePin }, 200*time.Millisecond).ShouldNot(BeNil())
+		})
+
+		Describe("UnpinActiveBackend", func() {
+			It("releases the pin", func() {
+				cluster.PinActiveBackend(backend, "some message", "operator", 0)
+				Expect(pinned).To(Receive(Equal(backend)))
+
+				cluster.UnpinActiveBackend()
+
+				Expect(pinned).To(Receive(BeNil()))
+				Expect(cluster.AsJSON().ActivePin).To(BeNil())
+			})
+
+			It("does nothing without a pin", func() {
+				cluster.UnpinActiveBackend()
+
+				Expect(pinned).NotTo(Receive())
+			})
+		})
+	})
+	Describe("SetBackendAdminState", func() {
+		var backend *domain.Backend
+
+		BeforeEach(func() {
+			backend = domain.NewBackend("backend-1", "192.0.2.11", 3306, 9200, "", lagertest.NewTestLogger("Cluster test"))
+		})
+
+		It("sets the admin state and calls each registered reselect", func() {
+			reselects := 0
+			cluster.RegisterReselect(func() { reselects++ })
+			cluster.RegisterReselect(func() { reselects++ })
+
+			Expect(cluster.SetBackendAdminState(backend, domain.AdminStateDrain, "upgrading", "operator")).To(Succeed())
+
+			Expect(backend.AdminState()).To(Equal(domain.AdminStateDrain))
+			Expect(reselects).To(Equal(2))
+		})
+
+		It("returns the error for an unknown state without reselecting", func() {
+			reselects := 0
+			cluster.RegisterReselect(func() { reselects++ })
+
+			Expect(cluster.SetBackendAdminState(backend, "retired", "upgrading", "operator")).NotTo(Succeed())
+			Expect(reselects).To(Equal(0))
+		})
 	})
 })
@@ -3,11 +3,14 @@ package api_test
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"code.cloudfoundry.org/lager/lagertest"
 	"github.com/cloudfoundry-incubator/switchboard/api"
 	"github.com/cloudfoundry-incubator/switchboard/api/apifakes"
+	"github.com/cloudfoundry-incubator/switchboard/domain"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/ghttp"
@@ -257,3 +260,149 @@ var _ = Describe("ClusterEndpoint", func() {
 		})
 	})
 })
+
+var _ = Describe("FailbackEndpoint", func() {
+	var (
+		fakeCluster *apifakes.FakeClusterManager
+		server      *ghttp.Server
+	)
+
+	BeforeEach(func() {
+		fakeCluster = new(apifakes.FakeClusterManager)
+		fakeCluster.AsJSONReturns(api.ClusterJSON{TrafficEnabled: true})
+
+		server = ghttp.NewServer()
+		server.AppendHandlers(api.FailbackEndpoint(fakeCluster, lagertest.NewTestLogger("Switchboard API test")))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("POST", func() {
+		It("invokes cluster.Failback and returns the cluster", func() {
+			resp, err := http.Post(server.URL(), "", nil)
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(fakeCluster.FailbackCallCount()).To(Equal(1))
+
+			var clusterJSON api.ClusterJSON
+			Expect(json.NewDecoder(resp.Body).Decode(&clusterJSON)).To(Succeed())
+			Expect(clusterJSON.TrafficEnabled).To(BeTrue())
+		})
+	})
+
+	Describe("GET", func() {
+		It("returns http 405 - Method not allowed", func() {
+			resp, err := http.Get(server.URL())
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(resp.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+			Expect(fakeCluster.FailbackCallCount()).To(Equal(0))
+		})
+	})
+})
+
+var _ = Describe("ActiveBackendEndpoint", func() {
+	var (
+		fakeCluster *apifakes.FakeClusterManager
+		backends    []*domain.Backend
+		server      *ghttp.Server
+	)
+
+	BeforeEach(func() {
+		fakeCluster = new(apifakes.FakeClusterManager)
+		testLogger := lagertest.NewTestLogger("Switchboard API test")
+
+		backends = []*domain.Backend{
+			domain.NewBackend("backend-0", "192.0.2.10", 3306, 9200, "", testLogger),
+			domain.NewBackend("backend-1", "192.0.2.11", 3306, 9200, "", testLogger),
+		}
+
+		server = ghttp.NewServer()
+		server.AppendHandlers(api.ActiveBackendEndpoint(fakeCluster, backends, testLogger))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	put := func(form url.Values) *http.Response {
+		req, err := http.NewRequest("PUT", server.URL(), strings.NewReader(form.Encode()))
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("operator", "password")
+
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		return resp
+	}
+
+	Describe("PUT", func() {
+		It("pins the named backend", func() {
+			resp := put(url.Values{
+				"backend":          {"backend-1"},
+				"message":          {"upgrading backend-0"},
+				"expiresInSeconds": {"600"},
+			})
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			Expect(fakeCluster.PinActiveBackendCallCount()).To(Equal(1))
+			backend, message, pinnedBy, expiresIn := fakeCluster.PinActiveBackendArgsForCall(0)
+			Expect(backend).To(Equal(backends[1]))
+			Expect(message).To(Equal("upgrading backend-0"))
+			Expect(pinnedBy).To(Equal("operator"))
+			Expect(expiresIn).To(Equal(10 * time.Minute))
+		})
+
+		It("does not require an expiry", func() {
+			resp := put(url.Values{"backend": {"backend-0"}, "message": {"some message"}})
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			_, _, _, expiresIn := fakeCluster.PinActiveBackendArgsForCall(0)
+			Expect(expiresIn).To(BeZero())
+		})
+
+		It("requires a message", func() {
+			resp := put(url.Values{"backend": {"backend-0"}})
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(fakeCluster.PinActiveBackendCallCount()).To(Equal(0))
+		})
+
+		It("returns 400 - Bad request for an invalid expiry", func() {
+			resp := put(url.Values{"backend": {"backend-0"}, "message": {"some message"}, "expiresInSeconds": {"soon"}})
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(fakeCluster.PinActiveBackendCallCount()).To(Equal(0))
+		})
+
+		It("returns 404 - Not found for an unknown backend", func() {
+			resp := put(url.Values{"backend": {"backend-9"}, "message": {"some message"}})
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+			Expect(fakeCluster.PinActiveBackendCallCount()).To(Equal(0))
+		})
+	})
+
+	Describe("DELETE", func() {
+		It("releases the pin", func() {
+			req, err := http.NewRequest("DELETE", server.URL(), nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(fakeCluster.UnpinActiveBackendCallCount()).To(Equal(1))
+		})
+	})
+
+	Describe("GET", func() {
+		It("returns http 405 - Method not allowed", func() {
+			resp, err := http.Get(server.URL())
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(resp.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+})
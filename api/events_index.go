@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cloudfoundry-incubator/switchboard/events"
+)
+
+// EventsIndex lists the recorded events, oldest first. The from and to
+// parameters, as RFC 3339 times, limit them to a range; either may be left
+// out.
+var EventsIndex = func(eventLog *events.Log) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := req.URL.Query()
+		from, err := parseTimeParam(query.Get("from"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to parse from: %s", err), http.StatusBadRequest)
+			return
+		}
+		to, err := parseTimeParam(query.Get("to"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to parse to: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		body, err := json.Marshal(eventLog.Between(from, to))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, err = w.Write(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,75 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry-incubator/switchboard/api"
+	"github.com/cloudfoundry-incubator/switchboard/events"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("EventsIndex", func() {
+	var (
+		eventLog         *events.Log
+		responseRecorder *httptest.ResponseRecorder
+		start            time.Time
+	)
+
+	BeforeEach(func() {
+		var err error
+		eventLog, err = events.NewLog(10, "", lagertest.NewTestLogger("EventsIndex test"))
+		Expect(err).NotTo(HaveOccurred())
+
+		start = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		eventLog.Record(events.Event{Time: start, Kind: events.KindTrafficDisabled, Message: "upgrading"})
+		eventLog.Record(events.Event{Time: start.Add(time.Hour), Kind: events.KindBackendUnhealthy, Backend: "backend-0"})
+		eventLog.Record(events.Event{Time: start.Add(2 * time.Hour), Kind: events.KindTrafficEnabled, Message: "done"})
+
+		responseRecorder = httptest.NewRecorder()
+	})
+
+	get := func(url string) []events.Event {
+		request, err := http.NewRequest("GET", url, nil)
+		Expect(err).NotTo(HaveOccurred())
+		api.EventsIndex(eventLog).ServeHTTP(responseRecorder, request)
+		Expect(responseRecorder.Code).To(Equal(http.StatusOK))
+
+		var recorded []events.Event
+		Expect(json.Unmarshal(responseRecorder.Body.Bytes(), &recorded)).To(Succeed())
+		return recorded
+	}
+
+	It("lists every event, oldest first", func() {
+		recorded := get("/v0/events")
+		Expect(recorded).To(HaveLen(3))
+		Expect(recorded[0].Kind).To(Equal(events.KindTrafficDisabled))
+		Expect(recorded[2].Kind).To(Equal(events.KindTrafficEnabled))
+	})
+
+	It("lists the events within the time range", func() {
+		recorded := get("/v0/events?from=2020-01-01T00:30:00Z&to=2020-01-01T01:30:00Z")
+		Expect(recorded).To(HaveLen(1))
+		Expect(recorded[0].Backend).To(Equal("backend-0"))
+	})
+
+	It("returns 400 when a time cannot be parsed", func() {
+		request, err := http.NewRequest("GET", "/v0/events?from=yesterday", nil)
+		Expect(err).NotTo(HaveOccurred())
+		api.EventsIndex(eventLog).ServeHTTP(responseRecorder, request)
+
+		Expect(responseRecorder.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("returns 405 for other methods", func() {
+		request, err := http.NewRequest("DELETE", "/v0/events", nil)
+		Expect(err).NotTo(HaveOccurred())
+		api.EventsIndex(eventLog).ServeHTTP(responseRecorder, request)
+
+		Expect(responseRecorder.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/cloudfoundry-incubator/switchboard/events"
+)
+
+// StreamKeepaliveInterval is how often an idle event stream is sent a
+// comment, so that proxies do not time it out.
+var StreamKeepaliveInterval = 15 * time.Second
+
+// EventsStream pushes each event as it is recorded, as Server-Sent Events
+// named after the event's kind and identified by its time. A client that
+// reconnects with a Last-Event-ID is first sent the events it missed.
+var EventsStream = func(eventLog *events.Log, logger lager.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		var lastEventTime time.Time
+		if lastEventID := req.Header.Get("Last-Event-ID"); lastEventID != "" {
+			var err error
+			lastEventTime, err = time.Parse(time.RFC3339Nano, lastEventID)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Failed to parse Last-Event-ID: %s", err), http.StatusBadRequest)
+				return
+			}
+		}
+
+		// subscribing before reading the missed events means none are lost
+		// in between; those also received from the subscription are skipped
+		// by their time
+		subscription, unsubscribe := eventLog.Subscribe()
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+
+		send := func(event events.Event) error {
+			data, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.Time.Format(time.RFC3339Nano), event.Kind, data)
+			return err
+		}
+
+		replayedUntil := lastEventTime
+		if !lastEventTime.IsZero() {
+			for _, event := range eventLog.Between(lastEventTime, time.Time{}) {
+				if !event.Time.After(lastEventTime) {
+					continue
+				}
+				if err := send(event); err != nil {
+					return
+				}
+				replayedUntil = event.Time
+			}
+		}
+		_, err := fmt.Fprint(w, ": connected\n\n")
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+
+		keepalive := time.NewTicker(StreamKeepaliveInterval)
+		defer keepalive.Stop()
+
+		for {
+			select {
+			case event := <-subscription:
+				if !replayedUntil.IsZero() && !event.Time.After(replayedUntil) {
+					continue
+				}
+				err = send(event)
+			case <-keepalive.C:
+				_, err = fmt.Fprint(w, ": keepalive\n\n")
+			case <-req.Context().Done():
+				return
+			}
+			if err != nil {
+				logger.Info("Event stream closed", lager.Data{"error": err.Error()})
+				return
+			}
+			flusher.Flush()
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package api_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry-incubator/switchboard/api"
+	"github.com/cloudfoundry-incubator/switchboard/events"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("EventsStream", func() {
+	var (
+		eventLog *events.Log
+		server   *httptest.Server
+		start    time.Time
+	)
+
+	BeforeEach(func() {
+		logger := lagertest.NewTestLogger("EventsStream test")
+
+		var err error
+		eventLog, err = events.NewLog(10, "", logger)
+		Expect(err).NotTo(HaveOccurred())
+
+		server = httptest.NewServer(api.EventsStream(eventLog, logger))
+		start = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	})
+
+	AfterEach(func() {
+		server.CloseClientConnections()
+		server.Close()
+	})
+
+	// connect opens the stream and returns its lines once the server has
+	// sent the connected comment
+	connect := func() (<-chan string, func()) {
+		resp, err := http.Get(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+
+		lines := make(chan string, 100)
+		go func() {
+			defer GinkgoRecover()
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				lines <- scanner.Text()
+			}
+			close(lines)
+		}()
+
+		Eventually(lines).Should(Receive(Equal(": connected")))
+		Eventually(lines).Should(Receive(Equal("")))
+		return lines, func() { resp.Body.Close() }
+	}
+
+	// receiveEvent reads the lines of the next event
+	receiveEvent := func(lines <-chan string) (string, string, events.Event) {
+		var id, kind string
+		var event events.Event
+		for {
+			var line string
+			Eventually(lines).Should(Receive(&line))
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				kind = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				Expect(json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event)).To(Succeed())
+			case line == "":
+				return id, kind, event
+			}
+		}
+	}
+
+	It("pushes each event as it is recorded", func() {
+		lines, disconnect := connect()
+		defer disconnect()
+
+		eventLog.ActiveBackendChanged("active", "backend-0", "backend-1", events.ReasonUnhealthy)
+
+		id, kind, event := receiveEvent(lines)
+		Expect(kind).To(Equal(events.KindActiveBackendChanged))
+		Expect(event.Backend).To(Equal("backend-1"))
+		Expect(event.PreviousBackend).To(Equal("backend-0"))
+		Expect(id).To(Equal(event.Time.Format(time.RFC3339Nano)))
+	})
+
+	It("first sends the events after the Last-Event-ID", func() {
+		eventLog.Record(events.Event{Time: start, Kind: events.KindTrafficDisabled})
+		eventLog.Record(events.Event{Time: start.Add(time.Minute), Kind: events.KindBackendUnhealthy, Backend: "backend-0"})
+		eventLog.Record(events.Event{Time: start.Add(2 * time.Minute), Kind: events.KindTrafficEnabled})
+
+		request, err := http.NewRequest("GET", server.URL, nil)
+		Expect(err).NotTo(HaveOccurred())
+		request.Header.Set("Last-Event-ID", start.Format(time.RFC3339Nano))
+		resp, err := http.DefaultClient.Do(request)
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+
+		scanner := bufio.NewScanner(resp.Body)
+		var received []string
+		for scanner.Scan() && scanner.Text() != ": connected" {
+			if strings.HasPrefix(scanner.Text(), "event: ") {
+				received = append(received, strings.TrimPrefix(scanner.Text(), "event: "))
+			}
+		}
+		Expect(received).To(Equal([]string{events.KindBackendUnhealthy, events.KindTrafficEnabled}))
+	})
+
+	It("returns 400 for a Last-Event-ID that is not a time", func() {
+		request, err := http.NewRequest("GET", server.URL, nil)
+		Expect(err).NotTo(HaveOccurred())
+		request.Header.Set("Last-Event-ID", "42")
+
+		resp, err := http.DefaultClient.Do(request)
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+	})
+
+	It("returns 405 for other methods", func() {
+		resp, err := http.Post(server.URL, "text/plain", nil)
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+	})
+
+	Context("when the stream is idle", func() {
+		var originalInterval time.Duration
+
+		BeforeEach(func() {
+			originalInterval = api.StreamKeepaliveInterval
+			api.StreamKeepaliveInterval = 10 * time.Millisecond
+		})
+
+		AfterEach(func() {
+			api.StreamKeepaliveInterval = originalInterval
+		})
+
+		It("sends keepalive comments", func() {
+			lines, disconnect := connect()
+			defer disconnect()
+
+			Eventually(lines).Should(Receive(Equal(": keepalive")))
+		})
+	})
+})
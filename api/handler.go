@@ -7,21 +7,36 @@ import (
 	"github.com/cloudfoundry-incubator/switchboard/api/middleware"
 	"github.com/cloudfoundry-incubator/switchboard/config"
 	"github.com/cloudfoundry-incubator/switchboard/domain"
+	"github.com/cloudfoundry-incubator/switchboard/events"
 )
 
 func NewHandler(
 	clusterManager ClusterManager,
 	backends []*domain.Backend,
+	listeners []domain.Listener,
 	logger lager.Logger,
 	apiConfig config.API,
 	staticDir string,
+	metricsHandler http.Handler,
+	eventLog *events.Log,
 ) http.Handler {
 	mux := http.NewServeMux()
 
 	mux.Handle("/", http.FileServer(http.Dir(staticDir)))
 
 	mux.Handle("/v0/backends", BackendsIndex(backends, clusterManager))
+	mux.Handle("/v0/backends/", BackendEndpoint(backends, clusterManager, logger))
+	mux.Handle("/v0/listeners", ListenersIndex(listeners))
 	mux.Handle("/v0/cluster", ClusterEndpoint(clusterManager, logger))
+	mux.Handle("/v0/cluster/failback", FailbackEndpoint(clusterManager, logger))
+	mux.Handle("/v0/cluster/active", ActiveBackendEndpoint(clusterManager, backends, logger))
+	mux.Handle("/v0/sessions", SessionsEndpoint(backends, eventLog, logger))
+	mux.Handle("/v0/sessions/", SessionEndpoint(backends, eventLog, logger))
+	mux.Handle("/v0/events", EventsIndex(eventLog))
+	mux.Handle("/v0/events/stream", EventsStream(eventLog, logger))
+	if metricsHandler != nil {
+		mux.Handle("/metrics", metricsHandler)
+	}
 
 	return middleware.Chain{
 		middleware.NewPanicRecovery(logger),
@@ -1,6 +1,7 @@
 package api_test
 
 import (
+	"bufio"
 	"net/http"
 	"net/http/httptest"
 
@@ -33,9 +34,14 @@ var _ = Describe("Handler", func() {
 		handler = api.NewHandler(
 			cluster,
 			backends,
+			[]domain.Listener{},
 			logger,
 			cfg,
 			staticDir,
+			http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Write([]byte("switchboard_traffic_enabled 1\n"))
+			}),
+			nil,
 		)
 	})
 
@@ -78,6 +84,57 @@ var _ = Describe("Handler", func() {
 		})
 	})
 
+	Context("when metrics are requested", func() {
+		BeforeEach(func() {
+			cfg = config.API{
+				Username: "foo",
+				Password: "bar",
+			}
+		})
+
+		It("serves them behind basic auth", func() {
+			request, err := http.NewRequest("GET", "/metrics", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			responseRecorder = httptest.NewRecorder()
+			handler.ServeHTTP(responseRecorder, request)
+			Expect(responseRecorder.Code).To(Equal(http.StatusUnauthorized))
+
+			request.SetBasicAuth("foo", "bar")
+			responseRecorder = httptest.NewRecorder()
+			handler.ServeHTTP(responseRecorder, request)
+			Expect(responseRecorder.Code).To(Equal(http.StatusOK))
+			Expect(responseRecorder.Body.String()).To(Equal("switchboard_traffic_enabled 1\n"))
+		})
+	})
+
+	Context("when the event stream is requested", func() {
+		BeforeEach(func() {
+			cfg = config.API{
+				Username: "foo",
+				Password: "bar",
+			}
+		})
+
+		It("streams through the middleware", func() {
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			request, err := http.NewRequest("GET", server.URL+"/v0/events/stream", nil)
+			Expect(err).NotTo(HaveOccurred())
+			request.SetBasicAuth("foo", "bar")
+
+			resp, err := http.DefaultClient.Do(request)
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			line, err := bufio.NewReader(resp.Body).ReadString('\n')
+			Expect(err).NotTo(HaveOccurred())
+			Expect(line).To(Equal(": connected\n"))
+		})
+	})
+
 	Context("when request does not contain https header", func() {
 
 		var request *http.Request
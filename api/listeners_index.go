@@ -0,0 +1,30 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/cloudfoundry-incubator/switchboard/domain"
+)
+
+var ListenersIndex = func(listeners []domain.Listener) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		listenersJSON := []domain.ListenerJSON{}
+		for _, l := range listeners {
+			listenersJSON = append(listenersJSON, l.AsJSON())
+		}
+
+		body, err := json.Marshal(listenersJSON)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, err = w.Write(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry-incubator/switchboard/api"
+	"github.com/cloudfoundry-incubator/switchboard/config"
+	"github.com/cloudfoundry-incubator/switchboard/domain"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ListenersIndex", func() {
+	It("lists each listener with its connection counts", func() {
+		logger := lagertest.NewTestLogger("ListenersIndex test")
+
+		active, err := domain.NewListener("active", config.Listener{MaxConnections: 1}, logger)
+		Expect(err).NotTo(HaveOccurred())
+		inactive, err := domain.NewListener("inactive", config.Listener{}, logger)
+		Expect(err).NotTo(HaveOccurred())
+
+		clientAddr := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 50000}
+		active.Admit(clientAddr)
+		active.Admit(clientAddr)
+
+		responseRecorder := httptest.NewRecorder()
+		request, err := http.NewRequest("GET", "/v0/listeners", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		api.ListenersIndex([]domain.Listener{active, inactive}).ServeHTTP(responseRecorder, request)
+
+		Expect(responseRecorder.Code).To(Equal(http.StatusOK))
+
+		var listeners []domain.ListenerJSON
+		Expect(json.Unmarshal(responseRecorder.Body.Bytes(), &listeners)).To(Succeed())
+		Expect(listeners).To(Equal([]domain.ListenerJSON{
+			{
+				Name:                "active",
+				CurrentConnections:  1,
+				RejectedConnections: map[string]uint64{domain.RejectReasonMaxConnections: 1},
+			},
+			{
+				Name:                "inactive",
+				RejectedConnections: map[string]uint64{},
+			},
+		}))
+	})
+})
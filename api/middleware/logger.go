@@ -90,3 +90,11 @@ func (rw *responseWriter) WriteHeader(s int) {
 	rw.statusCode = s
 	rw.ResponseWriter.WriteHeader(s)
 }
+
+// Flush lets handlers that stream their response, such as the event stream,
+// flush through the logger.
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -2,6 +2,7 @@ package middleware_test
 
 import (
 	"net/http"
+	"net/http/httptest"
 
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagertest"
@@ -70,4 +71,17 @@ var _ = Describe("Logger", func() {
 		Expect(arg0).ToNot(BeNil())
 		Expect(arg1).To(Equal(dummyRequest))
 	})
+
+	It("lets the next handler flush a streamed response", func() {
+		recorder := httptest.NewRecorder()
+		loggerHandler := middleware.NewLogger(logger, routePrefix).Wrap(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			flusher, ok := rw.(http.Flusher)
+			Expect(ok).To(BeTrue())
+			flusher.Flush()
+		}))
+
+		loggerHandler.ServeHTTP(recorder, dummyRequest)
+
+		Expect(recorder.Flushed).To(BeTrue())
+	})
 })
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/cloudfoundry-incubator/switchboard/domain"
+	"github.com/cloudfoundry-incubator/switchboard/events"
+)
+
+var SessionsEndpoint = func(backends []*domain.Backend, eventLog *events.Log, logger lager.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+
+		switch req.Method {
+		case "GET":
+			writeSessionsResponse(w, Backends(backends).Sessions(query.Get("backend"), query.Get("listener")))
+			return
+		case "DELETE":
+			_, clientCIDR, err := net.ParseCIDR(query.Get("clientCIDR"))
+			if err != nil {
+				http.Error(w, "Failed to parse clientCIDR", http.StatusBadRequest)
+				return
+			}
+
+			severed := Backends(backends).SeverSessions(func(s domain.SessionJSON) bool {
+				return clientIn(s, clientCIDR) &&
+					matchesFilter(s.Backend, query.Get("backend")) &&
+					matchesFilter(s.Listener, query.Get("listener"))
+			})
+			auditSever(logger, eventLog, req, severed, lager.Data{"clientCIDR": clientCIDR.String()})
+
+			writeSessionsResponse(w, severed)
+			return
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+var SessionEndpoint = func(backends []*domain.Backend, eventLog *events.Log, logger lager.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case "DELETE":
+			id := strings.TrimPrefix(req.URL.Path, "/v0/sessions/")
+
+			severed := Backends(backends).SeverSessions(func(s domain.SessionJSON) bool {
+				return s.ID == id
+			})
+			if len(severed) == 0 {
+				http.Error(w, "Session not found", http.StatusNotFound)
+				return
+			}
+			auditSever(logger, eventLog, req, severed, lager.Data{"id": id})
+
+			writeSessionsResponse(w, severed)
+			return
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// Sessions lists the sessions of every backend, optionally filtered by
+// backend name and listener. Empty filters match everything.
+func (bs Backends) Sessions(backendName, listener string) []domain.SessionJSON {
+	sessions := []domain.SessionJSON{}
+
+	for _, b := range bs {
+		for _, s := range b.SessionsAsJSON() {
+			if matchesFilter(s.Backend, backendName) && matchesFilter(s.Listener, listener) {
+				sessions = append(sessions, s)
+			}
+		}
+	}
+
+	return sessions
+}
+
+func (bs Backends) SeverSessions(matches func(domain.SessionJSON) bool) []domain.SessionJSON {
+	severed := []domain.SessionJSON{}
+
+	for _, b := range bs {
+		severed = append(severed, b.SeverSessions(matches)...)
+	}
+
+	return severed
+}
+
+func matchesFilter(value, filter string) bool {
+	return filter == "" || value == filter
+}
+
+func clientIn(s domain.SessionJSON, cidr *net.IPNet) bool {
+	host, _, err := net.SplitHostPort(s.ClientAddress)
+	if err != nil {
+		return false
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && cidr.Contains(ip)
+}
+
+func auditSever(logger lager.Logger, eventLog *events.Log, req *http.Request, severed []domain.SessionJSON, data lager.Data) {
+	username, _, _ := req.BasicAuth()
+
+	data["user"] = username
+	data["sessions"] = severed
+	logger.Info("Severing sessions via API", data)
+
+	// one event for each backend and listener, in the order they were severed
+	type backendListener struct{ backend, listener string }
+	var order []backendListener
+	counts := map[backendListener]uint{}
+	for _, s := range severed {
+		key := backendListener{s.Backend, s.Listener}
+		if counts[key] == 0 {
+			order = append(order, key)
+		}
+		counts[key]++
+	}
+	for _, key := range order {
+		eventLog.SessionsSevered(key.backend, key.listener, counts[key], events.SeverReasonOperator)
+	}
+}
+
+func writeSessionsResponse(w http.ResponseWriter, sessions []domain.SessionJSON) {
+	sessionsJSON, err := json.Marshal(sessions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, err = w.Write(sessionsJSON)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,303 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry-incubator/switchboard/api"
+	"github.com/cloudfoundry-incubator/switchboard/domain"
+	"github.com/cloudfoundry-incubator/switchboard/domain/domainfakes"
+	"github.com/cloudfoundry-incubator/switchboard/events"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("SessionsEndpoint", func() {
+	var (
+		backend0Bridges, backend1Bridges *domainfakes.FakeBridges
+		backends                         []*domain.Backend
+		eventLog                         *events.Log
+		testLogger                       *lagertest.TestLogger
+
+		server *ghttp.Server
+	)
+
+	BeforeEach(func() {
+		testLogger = lagertest.NewTestLogger("Sessions test")
+
+		backend0Bridges = new(domainfakes.FakeBridges)
+		fakeSessions(backend0Bridges, []domain.SessionJSON{
+			{ID: "1", Listener: "active", ClientAddress: "10.0.0.1:50000"},
+			{ID: "2", Listener: "inactive", ClientAddress: "10.0.0.2:50000"},
+		})
+		backend1Bridges = new(domainfakes.FakeBridges)
+		fakeSessions(backend1Bridges, []domain.SessionJSON{
+			{ID: "3", Listener: "active", ClientAddress: "192.168.0.3:50000"},
+		})
+
+		domain.BridgesProvider = func(lager.Logger) domain.Bridges {
+			return backend0Bridges
+		}
+		backend0 := domain.NewBackend("backend-0", "backend-0-host", 3306, 9200, "status", testLogger)
+
+		domain.BridgesProvider = func(lager.Logger) domain.Bridges {
+			return backend1Bridges
+		}
+		backend1 := domain.NewBackend("backend-1", "backend-1-host", 3306, 9200, "status", testLogger)
+
+		backends = []*domain.Backend{backend0, backend1}
+
+		var err error
+		eventLog, err = events.NewLog(10, "", testLogger)
+		Expect(err).NotTo(HaveOccurred())
+
+		server = ghttp.NewServer()
+		server.AppendHandlers(api.SessionsEndpoint(backends, eventLog, testLogger).ServeHTTP)
+	})
+
+	AfterEach(func() {
+		domain.BridgesProvider = domain.NewBridges
+		server.Close()
+	})
+
+	getSessions := func(query string) []domain.SessionJSON {
+		resp, err := http.Get(server.URL() + query)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		var sessions []domain.SessionJSON
+		err = json.NewDecoder(resp.Body).Decode(&sessions)
+		Expect(err).NotTo(HaveOccurred())
+		return sessions
+	}
+
+	sessionIDs := func(sessions []domain.SessionJSON) []string {
+		var ids []string
+		for _, s := range sessions {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+
+	Describe("GET", func() {
+		It("lists the sessions of every backend", func() {
+			sessions := getSessions("")
+			Expect(sessionIDs(sessions)).To(Equal([]string{"1", "2", "3"}))
+			Expect(sessions[0].Backend).To(Equal("backend-0"))
+			Expect(sessions[0].ClientAddress).To(Equal("10.0.0.1:50000"))
+			Expect(sessions[2].Backend).To(Equal("backend-1"))
+		})
+
+		It("filters by backend", func() {
+			Expect(sessionIDs(getSessions("?backend=backend-1"))).To(Equal([]string{"3"}))
+		})
+
+		It("filters by listener", func() {
+			Expect(sessionIDs(getSessions("?listener=active"))).To(Equal([]string{"1", "3"}))
+		})
+
+		It("combines filters", func() {
+			Expect(sessionIDs(getSessions("?backend=backend-0&listener=inactive"))).To(Equal([]string{"2"}))
+		})
+
+		Context("when there are no matching sessions", func() {
+			It("returns an empty list", func() {
+				resp, err := http.Get(server.URL() + "?backend=unknown")
+				Expect(err).NotTo(HaveOccurred())
+
+				var body []interface{}
+				err = json.NewDecoder(resp.Body).Decode(&body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(body).NotTo(BeNil())
+				Expect(body).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("DELETE", func() {
+		deleteSessions := func(query string) *http.Response {
+			req, err := http.NewRequest("DELETE", server.URL()+query, nil)
+			Expect(err).NotTo(HaveOccurred())
+			req.SetBasicAuth("some-operator", "some-password")
+
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			return resp
+		}
+
+		It("severs the sessions from clients in the given CIDR", func() {
+			resp := deleteSessions("?clientCIDR=10.0.0.0/8")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			var severed []domain.SessionJSON
+			Expect(json.NewDecoder(resp.Body).Decode(&severed)).To(Succeed())
+			Expect(sessionIDs(severed)).To(Equal([]string{"1", "2"}))
+
+			Expect(sessionIDs(backend0Bridges.AsJSON())).To(BeEmpty())
+			Expect(sessionIDs(backend1Bridges.AsJSON())).To(Equal([]string{"3"}))
+		})
+
+		It("honors the backend and listener filters", func() {
+			resp := deleteSessions("?clientCIDR=0.0.0.0/0&listener=active")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			Expect(sessionIDs(backend0Bridges.AsJSON())).To(Equal([]string{"2"}))
+			Expect(sessionIDs(backend1Bridges.AsJSON())).To(BeEmpty())
+		})
+
+		It("audits the operation with the caller's username", func() {
+			deleteSessions("?clientCIDR=192.168.0.0/16")
+
+			logContents := string(testLogger.Buffer().Contents())
+			Expect(logContents).To(ContainSubstring("Severing sessions via API"))
+			Expect(logContents).To(ContainSubstring(`"user":"some-operator"`))
+			Expect(logContents).To(ContainSubstring("192.168.0.3:50000"))
+		})
+
+		It("records the severed sessions of each backend and listener in the event log", func() {
+			deleteSessions("?clientCIDR=0.0.0.0/0")
+
+			severedEvents := eventLog.Between(time.Time{}, time.Time{})
+			Expect(severedEvents).To(HaveLen(3))
+			for i, expected := range []struct {
+				backend, listener string
+			}{{"backend-0", "active"}, {"backend-0", "inactive"}, {"backend-1", "active"}} {
+				Expect(severedEvents[i].Kind).To(Equal(events.KindSessionsSevered))
+				Expect(severedEvents[i].Backend).To(Equal(expected.backend))
+				Expect(severedEvents[i].Listener).To(Equal(expected.listener))
+				Expect(severedEvents[i].Sessions).To(BeNumerically("==", 1))
+				Expect(severedEvents[i].Reason).To(Equal(events.SeverReasonOperator))
+			}
+		})
+
+		Context("when clientCIDR is missing", func() {
+			It("returns 400 - Bad request", func() {
+				Expect(deleteSessions("").StatusCode).To(Equal(http.StatusBadRequest))
+				Expect(backend0Bridges.RemoveAndCloseMatchingCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when clientCIDR is unparsable", func() {
+			It("returns 400 - Bad request", func() {
+				Expect(deleteSessions("?clientCIDR=not-a-cidr").StatusCode).To(Equal(http.StatusBadRequest))
+				Expect(backend0Bridges.RemoveAndCloseMatchingCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("POST", func() {
+		It("returns http 405 - Method not allowed", func() {
+			resp, err := http.Post(server.URL(), "application/json", nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+})
+
+var _ = Describe("SessionEndpoint", func() {
+	var (
+		bridges    *domainfakes.FakeBridges
+		eventLog   *events.Log
+		testLogger *lagertest.TestLogger
+
+		server *ghttp.Server
+	)
+
+	BeforeEach(func() {
+		testLogger = lagertest.NewTestLogger("Session test")
+
+		bridges = new(domainfakes.FakeBridges)
+		fakeSessions(bridges, []domain.SessionJSON{
+			{ID: "1", Listener: "active"},
+			{ID: "2", Listener: "active"},
+		})
+
+		domain.BridgesProvider = func(lager.Logger) domain.Bridges {
+			return bridges
+		}
+		backend := domain.NewBackend("backend-0", "backend-0-host", 3306, 9200, "status", testLogger)
+
+		var err error
+		eventLog, err = events.NewLog(10, "", testLogger)
+		Expect(err).NotTo(HaveOccurred())
+
+		server = ghttp.NewServer()
+		server.AppendHandlers(api.SessionEndpoint([]*domain.Backend{backend}, eventLog, testLogger).ServeHTTP)
+	})
+
+	AfterEach(func() {
+		domain.BridgesProvider = domain.NewBridges
+		server.Close()
+	})
+
+	Describe("DELETE", func() {
+		It("severs only the given session", func() {
+			req, err := http.NewRequest("DELETE", server.URL()+"/v0/sessions/2", nil)
+			Expect(err).NotTo(HaveOccurred())
+			req.SetBasicAuth("some-operator", "some-password")
+
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			var severed []domain.SessionJSON
+			Expect(json.NewDecoder(resp.Body).Decode(&severed)).To(Succeed())
+			Expect(severed).To(HaveLen(1))
+			Expect(severed[0].ID).To(Equal("2"))
+			Expect(severed[0].Backend).To(Equal("backend-0"))
+
+			Expect(bridges.AsJSON()).To(Equal([]domain.SessionJSON{{ID: "1", Listener: "active"}}))
+			Expect(string(testLogger.Buffer().Contents())).To(ContainSubstring(`"user":"some-operator"`))
+
+			severedEvents := eventLog.Between(time.Time{}, time.Time{})
+			Expect(severedEvents).To(HaveLen(1))
+			Expect(severedEvents[0].Backend).To(Equal("backend-0"))
+			Expect(severedEvents[0].Listener).To(Equal("active"))
+			Expect(severedEvents[0].Sessions).To(BeNumerically("==", 1))
+			Expect(severedEvents[0].Reason).To(Equal(events.SeverReasonOperator))
+		})
+
+		Context("when the session does not exist", func() {
+			It("returns 404 - Not found", func() {
+				req, err := http.NewRequest("DELETE", server.URL()+"/v0/sessions/unknown", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				resp, err := http.DefaultClient.Do(req)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+			})
+		})
+	})
+
+	Describe("GET", func() {
+		It("returns http 405 - Method not allowed", func() {
+			resp, err := http.Get(server.URL() + "/v0/sessions/1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+})
+
+// fakeSessions makes the fake bridges behave like a real collection of the
+// given sessions, so that severed sessions disappear from later listings.
+func fakeSessions(bridges *domainfakes.FakeBridges, sessions []domain.SessionJSON) {
+	bridges.AsJSONStub = func() []domain.SessionJSON {
+		return sessions
+	}
+	bridges.RemoveAndCloseMatchingStub = func(matches func(domain.SessionJSON) bool) []domain.SessionJSON {
+		var closed, remaining []domain.SessionJSON
+		for _, s := range sessions {
+			if matches(s) {
+				closed = append(closed, s)
+			} else {
+				remaining = append(remaining, s)
+			}
+		}
+		sessions = remaining
+		return closed
+	}
+}
@@ -1,5 +1,6 @@
 require('babel/polyfill');
 var Backends = require('./backends');
+var Events = require('./events');
 var React = require('react/addons');
 var Layout = require('../../serve/components/layout');
 var request = require('superagent');
@@ -9,15 +10,45 @@ var cx = React.addons.classSet;
 
 var Application = React.createClass({
   getInitialState() {
-    return {backends: []}
+    return {backends: [], events: []}
   },
 
   statics: {
-    POLL_INTERVAL: 1 * 1000
+    // changes in health and active backend are pushed over the event
+    // stream; polling only keeps the session counts current
+    POLL_INTERVAL: 10 * 1000,
+    FALLBACK_POLL_INTERVAL: 1 * 1000,
+    MAX_EVENTS: 1000,
+    EVENT_KINDS: ['backendHealthy', 'backendUnhealthy', 'activeBackendChanged', 'trafficEnabled', 'trafficDisabled', 'sessionsSevered']
   },
 
   componentDidMount() {
-    this.pollBackends();
+    this.updateEvents();
+    if (typeof EventSource === 'undefined') {
+      this.pollBackends(Application.FALLBACK_POLL_INTERVAL);
+      return;
+    }
+    this.streamEvents();
+    this.pollBackends(Application.POLL_INTERVAL);
+  },
+
+  componentWillUnmount() {
+    if (this.eventSource) this.eventSource.close();
+  },
+
+  streamEvents() {
+    this.eventSource = new EventSource('/v0/events/stream');
+    Application.EVENT_KINDS.forEach(function(kind) {
+      this.eventSource.addEventListener(kind, this.receiveEvent);
+    }, this);
+  },
+
+  receiveEvent({data}) {
+    var event = JSON.parse(data);
+    this.setState({events: this.state.events.concat([event]).slice(-Application.MAX_EVENTS)});
+    if (event.kind !== 'trafficEnabled' && event.kind !== 'trafficDisabled') {
+      this.updateBackends();
+    }
   },
 
   updateBackends() {
@@ -29,13 +60,22 @@ var Application = React.createClass({
       }.bind(this));
   },
 
-  pollBackends() {
+  updateEvents() {
+    request.get('/v0/events')
+      .accept('json')
+      .end(function(err, {body: events}) {
+        if (err) return;
+        this.setState({events});
+      }.bind(this));
+  },
+
+  pollBackends(interval) {
     this.updateBackends();
-    setCorrectingInterval(this.updateBackends, Application.POLL_INTERVAL);
+    setCorrectingInterval(this.updateBackends, interval);
   },
 
   render() {
-    var {backends} = this.state;
+    var {backends, events} = this.state;
     var healthyCount = backends && backends.reduce((memo, b) => memo + (b.healthy ? 1 : 0), 0);
     var healthy = healthyCount === backends.length;
     var healthText = healthy ? 'All nodes are healthy!' : `${backends.length - healthyCount} out of ${backends.length} nodes are unhealthy.`;
@@ -72,6 +112,11 @@ var Application = React.createClass({
                 <Backends backends={backends}/>
               </div>
             </div>
+            <div className="row mtxl">
+              <div className="col-sm-24 mtl">
+                <Events events={events}/>
+              </div>
+            </div>
           </div>
         </div>
       </div>
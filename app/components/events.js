@@ -0,0 +1,65 @@
+var React = require('react/addons');
+
+var cx = React.addons.classSet;
+var types = React.PropTypes;
+
+function describe(event) {
+  var {kind, listener, backend, previousBackend, reason, message, sessions} = event;
+  switch (kind) {
+    case 'backendHealthy':
+      return `${backend} became healthy`;
+    case 'backendUnhealthy':
+      return `${backend} became unhealthy`;
+    case 'activeBackendChanged':
+      var from = previousBackend || 'none';
+      var to = backend || 'none';
+      return `${listener} listener moved from ${from} to ${to} (${reason})`;
+    case 'trafficEnabled':
+      return `Traffic enabled${message ? `: ${message}` : ''}`;
+    case 'trafficDisabled':
+      return `Traffic disabled${message ? `: ${message}` : ''}`;
+    case 'sessionsSevered':
+      return `Severed ${sessions} ${listener ? `${listener} ` : ''}sessions to ${backend} (${reason})`;
+    default:
+      return kind;
+  }
+}
+
+var Events = React.createClass({
+  propTypes: {
+    events: types.array.isRequired
+  },
+
+  renderEvents() {
+    var {events} = this.props;
+    return events.slice().reverse().map(function(event, i) {
+      var warning = ['backendUnhealthy', 'trafficDisabled', 'sessionsSevered'].indexOf(event.kind) !== -1;
+      return (
+        <li className="media mvm" key={i}>
+          <div className="media-left">
+            <i className={cx({'fa fa-fw': true, 'fa-circle type-brand-4': !warning, 'fa-exclamation-circle type-error-2': warning})}></i>
+          </div>
+          <div className="media-body">
+            <span className="type-neutral-4 mrm">{new Date(event.time).toLocaleString()}</span>
+            {describe(event)}
+          </div>
+        </li>
+      );
+    });
+  },
+
+  render() {
+    return (
+      <div>
+        <h5 className="em-max mlm">Events</h5>
+        <ul className="list-unstyled timeline mlm">
+        {this.renderEvents()}
+        </ul>
+      </div>
+    );
+  }
+});
+
+Events.describe = describe;
+
+module.exports = Events;
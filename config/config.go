@@ -1,32 +1,73 @@
 package config
 
 import (
+	"crypto/tls"
 	"errors"
 	"flag"
 	"fmt"
+	"net"
+	"net/url"
 	"time"
 
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagerflags"
+	"github.com/cloudfoundry-incubator/switchboard/events"
 
 	"github.com/pivotal-cf-experimental/service-config"
 	"gopkg.in/validator.v2"
 )
 
 type Config struct {
-	Proxy      Proxy  `yaml:"Proxy" validate:"nonzero"`
-	API        API    `yaml:"API" validate:"nonzero"`
-	StaticDir  string `yaml:"StaticDir" validate:"nonzero"`
-	HealthPort uint   `yaml:"HealthPort" validate:"nonzero"`
-	Logger     lager.Logger
+	Proxy         Proxy         `yaml:"Proxy" validate:"nonzero"`
+	API           API           `yaml:"API" validate:"nonzero"`
+	StaticDir     string        `yaml:"StaticDir" validate:"nonzero"`
+	HealthPort    uint          `yaml:"HealthPort" validate:"nonzero"`
+	Metrics       Metrics       `yaml:"Metrics"`
+	Events        Events        `yaml:"Events"`
+	Notifications Notifications `yaml:"Notifications"`
+	Logger        lager.Logger
 }
 
 type Proxy struct {
-	Port                     uint      `yaml:"Port" validate:"nonzero"`
-	InactiveMysqlPort        uint      `yaml:"InactiveMysqlPort"`
-	Backends                 []Backend `yaml:"Backends" validate:"min=1"`
-	HealthcheckTimeoutMillis uint      `yaml:"HealthcheckTimeoutMillis" validate:"nonzero"`
-	ShutdownDelaySeconds     uint      `yaml:"ShutdownDelaySeconds"`
+	Port                      uint      `yaml:"Port" validate:"nonzero"`
+	InactiveMysqlPort         uint      `yaml:"InactiveMysqlPort"`
+	Backends                  []Backend `yaml:"Backends" validate:"min=1"`
+	HealthcheckTimeoutMillis  uint      `yaml:"HealthcheckTimeoutMillis" validate:"nonzero"`
+	ShutdownDelaySeconds      uint      `yaml:"ShutdownDelaySeconds"`
+	DrainTimeoutSeconds       uint      `yaml:"DrainTimeoutSeconds"`
+	HealthcheckRise           uint      `yaml:"HealthcheckRise"`
+	HealthcheckFall           uint      `yaml:"HealthcheckFall"`
+	HealthcheckIntervalMillis uint      `yaml:"HealthcheckIntervalMillis"`
+	HealthcheckJitterMillis   uint      `yaml:"HealthcheckJitterMillis"`
+	FlapThreshold             uint      `yaml:"FlapThreshold"`
+	FlapWindowSeconds         uint      `yaml:"FlapWindowSeconds"`
+	FlapPenaltySeconds        uint      `yaml:"FlapPenaltySeconds"`
+	PassiveErrorThreshold     uint      `yaml:"PassiveErrorThreshold"`
+	PassiveErrorWindowSeconds uint      `yaml:"PassiveErrorWindowSeconds"`
+	ActiveListener            Listener  `yaml:"ActiveListener"`
+	InactiveListener          Listener  `yaml:"InactiveListener"`
+}
+
+type Listener struct {
+	IdleTimeoutSeconds        uint     `yaml:"IdleTimeoutSeconds"`
+	MaxSessionLifetimeSeconds uint     `yaml:"MaxSessionLifetimeSeconds"`
+	MaxConnections            uint     `yaml:"MaxConnections"`
+	MaxConnectionsPerClientIP uint     `yaml:"MaxConnectionsPerClientIP"`
+	NewConnectionsPerSecond   uint     `yaml:"NewConnectionsPerSecond"`
+	NewConnectionBurst        uint     `yaml:"NewConnectionBurst"`
+	AcceptProxyProtocol       bool     `yaml:"AcceptProxyProtocol"`
+	ProxyProtocolTrustedCIDRs []string `yaml:"ProxyProtocolTrustedCIDRs"`
+	TLSCertificatePath        string   `yaml:"TLSCertificatePath"`
+	TLSPrivateKeyPath         string   `yaml:"TLSPrivateKeyPath"`
+	TLSClientCAPath           string   `yaml:"TLSClientCAPath"`
+	RejectWithMySQLError      bool     `yaml:"RejectWithMySQLError"`
+	MySQLErrorCode            uint16   `yaml:"MySQLErrorCode"`
+	MySQLErrorMessage         string   `yaml:"MySQLErrorMessage"`
+	ConnectTimeoutMillis      uint     `yaml:"ConnectTimeoutMillis"`
+	ConnectRetries            uint     `yaml:"ConnectRetries"`
+	SelectionPolicy           string   `yaml:"SelectionPolicy"`
+	PreferredBackends         []string `yaml:"PreferredBackends"`
+	Failback                  string   `yaml:"Failback"`
 }
 
 type API struct {
@@ -38,22 +79,178 @@ type API struct {
 	ProxyURIs      []string `yaml:"ProxyURIs"`
 }
 
+// Metrics configures pushing metrics to a StatsD agent, in addition to
+// serving them for Prometheus. StatsdAddress is the agent's UDP host:port;
+// leaving it blank disables the emitter.
+type Metrics struct {
+	StatsdAddress              string `yaml:"StatsdAddress"`
+	StatsdPrefix               string `yaml:"StatsdPrefix"`
+	StatsdFormat               string `yaml:"StatsdFormat"`
+	StatsdFlushIntervalSeconds uint   `yaml:"StatsdFlushIntervalSeconds"`
+}
+
+// Events configures the history of health changes, active backend changes,
+// traffic changes and severed sessions served at /v0/events. Capacity is how
+// many events are kept, 1000 by default. With a PersistPath the events are
+// also written to that file and read back on restart.
+type Events struct {
+	Capacity    uint   `yaml:"Capacity"`
+	PersistPath string `yaml:"PersistPath"`
+}
+
+// Notifications configures webhooks that are sent each event as it is
+// recorded, e.g. to page on-call when the active backend changes. A failed
+// delivery is retried up to MaxAttempts times in all, 5 by default, waiting
+// RetryBackoffMillis, 1000 by default, and doubling the wait after each
+// attempt. Each webhook queues up to QueueSize events, 100 by default, and
+// drops new ones while its queue is full.
+type Notifications struct {
+	Webhooks           []Webhook `yaml:"Webhooks"`
+	QueueSize          uint      `yaml:"QueueSize"`
+	MaxAttempts        uint      `yaml:"MaxAttempts"`
+	RetryBackoffMillis uint      `yaml:"RetryBackoffMillis"`
+	TimeoutMillis      uint      `yaml:"TimeoutMillis"`
+}
+
+// Webhook is a URL that events are POSTed to as JSON. With a Secret the
+// body is signed with HMAC-SHA256 in the X-Switchboard-Signature header.
+// Events lists the kinds of event sent, by default activeBackendChanged,
+// trafficEnabled and trafficDisabled.
+type Webhook struct {
+	URL    string   `yaml:"URL" validate:"nonzero"`
+	Secret string   `yaml:"Secret" json:"-"`
+	Events []string `yaml:"Events"`
+}
+
+// Backend is a node that clients are proxied to. With EnableTLS the proxy
+// starts a TLS handshake as soon as it has connected, so the backend must
+// accept TLS from the first byte, e.g. behind a TLS terminator. A stock
+// MySQL or Galera node negotiates TLS inside its own protocol, after its
+// greeting, and cannot be reached with EnableTLS.
 type Backend struct {
-	Host           string `yaml:"Host" validate:"nonzero"`
-	Port           uint   `yaml:"Port" validate:"nonzero"`
-	StatusPort     uint   `yaml:"StatusPort" validate:"nonzero"`
-	StatusEndpoint string `yaml:"StatusEndpoint" validate:"nonzero"`
-	Name           string `yaml:"Name" validate:"nonzero"`
+	Host               string `yaml:"Host" validate:"nonzero"`
+	Port               uint   `yaml:"Port" validate:"nonzero"`
+	StatusPort         uint   `yaml:"StatusPort"`
+	StatusEndpoint     string `yaml:"StatusEndpoint"`
+	Name               string `yaml:"Name" validate:"nonzero"`
+	SendProxyProtocol  bool   `yaml:"SendProxyProtocol"`
+	EnableTLS          bool   `yaml:"EnableTLS"`
+	TLSCAPath          string `yaml:"TLSCAPath"`
+	TLSCertificatePath string `yaml:"TLSCertificatePath"`
+	TLSPrivateKeyPath  string `yaml:"TLSPrivateKeyPath"`
+	TLSServerName      string `yaml:"TLSServerName"`
+	TLSMinVersion      string `yaml:"TLSMinVersion"`
+
+	HealthCheck         string `yaml:"HealthCheck"`
+	HealthCheckCommand  string `yaml:"HealthCheckCommand"`
+	HealthCheckUsername string `yaml:"HealthCheckUsername"`
+	HealthCheckPassword string `yaml:"HealthCheckPassword" json:"-"`
+
+	Priority uint `yaml:"Priority"`
 }
 
 func (p Proxy) HealthcheckTimeout() time.Duration {
 	return time.Duration(p.HealthcheckTimeoutMillis) * time.Millisecond
 }
 
+// HealthcheckInterval is how long each backend waits between health checks.
+// It defaults to a fifth of the health check timeout.
+func (p Proxy) HealthcheckInterval() time.Duration {
+	if p.HealthcheckIntervalMillis == 0 {
+		return p.HealthcheckTimeout() / 5
+	}
+	return time.Duration(p.HealthcheckIntervalMillis) * time.Millisecond
+}
+
+// HealthcheckJitter is the most that is randomly added to each interval, so
+// that checks of different backends do not stay in step.
+func (p Proxy) HealthcheckJitter() time.Duration {
+	return time.Duration(p.HealthcheckJitterMillis) * time.Millisecond
+}
+
 func (p Proxy) ShutdownDelay() time.Duration {
 	return time.Duration(p.ShutdownDelaySeconds) * time.Second
 }
 
+// DrainTimeout is how long sessions may stay on a healthy backend that is no
+// longer active. Zero severs them as soon as the active backend changes.
+func (p Proxy) DrainTimeout() time.Duration {
+	return time.Duration(p.DrainTimeoutSeconds) * time.Second
+}
+
+// FlapWindow is how far back health state changes are counted towards
+// FlapThreshold.
+func (p Proxy) FlapWindow() time.Duration {
+	return time.Duration(p.FlapWindowSeconds) * time.Second
+}
+
+// FlapPenalty is the longest a flapping backend is held out of selection
+// after its latest state change. It shrinks as changes slide out of
+// FlapWindow.
+func (p Proxy) FlapPenalty() time.Duration {
+	return time.Duration(p.FlapPenaltySeconds) * time.Second
+}
+
+// PassiveErrorWindow is how far back the errors clients hit on a backend are
+// counted towards PassiveErrorThreshold.
+func (p Proxy) PassiveErrorWindow() time.Duration {
+	return time.Duration(p.PassiveErrorWindowSeconds) * time.Second
+}
+
+// IdleTimeout is how long a session may go without bytes in either direction
+// before it is closed. Zero disables the timeout.
+func (l Listener) IdleTimeout() time.Duration {
+	return time.Duration(l.IdleTimeoutSeconds) * time.Second
+}
+
+// MaxSessionLifetime is how long a session may stay open regardless of
+// activity. Zero disables the limit.
+func (l Listener) MaxSessionLifetime() time.Duration {
+	return time.Duration(l.MaxSessionLifetimeSeconds) * time.Second
+}
+
+// ConnectTimeout bounds how long the proxy may spend connecting a client to a
+// backend, including retries on other backends. Zero disables the timeout.
+func (l Listener) ConnectTimeout() time.Duration {
+	return time.Duration(l.ConnectTimeoutMillis) * time.Millisecond
+}
+
+// TLSEnabled reports whether the listener terminates TLS.
+func (l Listener) TLSEnabled() bool {
+	return l.TLSCertificatePath != ""
+}
+
+// StatsdEnabled reports whether metrics are pushed to a StatsD agent.
+func (m Metrics) StatsdEnabled() bool {
+	return m.StatsdAddress != ""
+}
+
+// StatsdFlushInterval is how often metrics are pushed. It defaults to 10
+// seconds.
+func (m Metrics) StatsdFlushInterval() time.Duration {
+	if m.StatsdFlushIntervalSeconds == 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(m.StatsdFlushIntervalSeconds) * time.Second
+}
+
+// RetryBackoff is how long a failed delivery waits before its first retry.
+// It defaults to a second.
+func (n Notifications) RetryBackoff() time.Duration {
+	if n.RetryBackoffMillis == 0 {
+		return time.Second
+	}
+	return time.Duration(n.RetryBackoffMillis) * time.Millisecond
+}
+
+// Timeout bounds each delivery attempt. It defaults to 5 seconds.
+func (n Notifications) Timeout() time.Duration {
+	if n.TimeoutMillis == 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(n.TimeoutMillis) * time.Millisecond
+}
+
 func NewConfig(osArgs []string) (*Config, error) {
 	var rootConfig Config
 
@@ -91,6 +288,49 @@ func (c Config) Validate() error {
 				fmt.Sprintf("Proxy.Backends[%d].", i),
 			)
 		}
+		errString += backend.validate(fmt.Sprintf("Proxy.Backends[%d].", i))
+	}
+
+	listeners := map[string]Listener{
+		"Proxy.ActiveListener.":   c.Proxy.ActiveListener,
+		"Proxy.InactiveListener.": c.Proxy.InactiveListener,
+	}
+	backendNames := map[string]bool{}
+	for _, backend := range c.Proxy.Backends {
+		backendNames[backend.Name] = true
+	}
+	for keyPrefix, listener := range listeners {
+		errString += listener.validate(keyPrefix)
+
+		for i, name := range listener.PreferredBackends {
+			if !backendNames[name] {
+				errString += fmt.Sprintf("%sPreferredBackends[%d] : no backend is named %s\n", keyPrefix, i, name)
+			}
+		}
+	}
+
+	// the active listener sends all traffic to a single backend
+	if c.Proxy.ActiveListener.ConnectRetries > 0 {
+		errString += "Proxy.ActiveListener.ConnectRetries : must be 0, only the inactive listener can retry on other backends\n"
+	}
+
+	if c.Proxy.FlapThreshold > 0 && (c.Proxy.FlapWindowSeconds == 0 || c.Proxy.FlapPenaltySeconds == 0) {
+		errString += "Proxy.FlapThreshold : requires FlapWindowSeconds and FlapPenaltySeconds\n"
+	}
+
+	if c.Proxy.PassiveErrorThreshold > 0 && c.Proxy.PassiveErrorWindowSeconds == 0 {
+		errString += "Proxy.PassiveErrorThreshold : requires PassiveErrorWindowSeconds\n"
+	}
+
+	errString += c.Metrics.validate("Metrics.")
+
+	// validator.Validate does not work on nested arrays
+	for i, webhook := range c.Notifications.Webhooks {
+		keyPrefix := fmt.Sprintf("Notifications.Webhooks[%d].", i)
+		if webhookErr := validator.Validate(webhook); webhookErr != nil {
+			errString += formatErrorString(webhookErr, keyPrefix)
+		}
+		errString += webhook.validate(keyPrefix)
 	}
 
 	if len(errString) > 0 {
@@ -99,6 +339,171 @@ func (c Config) Validate() error {
 	return nil
 }
 
+// Selection policies selectable with Listener.SelectionPolicy. Index, the
+// default, prefers the lowest wsrep_local_index on the active listener and
+// the highest on the inactive one. Priority prefers the highest
+// Backend.Priority, Preferred follows Listener.PreferredBackends and falls
+// back to the index order, and LeastSessions keeps the current backend and
+// otherwise prefers the one with the fewest sessions.
+const (
+	SelectionPolicyIndex         = "index"
+	SelectionPolicyPriority      = "priority"
+	SelectionPolicyPreferred     = "preferred"
+	SelectionPolicyLeastSessions = "least-sessions"
+)
+
+// Failback modes selectable with Listener.Failback. Automatic, the default,
+// moves to a more preferred backend as soon as it is healthy. Sticky keeps
+// the active backend for as long as it is healthy, until failback is
+// triggered through the API.
+const (
+	FailbackAutomatic = "automatic"
+	FailbackSticky    = "sticky"
+)
+
+// StatsD formats selectable with Metrics.StatsdFormat. DogStatsD, the
+// default, sends the listener and backend as tags; StatsD appends them to
+// the metric name for agents that do not understand tags.
+const (
+	StatsdFormatDogStatsD = "dogstatsd"
+	StatsdFormatStatsD    = "statsd"
+)
+
+// TLSVersions are the accepted values of Backend.TLSMinVersion.
+var TLSVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Health checks selectable with Backend.HealthCheck. Galera, the default,
+// reads the galera-healthcheck JSON from StatusEndpoint on StatusPort; HTTP
+// only needs a 2xx status code from it. TCP connects to the backend's Port
+// and Exec runs HealthCheckCommand with /bin/sh, passing when it exits with
+// status 0. MySQL logs in to the backend's Port as HealthCheckUsername and
+// reads the Galera status variables itself.
+const (
+	HealthCheckGalera = "galera"
+	HealthCheckHTTP   = "http"
+	HealthCheckTCP    = "tcp"
+	HealthCheckExec   = "exec"
+	HealthCheckMySQL  = "mysql"
+)
+
+func (b Backend) validate(keyPrefix string) string {
+	var errString string
+
+	switch b.HealthCheck {
+	case "", HealthCheckGalera, HealthCheckHTTP:
+		if b.StatusPort == 0 {
+			errString += fmt.Sprintf("%sStatusPort : required when HealthCheck is galera or http\n", keyPrefix)
+		}
+		if b.StatusEndpoint == "" {
+			errString += fmt.Sprintf("%sStatusEndpoint : required when HealthCheck is galera or http\n", keyPrefix)
+		}
+	case HealthCheckTCP:
+	case HealthCheckExec:
+		if b.HealthCheckCommand == "" {
+			errString += fmt.Sprintf("%sHealthCheckCommand : required when HealthCheck is exec\n", keyPrefix)
+		}
+	case HealthCheckMySQL:
+		if b.HealthCheckUsername == "" {
+			errString += fmt.Sprintf("%sHealthCheckUsername : required when HealthCheck is mysql\n", keyPrefix)
+		}
+	default:
+		errString += fmt.Sprintf("%sHealthCheck : must be one of galera, http, tcp, exec or mysql\n", keyPrefix)
+	}
+
+	if (b.TLSCertificatePath == "") != (b.TLSPrivateKeyPath == "") {
+		errString += fmt.Sprintf("%sTLSCertificatePath : must be set together with TLSPrivateKeyPath\n", keyPrefix)
+	}
+	if _, ok := TLSVersions[b.TLSMinVersion]; b.TLSMinVersion != "" && !ok {
+		errString += fmt.Sprintf("%sTLSMinVersion : must be one of 1.0, 1.1, 1.2 or 1.3\n", keyPrefix)
+	}
+
+	return errString
+}
+
+func (l Listener) validate(keyPrefix string) string {
+	var errString string
+
+	if l.AcceptProxyProtocol && len(l.ProxyProtocolTrustedCIDRs) == 0 {
+		errString += fmt.Sprintf("%sProxyProtocolTrustedCIDRs : required when AcceptProxyProtocol is set\n", keyPrefix)
+	}
+	if (l.TLSCertificatePath == "") != (l.TLSPrivateKeyPath == "") {
+		errString += fmt.Sprintf("%sTLSCertificatePath : must be set together with TLSPrivateKeyPath\n", keyPrefix)
+	}
+	if l.TLSClientCAPath != "" && l.TLSCertificatePath == "" {
+		errString += fmt.Sprintf("%sTLSClientCAPath : requires TLSCertificatePath and TLSPrivateKeyPath\n", keyPrefix)
+	}
+	for i, cidr := range l.ProxyProtocolTrustedCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			errString += fmt.Sprintf("%sProxyProtocolTrustedCIDRs[%d] : %s\n", keyPrefix, i, err)
+		}
+	}
+
+	switch l.SelectionPolicy {
+	case "", SelectionPolicyIndex, SelectionPolicyPriority, SelectionPolicyLeastSessions:
+	case SelectionPolicyPreferred:
+		if len(l.PreferredBackends) == 0 {
+			errString += fmt.Sprintf("%sPreferredBackends : required when SelectionPolicy is preferred\n", keyPrefix)
+		}
+	default:
+		errString += fmt.Sprintf("%sSelectionPolicy : must be one of index, priority, preferred or least-sessions\n", keyPrefix)
+	}
+
+	switch l.Failback {
+	case "", FailbackAutomatic, FailbackSticky:
+	default:
+		errString += fmt.Sprintf("%sFailback : must be automatic or sticky\n", keyPrefix)
+	}
+
+	return errString
+}
+
+func (m Metrics) validate(keyPrefix string) string {
+	var errString string
+
+	if m.StatsdAddress != "" {
+		if _, _, err := net.SplitHostPort(m.StatsdAddress); err != nil {
+			errString += fmt.Sprintf("%sStatsdAddress : %s\n", keyPrefix, err)
+		}
+	}
+
+	switch m.StatsdFormat {
+	case "", StatsdFormatDogStatsD, StatsdFormatStatsD:
+	default:
+		errString += fmt.Sprintf("%sStatsdFormat : must be dogstatsd or statsd\n", keyPrefix)
+	}
+
+	return errString
+}
+
+func (w Webhook) validate(keyPrefix string) string {
+	var errString string
+
+	if w.URL != "" {
+		if u, err := url.Parse(w.URL); err != nil {
+			errString += fmt.Sprintf("%sURL : %s\n", keyPrefix, err)
+		} else if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errString += fmt.Sprintf("%sURL : must be an http or https URL\n", keyPrefix)
+		}
+	}
+
+	kinds := map[string]bool{}
+	for _, kind := range events.Kinds {
+		kinds[kind] = true
+	}
+	for i, kind := range w.Events {
+		if !kinds[kind] {
+			errString += fmt.Sprintf("%sEvents[%d] : no event is named %s\n", keyPrefix, i, kind)
+		}
+	}
+
+	return errString
+}
+
 func formatErrorString(err error, keyPrefix string) string {
 	errs := err.(validator.ErrorMap)
 	var errsString string
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	. "github.com/cloudfoundry-incubator/switchboard/config"
+	"github.com/cloudfoundry-incubator/switchboard/events"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -20,11 +21,99 @@ var _ = Describe("Config", func() {
 			})
 		})
 
+		Describe("HealthcheckInterval", func() {
+			It("returns interval in millis", func() {
+				Expect(Proxy{HealthcheckTimeoutMillis: 2000, HealthcheckIntervalMillis: 200}.HealthcheckInterval()).To(Equal(200 * time.Millisecond))
+			})
+
+			It("defaults to a fifth of the timeout", func() {
+				Expect(Proxy{HealthcheckTimeoutMillis: 1000}.HealthcheckInterval()).To(Equal(200 * time.Millisecond))
+			})
+		})
+
+		Describe("HealthcheckJitter", func() {
+			It("returns jitter in millis", func() {
+				Expect(Proxy{HealthcheckJitterMillis: 50}.HealthcheckJitter()).To(Equal(50 * time.Millisecond))
+			})
+		})
+
+		Describe("FlapWindow", func() {
+			It("returns window in seconds", func() {
+				Expect(Proxy{FlapWindowSeconds: 60}.FlapWindow()).To(Equal(60 * time.Second))
+			})
+		})
+
+		Describe("FlapPenalty", func() {
+			It("returns penalty in seconds", func() {
+				Expect(Proxy{FlapPenaltySeconds: 120}.FlapPenalty()).To(Equal(120 * time.Second))
+			})
+		})
+
 		Describe("ShutdownDelay", func() {
 			It("returns delay in seconds", func() {
 				Expect(Proxy{ShutdownDelaySeconds: 10}.ShutdownDelay()).To(Equal(10 * time.Second))
 			})
 		})
+
+		Describe("DrainTimeout", func() {
+			It("returns timeout in seconds", func() {
+				Expect(Proxy{DrainTimeoutSeconds: 30}.DrainTimeout()).To(Equal(30 * time.Second))
+			})
+		})
+	})
+
+	Describe("Listener methods", func() {
+		Describe("IdleTimeout", func() {
+			It("returns timeout in seconds", func() {
+				Expect(Listener{IdleTimeoutSeconds: 600}.IdleTimeout()).To(Equal(600 * time.Second))
+			})
+		})
+
+		Describe("MaxSessionLifetime", func() {
+			It("returns lifetime in seconds", func() {
+				Expect(Listener{MaxSessionLifetimeSeconds: 3600}.MaxSessionLifetime()).To(Equal(3600 * time.Second))
+			})
+		})
+
+		Describe("ConnectTimeout", func() {
+			It("returns timeout in millis", func() {
+				Expect(Listener{ConnectTimeoutMillis: 1500}.ConnectTimeout()).To(Equal(1500 * time.Millisecond))
+			})
+		})
+	})
+
+	Describe("Metrics methods", func() {
+		Describe("StatsdFlushInterval", func() {
+			It("returns interval in seconds", func() {
+				Expect(Metrics{StatsdFlushIntervalSeconds: 30}.StatsdFlushInterval()).To(Equal(30 * time.Second))
+			})
+
+			It("defaults to 10 seconds", func() {
+				Expect(Metrics{}.StatsdFlushInterval()).To(Equal(10 * time.Second))
+			})
+		})
+	})
+
+	Describe("Notifications methods", func() {
+		Describe("RetryBackoff", func() {
+			It("returns backoff in millis", func() {
+				Expect(Notifications{RetryBackoffMillis: 250}.RetryBackoff()).To(Equal(250 * time.Millisecond))
+			})
+
+			It("defaults to a second", func() {
+				Expect(Notifications{}.RetryBackoff()).To(Equal(time.Second))
+			})
+		})
+
+		Describe("Timeout", func() {
+			It("returns timeout in millis", func() {
+				Expect(Notifications{TimeoutMillis: 2000}.Timeout()).To(Equal(2 * time.Second))
+			})
+
+			It("defaults to 5 seconds", func() {
+				Expect(Notifications{}.Timeout()).To(Equal(5 * time.Second))
+			})
+		})
 	})
 
 	Describe("Validate", func() {
@@ -83,6 +172,346 @@ var _ = Describe("Config", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 
+		It("does not return an error if Proxy.DrainTimeoutSeconds is blank", func() {
+			err := test_helpers.IsOptionalField(rootConfig, "Proxy.DrainTimeoutSeconds")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("does not return an error if Proxy.HealthcheckRise is blank", func() {
+			err := test_helpers.IsOptionalField(rootConfig, "Proxy.HealthcheckRise")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("does not return an error if Proxy.HealthcheckFall is blank", func() {
+			err := test_helpers.IsOptionalField(rootConfig, "Proxy.HealthcheckFall")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("does not return an error if Proxy.HealthcheckIntervalMillis is blank", func() {
+			err := test_helpers.IsOptionalField(rootConfig, "Proxy.HealthcheckIntervalMillis")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("does not return an error if Proxy.HealthcheckJitterMillis is blank", func() {
+			err := test_helpers.IsOptionalField(rootConfig, "Proxy.HealthcheckJitterMillis")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("does not return an error if Proxy.FlapThreshold is blank", func() {
+			err := test_helpers.IsOptionalField(rootConfig, "Proxy.FlapThreshold")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("does not return an error if Proxy.PassiveErrorThreshold is blank", func() {
+			err := test_helpers.IsOptionalField(rootConfig, "Proxy.PassiveErrorThreshold")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("does not return an error if Proxy.ActiveListener is blank", func() {
+			err := test_helpers.IsOptionalField(rootConfig, "Proxy.ActiveListener")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("does not return an error if Proxy.InactiveListener is blank", func() {
+			err := test_helpers.IsOptionalField(rootConfig, "Proxy.InactiveListener")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		Context("when a backend selects a health check", func() {
+			It("returns an error if the health check is unknown", func() {
+				rootConfig.Proxy.Backends[0].HealthCheck = "ping"
+
+				err := rootConfig.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Proxy.Backends[0].HealthCheck")))
+			})
+
+			It("returns an error if an exec health check has no command", func() {
+				rootConfig.Proxy.Backends[0].HealthCheck = "exec"
+
+				err := rootConfig.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Proxy.Backends[0].HealthCheckCommand")))
+			})
+
+			It("does not return an error for an exec health check with a command", func() {
+				rootConfig.Proxy.Backends[0].HealthCheck = "exec"
+				rootConfig.Proxy.Backends[0].HealthCheckCommand = "/bin/true"
+
+				Expect(rootConfig.Validate()).To(Succeed())
+			})
+
+			It("returns an error if an http health check has no status port or endpoint", func() {
+				rootConfig.Proxy.Backends[0].HealthCheck = "http"
+				rootConfig.Proxy.Backends[0].StatusPort = 0
+				rootConfig.Proxy.Backends[0].StatusEndpoint = ""
+
+				err := rootConfig.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Proxy.Backends[0].StatusPort")))
+				Expect(err).To(MatchError(ContainSubstring("Proxy.Backends[0].StatusEndpoint")))
+			})
+
+			It("does not require a status port or endpoint for checks that do not use them", func() {
+				for _, healthCheck := range []string{"tcp", "exec", "mysql"} {
+					rootConfig.Proxy.Backends[0].HealthCheck = healthCheck
+					rootConfig.Proxy.Backends[0].HealthCheckCommand = "/bin/true"
+					rootConfig.Proxy.Backends[0].HealthCheckUsername = "monitor"
+					rootConfig.Proxy.Backends[0].StatusPort = 0
+					rootConfig.Proxy.Backends[0].StatusEndpoint = ""
+
+					Expect(rootConfig.Validate()).To(Succeed(), healthCheck)
+				}
+			})
+
+			It("returns an error if a mysql health check has no username", func() {
+				rootConfig.Proxy.Backends[0].HealthCheck = "mysql"
+
+				err := rootConfig.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Proxy.Backends[0].HealthCheckUsername")))
+			})
+		})
+
+		Context("when a backend is dialed over TLS", func() {
+			It("returns an error if the client private key is missing", func() {
+				rootConfig.Proxy.Backends[0].TLSCertificatePath = "/some/cert.pem"
+
+				err := rootConfig.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Proxy.Backends[0].TLSCertificatePath")))
+			})
+
+			It("returns an error if the minimum version is unknown", func() {
+				rootConfig.Proxy.Backends[0].TLSMinVersion = "2.0"
+
+				err := rootConfig.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Proxy.Backends[0].TLSMinVersion")))
+			})
+
+			It("does not return an error for a complete TLS configuration", func() {
+				rootConfig.Proxy.Backends[0].EnableTLS = true
+				rootConfig.Proxy.Backends[0].TLSCAPath = "/some/ca.pem"
+				rootConfig.Proxy.Backends[0].TLSCertificatePath = "/some/cert.pem"
+				rootConfig.Proxy.Backends[0].TLSPrivateKeyPath = "/some/key.pem"
+				rootConfig.Proxy.Backends[0].TLSServerName = "mysql.internal"
+				rootConfig.Proxy.Backends[0].TLSMinVersion = "1.3"
+
+				Expect(rootConfig.Validate()).To(Succeed())
+			})
+		})
+
+		Context("when a listener terminates TLS", func() {
+			It("returns an error if the private key is missing", func() {
+				rootConfig.Proxy.ActiveListener.TLSCertificatePath = "/some/cert.pem"
+
+				err := rootConfig.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Proxy.ActiveListener.TLSCertificatePath")))
+			})
+
+			It("returns an error if a client CA is given without a certificate", func() {
+				rootConfig.Proxy.ActiveListener.TLSClientCAPath = "/some/ca.pem"
+
+				err := rootConfig.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Proxy.ActiveListener.TLSClientCAPath")))
+			})
+
+			It("does not return an error for a certificate, key and client CA", func() {
+				rootConfig.Proxy.ActiveListener.TLSCertificatePath = "/some/cert.pem"
+				rootConfig.Proxy.ActiveListener.TLSPrivateKeyPath = "/some/key.pem"
+				rootConfig.Proxy.ActiveListener.TLSClientCAPath = "/some/ca.pem"
+
+				Expect(rootConfig.Validate()).To(Succeed())
+			})
+		})
+
+		Context("when a listener accepts the PROXY protocol", func() {
+			It("returns an error if no trusted CIDRs are configured", func() {
+				rootConfig.Proxy.ActiveListener.AcceptProxyProtocol = true
+
+				err := rootConfig.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Proxy.ActiveListener.ProxyProtocolTrustedCIDRs")))
+			})
+
+			It("returns an error if a trusted CIDR is unparsable", func() {
+				rootConfig.Proxy.InactiveListener.AcceptProxyProtocol = true
+				rootConfig.Proxy.InactiveListener.ProxyProtocolTrustedCIDRs = []string{"10.0.0.0/8", "not-a-cidr"}
+
+				err := rootConfig.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Proxy.InactiveListener.ProxyProtocolTrustedCIDRs[1]")))
+			})
+
+			It("does not return an error for valid trusted CIDRs", func() {
+				rootConfig.Proxy.ActiveListener.AcceptProxyProtocol = true
+				rootConfig.Proxy.ActiveListener.ProxyProtocolTrustedCIDRs = []string{"10.0.0.0/8"}
+
+				Expect(rootConfig.Validate()).To(Succeed())
+			})
+		})
+
+		Context("when a listener has a selection policy", func() {
+			It("returns an error for an unknown policy", func() {
+				rootConfig.Proxy.ActiveListener.SelectionPolicy = "random"
+
+				err := rootConfig.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Proxy.ActiveListener.SelectionPolicy")))
+			})
+
+			It("returns an error for the preferred policy without preferred backends", func() {
+				rootConfig.Proxy.InactiveListener.SelectionPolicy = "preferred"
+
+				err := rootConfig.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Proxy.InactiveListener.PreferredBackends")))
+			})
+
+			It("returns an error for a preferred backend that does not exist", func() {
+				rootConfig.Proxy.ActiveListener.SelectionPolicy = "preferred"
+				rootConfig.Proxy.ActiveListener.PreferredBackends = []string{"backend-0", "backend-9"}
+
+				err := rootConfig.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Proxy.ActiveListener.PreferredBackends[1] : no backend is named backend-9")))
+			})
+
+			It("does not return an error for a valid policy", func() {
+				rootConfig.Proxy.Backends[0].Priority = 10
+				rootConfig.Proxy.ActiveListener.SelectionPolicy = "priority"
+				rootConfig.Proxy.InactiveListener.SelectionPolicy = "preferred"
+				rootConfig.Proxy.InactiveListener.PreferredBackends = []string{"backend-0"}
+
+				Expect(rootConfig.Validate()).To(Succeed())
+			})
+		})
+
+		Context("when a listener has a failback mode", func() {
+			It("returns an error for an unknown mode", func() {
+				rootConfig.Proxy.ActiveListener.Failback = "never"
+
+				err := rootConfig.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Proxy.ActiveListener.Failback")))
+			})
+
+			It("does not return an error for sticky failback", func() {
+				rootConfig.Proxy.ActiveListener.Failback = "sticky"
+
+				Expect(rootConfig.Validate()).To(Succeed())
+			})
+		})
+
+		Context("when a listener retries connections", func() {
+			It("returns an error for the active listener", func() {
+				rootConfig.Proxy.ActiveListener.ConnectRetries = 1
+
+				err := rootConfig.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Proxy.ActiveListener.ConnectRetries")))
+			})
+
+			It("does not return an error for the inactive listener", func() {
+				rootConfig.Proxy.InactiveListener.ConnectRetries = 2
+
+				Expect(rootConfig.Validate()).To(Succeed())
+			})
+		})
+
+		Context("when flap damping is enabled", func() {
+			It("returns an error without a window and penalty", func() {
+				rootConfig.Proxy.FlapThreshold = 4
+
+				err := rootConfig.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Proxy.FlapThreshold")))
+			})
+
+			It("does not return an error with a window and penalty", func() {
+				rootConfig.Proxy.FlapThreshold = 4
+				rootConfig.Proxy.FlapWindowSeconds = 60
+				rootConfig.Proxy.FlapPenaltySeconds = 120
+
+				Expect(rootConfig.Validate()).To(Succeed())
+			})
+		})
+
+		Context("when passive error detection is enabled", func() {
+			It("returns an error without a window", func() {
+				rootConfig.Proxy.PassiveErrorThreshold = 3
+
+				err := rootConfig.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Proxy.PassiveErrorThreshold")))
+			})
+
+			It("does not return an error with a window", func() {
+				rootConfig.Proxy.PassiveErrorThreshold = 3
+				rootConfig.Proxy.PassiveErrorWindowSeconds = 30
+
+				Expect(rootConfig.Validate()).To(Succeed())
+			})
+		})
+
+		Context("when metrics are pushed to StatsD", func() {
+			It("returns an error if StatsdAddress has no port", func() {
+				rootConfig.Metrics.StatsdAddress = "127.0.0.1"
+
+				err := rootConfig.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Metrics.StatsdAddress")))
+			})
+
+			It("returns an error if StatsdFormat is unknown", func() {
+				rootConfig.Metrics.StatsdAddress = "127.0.0.1:8125"
+				rootConfig.Metrics.StatsdFormat = "graphite"
+
+				err := rootConfig.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Metrics.StatsdFormat")))
+			})
+
+			It("does not return an error with an address and format", func() {
+				rootConfig.Metrics.StatsdAddress = "127.0.0.1:8125"
+				rootConfig.Metrics.StatsdFormat = StatsdFormatStatsD
+
+				Expect(rootConfig.Validate()).To(Succeed())
+			})
+		})
+
+		Context("when webhooks are configured", func() {
+			It("returns an error if a URL is blank", func() {
+				rootConfig.Notifications.Webhooks = []Webhook{{}}
+
+				err := rootConfig.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Notifications.Webhooks[0].URL")))
+			})
+
+			It("returns an error if a URL is not http or https", func() {
+				rootConfig.Notifications.Webhooks = []Webhook{{URL: "ftp://example.com/hook"}}
+
+				err := rootConfig.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Notifications.Webhooks[0].URL : must be an http or https URL")))
+			})
+
+			It("returns an error if an event kind is unknown", func() {
+				rootConfig.Notifications.Webhooks = []Webhook{{URL: "https://example.com/hook", Events: []string{"failover"}}}
+
+				err := rootConfig.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Notifications.Webhooks[0].Events[0] : no event is named failover")))
+			})
+
+			It("does not return an error with a URL and known events", func() {
+				rootConfig.Notifications.Webhooks = []Webhook{{
+					URL:    "https://example.com/hook",
+					Secret: "secret",
+					Events: []string{events.KindActiveBackendChanged, events.KindBackendUnhealthy},
+				}}
+
+				Expect(rootConfig.Validate()).To(Succeed())
+			})
+		})
+
+		It("does not return an error if Notifications is blank", func() {
+			err := test_helpers.IsOptionalField(rootConfig, "Notifications")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("does not return an error if Events is blank", func() {
+			err := test_helpers.IsOptionalField(rootConfig, "Events")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("does not return an error if Metrics is blank", func() {
+			err := test_helpers.IsOptionalField(rootConfig, "Metrics")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
 		It("returns an error if Proxy.Backends is blank", func() {
 			err := test_helpers.IsRequiredField(rootConfig, "Proxy.Backends")
 			Expect(err).ToNot(HaveOccurred())
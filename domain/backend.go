@@ -1,19 +1,41 @@
 package domain
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
-
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"code.cloudfoundry.org/lager"
+	"github.com/cloudfoundry-incubator/switchboard/events"
 )
 
 var BridgesProvider = NewBridges
-var Dialer = net.Dial
+var Dialer = net.DialTimeout
+
+// Errors clients hit on a backend, as reported to its error observer.
+const (
+	BackendErrorDial           = "dial"
+	BackendErrorConnectTimeout = "connectTimeout"
+	BackendErrorReset          = "reset"
+)
+
+// Admin states an operator can put a backend in. Only active backends are
+// selected; drain lets the backend's sessions finish, while maintenance
+// severs them.
+const (
+	AdminStateActive      = "active"
+	AdminStateDrain       = "drain"
+	AdminStateMaintenance = "maintenance"
+)
 
 type Backend struct {
+	// accessed atomically, keep 64-bit aligned
+	bytes byteCounts
+
 	mutex          sync.RWMutex
 	host           string
 	port           uint
@@ -23,15 +45,84 @@ type Backend struct {
 	bridges        Bridges
 	name           string
 	healthy        bool
+	healthChanges  uint64
+	drains         map[string]*drain
+	closedSessions map[string]uint64
+	flapPenalty    time.Time
+	admin          adminState
+
+	sendProxyProtocol bool
+	tlsConfig         *tls.Config
+	errorObserver     func(backend *Backend, kind string)
+	eventLog          *events.Log
+}
+
+// byteCounts counts the bytes proxied by every session to the backend as
+// they are copied, so that the totals only go up as sessions come and go.
+type byteCounts struct {
+	fromClient uint64
+	toClient   uint64
+}
+
+// countingConn counts the bytes written to and read from a backend
+// connection in the backend's totals.
+type countingConn struct {
+	net.Conn
+	counts *byteCounts
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		atomic.AddUint64(&c.counts.toClient, uint64(n))
+	}
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		atomic.AddUint64(&c.counts.fromClient, uint64(n))
+	}
+	return n, err
+}
+
+type adminState struct {
+	state     string
+	message   string
+	changedBy string
+	changedAt time.Time
+}
+
+type drain struct {
+	deadline time.Time
+	timer    *time.Timer
 }
 
 type BackendJSON struct {
-	Host                string `json:"host"`
-	Port                uint   `json:"port"`
-	StatusPort          uint   `json:"status_port"`
-	Healthy             bool   `json:"healthy"`
-	Name                string `json:"name"`
-	CurrentSessionCount uint   `json:"currentSessionCount"`
+	Host                string            `json:"host"`
+	Port                uint              `json:"port"`
+	StatusPort          uint              `json:"status_port"`
+	Healthy             bool              `json:"healthy"`
+	HealthChanges       uint64            `json:"healthChanges"`
+	Name                string            `json:"name"`
+	CurrentSessionCount uint              `json:"currentSessionCount"`
+	ClosedSessions      map[string]uint64 `json:"closedSessions"`
+	BytesFromClient     uint64            `json:"bytesFromClient"`
+	BytesToClient       uint64            `json:"bytesToClient"`
+	Drains              []DrainJSON       `json:"drains"`
+	Flapping            bool              `json:"flapping"`
+	FlapPenaltyDeadline *time.Time        `json:"flapPenaltyDeadline,omitempty"`
+	AdminState          string            `json:"adminState"`
+	AdminMessage        string            `json:"adminMessage,omitempty"`
+	AdminChangedBy      string            `json:"adminChangedBy,omitempty"`
+	AdminChangedAt      *time.Time        `json:"adminChangedAt,omitempty"`
+}
+
+type DrainJSON struct {
+	Listener          string    `json:"listener"`
+	Deadline          time.Time `json:"deadline"`
+	RemainingSessions uint      `json:"remainingSessions"`
 }
 
 func NewBackend(
@@ -50,6 +141,9 @@ func NewBackend(
 		statusEndpoint: statusEndpoint,
 		logger:         logger,
 		bridges:        BridgesProvider(logger),
+		drains:         map[string]*drain{},
+		closedSessions: map[string]uint64{},
+		admin:          adminState{state: AdminStateActive},
 	}
 }
 
@@ -60,24 +154,272 @@ func (b *Backend) HealthcheckUrl() string {
 	return fmt.Sprintf("http://%s:%d/%s", b.host, b.statusPort, b.statusEndpoint)
 }
 
-func (b *Backend) Bridge(clientConn net.Conn) error {
+func (b *Backend) Bridge(clientConn net.Conn, listener Listener) error {
 	backendAddr := fmt.Sprintf("%s:%d", b.host, b.port)
 
-	backendConn, err := Dialer("tcp", backendAddr)
+	// connecting to the backend, TLS included, must finish within the
+	// listener's connect timeout
+	var connectDeadline time.Time
+	if listener.ConnectTimeout > 0 {
+		connectDeadline = time.Now().Add(listener.ConnectTimeout)
+	}
+
+	backendConn, err := Dialer("tcp", backendAddr, listener.ConnectTimeout)
 	if err != nil {
+		b.reportError(connectErrorKind(err))
 		return errors.New(fmt.Sprintf("Error establishing connection to backend: %s", err))
 	}
 
-	bridge := b.bridges.Create(clientConn, backendConn)
-	bridge.Connect()
+	if b.sendsProxyProtocol() {
+		err = writeProxyV2Header(backendConn, clientConn.RemoteAddr(), clientConn.LocalAddr())
+		if err != nil {
+			backendConn.Close()
+			b.reportError(connectErrorKind(err))
+			return errors.New(fmt.Sprintf("Error sending PROXY protocol header to backend: %s", err))
+		}
+	}
+
+	if tlsConfig := b.backendTLSConfig(); tlsConfig != nil {
+		backendConn, err = handshakeTLS(backendConn, tlsConfig, connectDeadline)
+		if err != nil {
+			b.reportError(connectErrorKind(err))
+			return errors.New(fmt.Sprintf("Error establishing TLS connection to backend: %s", err))
+		}
+	}
+
+	bridge := b.bridges.Create(clientConn, &countingConn{Conn: backendConn, counts: &b.bytes}, listener)
+	closeReason := bridge.Connect()
 	_ = b.bridges.Remove(bridge) //untested
 
+	b.recordClose(closeReason)
+	b.checkDrained(listener.Name)
+
+	if closeReason == CloseReasonBackendReset {
+		b.reportError(BackendErrorReset)
+	}
+
 	return nil
 }
 
-func (b *Backend) SeverConnections() {
-	b.logger.Info(fmt.Sprintf("Severing all connections to %s at %s:%d", b.name, b.host, b.port))
-	b.bridges.RemoveAndCloseAll()
+func connectErrorKind(err error) string {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return BackendErrorConnectTimeout
+	}
+	return BackendErrorDial
+}
+
+// SetErrorObserver has the errors clients hit on the backend reported to
+// observer, e.g. to check its health before its next interval.
+func (b *Backend) SetErrorObserver(observer func(backend *Backend, kind string)) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.errorObserver = observer
+}
+
+// SetEventLog records the backend's health changes and severed sessions in
+// the event log.
+func (b *Backend) SetEventLog(eventLog *events.Log) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.eventLog = eventLog
+}
+
+func (b *Backend) events() *events.Log {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	return b.eventLog
+}
+
+func (b *Backend) reportError(kind string) {
+	b.mutex.RLock()
+	observer := b.errorObserver
+	b.mutex.RUnlock()
+
+	if observer != nil {
+		observer(b, kind)
+	}
+}
+
+// SetSendProxyProtocol makes the backend receive a PROXY protocol v2 header
+// naming the client at the start of every bridged connection.
+func (b *Backend) SetSendProxyProtocol(send bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.sendProxyProtocol = send
+}
+
+func (b *Backend) sendsProxyProtocol() bool {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	return b.sendProxyProtocol
+}
+
+// SetTLSConfig makes the backend be dialed over TLS with the given settings,
+// or in plaintext when tlsConfig is nil.
+func (b *Backend) SetTLSConfig(tlsConfig *tls.Config) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.tlsConfig = tlsConfig
+}
+
+func (b *Backend) backendTLSConfig() *tls.Config {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	return b.tlsConfig
+}
+
+// SeverConnections closes every session to the backend, recording why in
+// the event log.
+func (b *Backend) SeverConnections(severReason string) {
+	b.logger.Info(fmt.Sprintf("Severing all connections to %s at %s:%d", b.name, b.host, b.port), lager.Data{"reason": severReason})
+	severed := b.bridges.RemoveAndCloseAll()
+	b.events().SessionsSevered(b.name, "", severed, severReason)
+}
+
+// SeverListenerSessions closes the listener's sessions to the backend,
+// leaving those from other listeners connected, and records why in the
+// event log.
+func (b *Backend) SeverListenerSessions(listener, severReason string) uint {
+	severed := b.SeverSessions(func(s SessionJSON) bool {
+		return s.Listener == listener
+	})
+	b.events().SessionsSevered(b.name, listener, uint(len(severed)), severReason)
+	return uint(len(severed))
+}
+
+// SessionCount is how many sessions the backend has, without the cost of
+// AsJSON.
+func (b *Backend) SessionCount() uint {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	return b.bridges.Size()
+}
+
+func (b *Backend) SessionsAsJSON() []SessionJSON {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	sessions := b.bridges.AsJSON()
+	for i := range sessions {
+		sessions[i].Backend = b.name
+	}
+	return sessions
+}
+
+// SeverSessions closes the sessions that match, leaving every other
+// session on the backend connected.
+func (b *Backend) SeverSessions(matches func(SessionJSON) bool) []SessionJSON {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	severed := b.bridges.RemoveAndCloseMatching(func(s SessionJSON) bool {
+		s.Backend = b.name
+		return matches(s)
+	})
+	for i := range severed {
+		severed[i].Backend = b.name
+	}
+	return severed
+}
+
+// Drain lets the listener's existing sessions carry on for up to timeout,
+// after which any that remain are severed. It is used instead of
+// SeverConnections when traffic moves away from a backend that is still
+// healthy.
+func (b *Backend) Drain(listener string, timeout time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, ok := b.drains[listener]; ok || b.unsafeSessionCount(listener) == 0 {
+		return
+	}
+
+	b.logger.Info(fmt.Sprintf("Draining %s connections to %s at %s:%d", listener, b.name, b.host, b.port), lager.Data{"timeout": timeout.String()})
+	d := &drain{deadline: time.Now().Add(timeout)}
+	// the timer may fire just as the drain is cancelled, so it only
+	// finishes the drain it was started for, never a later one
+	d.timer = time.AfterFunc(timeout, func() {
+		b.finishDrain(listener, d)
+	})
+	b.drains[listener] = d
+}
+
+// CancelDrain stops draining without severing the remaining sessions, e.g.
+// because the listener has chosen this backend again.
+func (b *Backend) CancelDrain(listener string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.unsafeStopDrain(listener)
+}
+
+// FinishDrain severs the sessions that are still draining.
+func (b *Backend) FinishDrain(listener string) {
+	b.finishDrain(listener, nil)
+}
+
+// finishDrain finishes the listener's drain if it is only, or whichever
+// drain is in progress when only is nil.
+func (b *Backend) finishDrain(listener string, only *drain) {
+	b.mutex.Lock()
+	var draining bool
+	if d, ok := b.drains[listener]; ok && (only == nil || d == only) {
+		draining = b.unsafeStopDrain(listener)
+	}
+	b.mutex.Unlock()
+
+	if !draining {
+		return
+	}
+
+	severed := b.SeverListenerSessions(listener, events.SeverReasonDrainFinished)
+	b.logger.Info(fmt.Sprintf("Severed %d %s connections still draining from %s at %s:%d", severed, listener, b.name, b.host, b.port))
+}
+
+func (b *Backend) recordClose(closeReason string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.closedSessions[closeReason]++
+}
+
+func (b *Backend) checkDrained(listener string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, ok := b.drains[listener]; ok && b.unsafeSessionCount(listener) == 0 {
+		b.unsafeStopDrain(listener)
+		b.logger.Info(fmt.Sprintf("Finished draining %s connections to %s at %s:%d", listener, b.name, b.host, b.port))
+	}
+}
+
+func (b *Backend) unsafeStopDrain(listener string) bool {
+	d, ok := b.drains[listener]
+	if !ok {
+		return false
+	}
+
+	d.timer.Stop()
+	delete(b.drains, listener)
+	return true
+}
+
+func (b *Backend) unsafeSessionCount(listener string) uint {
+	var count uint
+	for _, s := range b.bridges.AsJSON() {
+		if s.Listener == listener {
+			count++
+		}
+	}
+	return count
 }
 
 func (b *Backend) SetHealthy() {
@@ -86,8 +428,16 @@ func (b *Backend) SetHealthy() {
 	}
 
 	b.mutex.Lock()
-	defer b.mutex.Unlock()
+	changed := !b.healthy
+	if changed {
+		b.healthChanges++
+	}
 	b.healthy = true
+	b.mutex.Unlock()
+
+	if changed {
+		b.events().BackendHealthChanged(b.name, true)
+	}
 }
 
 func (b *Backend) SetUnhealthy() {
@@ -96,8 +446,85 @@ func (b *Backend) SetUnhealthy() {
 	}
 
 	b.mutex.Lock()
-	defer b.mutex.Unlock()
+	changed := b.healthy
+	if changed {
+		b.healthChanges++
+	}
 	b.healthy = false
+
+	var draining []string
+	for listener := range b.drains {
+		draining = append(draining, listener)
+	}
+	b.mutex.Unlock()
+
+	if changed {
+		b.events().BackendHealthChanged(b.name, false)
+	}
+
+	// a backend that fails while draining is severed like an active one
+	for _, listener := range draining {
+		b.FinishDrain(listener)
+	}
+}
+
+// SetFlapPenalty records until when the backend is held out of selection for
+// flapping. The zero time means it is not flapping.
+func (b *Backend) SetFlapPenalty(deadline time.Time) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.flapPenalty = deadline
+}
+
+// SetAdminState records the admin state an operator put the backend in, with
+// their reason. A backend put in maintenance has every session severed at
+// once, including those left to finish on their own and those placed there
+// by retries, which have no drain to finish.
+func (b *Backend) SetAdminState(state, message, changedBy string) error {
+	switch state {
+	case AdminStateActive, AdminStateDrain, AdminStateMaintenance:
+	default:
+		return errors.New(fmt.Sprintf("Unknown admin state %q", state))
+	}
+
+	b.mutex.Lock()
+	b.admin = adminState{
+		state:     state,
+		message:   message,
+		changedBy: changedBy,
+		changedAt: time.Now(),
+	}
+
+	if state == AdminStateMaintenance {
+		for listener := range b.drains {
+			b.unsafeStopDrain(listener)
+		}
+	}
+	b.mutex.Unlock()
+
+	b.logger.Info(fmt.Sprintf("Setting %s at %s:%d to %s", b.name, b.host, b.port, state), lager.Data{
+		"message":   message,
+		"changedBy": changedBy,
+	})
+
+	if state == AdminStateMaintenance {
+		b.SeverConnections(events.SeverReasonMaintenance)
+	}
+	return nil
+}
+
+func (b *Backend) AdminState() string {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	return b.admin.state
+}
+
+// InRotation reports whether the backend may be selected, i.e. an operator
+// has not taken it out for drain or maintenance.
+func (b *Backend) InRotation() bool {
+	return b.AdminState() == AdminStateActive
 }
 
 func (b *Backend) Healthy() bool {
@@ -110,12 +537,49 @@ func (b *Backend) Healthy() bool {
 func (b *Backend) AsJSON() BackendJSON {
 	b.mutex.RLock()
 	defer b.mutex.RUnlock()
+	drains := []DrainJSON{}
+	for listener, d := range b.drains {
+		drains = append(drains, DrainJSON{
+			Listener:          listener,
+			Deadline:          d.deadline,
+			RemainingSessions: b.unsafeSessionCount(listener),
+		})
+	}
+
+	closedSessions := map[string]uint64{}
+	for closeReason, count := range b.closedSessions {
+		closedSessions[closeReason] = count
+	}
+
+	var flapPenaltyDeadline *time.Time
+	if !b.flapPenalty.IsZero() {
+		deadline := b.flapPenalty
+		flapPenaltyDeadline = &deadline
+	}
+
+	var adminChangedAt *time.Time
+	if !b.admin.changedAt.IsZero() {
+		changedAt := b.admin.changedAt
+		adminChangedAt = &changedAt
+	}
+
 	return BackendJSON{
 		Host:                b.host,
 		Port:                b.port,
 		StatusPort:          b.statusPort,
 		Name:                b.name,
 		Healthy:             b.healthy,
+		HealthChanges:       b.healthChanges,
 		CurrentSessionCount: b.bridges.Size(),
+		ClosedSessions:      closedSessions,
+		BytesFromClient:     atomic.LoadUint64(&b.bytes.fromClient),
+		BytesToClient:       atomic.LoadUint64(&b.bytes.toClient),
+		Drains:              drains,
+		Flapping:            flapPenaltyDeadline != nil,
+		FlapPenaltyDeadline: flapPenaltyDeadline,
+		AdminState:          b.admin.state,
+		AdminMessage:        b.admin.message,
+		AdminChangedBy:      b.admin.changedBy,
+		AdminChangedAt:      adminChangedAt,
 	}
 }
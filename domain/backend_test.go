@@ -1,12 +1,16 @@
 package domain_test
 
 import (
+	"errors"
 	"net"
+	"sync"
+	"time"
 
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagertest"
 	"github.com/cloudfoundry-incubator/switchboard/domain"
 	"github.com/cloudfoundry-incubator/switchboard/domain/domainfakes"
+	"github.com/cloudfoundry-incubator/switchboard/events"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -38,31 +42,304 @@ var _ = Describe("Backend", func() {
 	})
 
 	Describe("SeverConnections", func() {
+		It("records the severed sessions in the event log", func() {
+			eventLog, err := events.NewLog(10, "", lagertest.NewTestLogger("Backend test"))
+			Expect(err).NotTo(HaveOccurred())
+			backend.SetEventLog(eventLog)
+			bridges.RemoveAndCloseAllReturns(2)
+
+			backend.SeverConnections(events.SeverReasonTrafficDisabled)
+
+			recorded := eventLog.Between(time.Time{}, time.Time{})
+			Expect(recorded).To(HaveLen(1))
+			Expect(recorded[0].Kind).To(Equal(events.KindSessionsSevered))
+			Expect(recorded[0].Backend).To(Equal("backend-0"))
+			Expect(recorded[0].Sessions).To(BeNumerically("==", 2))
+			Expect(recorded[0].Reason).To(Equal(events.SeverReasonTrafficDisabled))
+		})
+
 		It("removes and closes all bridges", func() {
-			backend.SeverConnections()
+			backend.SeverConnections(events.SeverReasonActiveBackendChanged)
 			Expect(bridges.RemoveAndCloseAllCallCount()).To(Equal(1))
 		})
 	})
 
+	Describe("SessionCount", func() {
+		It("counts the bridges without reading their sessions", func() {
+			bridges.SizeReturns(3)
+
+			Expect(backend.SessionCount()).To(BeNumerically("==", 3))
+			Expect(bridges.AsJSONCallCount()).To(Equal(0))
+		})
+	})
+
+	Describe("SessionsAsJSON", func() {
+		It("returns the sessions of every bridge, labelled with the backend name", func() {
+			bridges.AsJSONReturns([]domain.SessionJSON{
+				{ID: "1", Listener: "active"},
+				{ID: "2", Listener: "inactive"},
+			})
+
+			sessions := backend.SessionsAsJSON()
+			Expect(sessions).To(Equal([]domain.SessionJSON{
+				{ID: "1", Listener: "active", Backend: "backend-0"},
+				{ID: "2", Listener: "inactive", Backend: "backend-0"},
+			}))
+		})
+	})
+
+	Describe("SeverSessions", func() {
+		It("closes the matching sessions, matching on the backend name too", func() {
+			bridges.RemoveAndCloseMatchingStub = func(matches func(domain.SessionJSON) bool) []domain.SessionJSON {
+				var closed []domain.SessionJSON
+				for _, s := range []domain.SessionJSON{{ID: "1"}, {ID: "2"}} {
+					if matches(s) {
+						closed = append(closed, s)
+					}
+				}
+				return closed
+			}
+
+			severed := backend.SeverSessions(func(s domain.SessionJSON) bool {
+				return s.ID == "2" && s.Backend == "backend-0"
+			})
+			Expect(severed).To(Equal([]domain.SessionJSON{{ID: "2", Backend: "backend-0"}}))
+		})
+	})
+
+	Describe("SeverListenerSessions", func() {
+		It("closes only the listener's sessions and records them in the event log", func() {
+			eventLog, err := events.NewLog(10, "", lagertest.NewTestLogger("Backend test"))
+			Expect(err).NotTo(HaveOccurred())
+			backend.SetEventLog(eventLog)
+			bridges.RemoveAndCloseMatchingStub = func(matches func(domain.SessionJSON) bool) []domain.SessionJSON {
+				var closed []domain.SessionJSON
+				for _, s := range []domain.SessionJSON{{ID: "1", Listener: "active"}, {ID: "2", Listener: "inactive"}} {
+					if matches(s) {
+						closed = append(closed, s)
+					}
+				}
+				return closed
+			}
+
+			Expect(backend.SeverListenerSessions("inactive", events.SeverReasonTrafficDisabled)).To(BeNumerically("==", 1))
+
+			recorded := eventLog.Between(time.Time{}, time.Time{})
+			Expect(recorded).To(HaveLen(1))
+			Expect(recorded[0].Listener).To(Equal("inactive"))
+			Expect(recorded[0].Sessions).To(BeNumerically("==", 1))
+			Expect(recorded[0].Reason).To(Equal(events.SeverReasonTrafficDisabled))
+		})
+	})
+
+	Describe("Drain", func() {
+		var (
+			sessions []domain.SessionJSON
+			m        sync.Mutex
+		)
+
+		BeforeEach(func() {
+			sessions = []domain.SessionJSON{
+				{ID: "1", Listener: "active"},
+				{ID: "2", Listener: "inactive"},
+			}
+			bridges.AsJSONStub = func() []domain.SessionJSON {
+				m.Lock()
+				defer m.Unlock()
+				return sessions
+			}
+			bridges.RemoveAndCloseMatchingStub = func(matches func(domain.SessionJSON) bool) []domain.SessionJSON {
+				m.Lock()
+				defer m.Unlock()
+				var closed, remaining []domain.SessionJSON
+				for _, s := range sessions {
+					if matches(s) {
+						closed = append(closed, s)
+					} else {
+						remaining = append(remaining, s)
+					}
+				}
+				sessions = remaining
+				return closed
+			}
+		})
+
+		It("reports the drain progress", func() {
+			backend.Drain("active", time.Minute)
+
+			drains := backend.AsJSON().Drains
+			Expect(drains).To(HaveLen(1))
+			Expect(drains[0].Listener).To(Equal("active"))
+			Expect(drains[0].RemainingSessions).To(BeNumerically("==", 1))
+			Expect(drains[0].Deadline).To(BeTemporally("~", time.Now().Add(time.Minute), time.Second))
+		})
+
+		It("severs the listener's remaining sessions once the timeout elapses", func() {
+			backend.Drain("active", 100*time.Millisecond)
+			Expect(bridges.RemoveAndCloseMatchingCallCount()).To(Equal(0))
+
+			Eventually(bridges.RemoveAndCloseMatchingCallCount).Should(Equal(1))
+			Expect(bridges.AsJSON()).To(Equal([]domain.SessionJSON{{ID: "2", Listener: "inactive"}}))
+			Expect(backend.AsJSON().Drains).To(BeEmpty())
+		})
+
+		Context("when the listener has no sessions on the backend", func() {
+			It("does not drain", func() {
+				backend.Drain("some-other-listener", time.Minute)
+				Expect(backend.AsJSON().Drains).To(BeEmpty())
+			})
+		})
+
+		Context("when the drain is cancelled", func() {
+			It("leaves the sessions connected", func() {
+				backend.Drain("active", 100*time.Millisecond)
+				backend.CancelDrain("active")
+
+				Expect(backend.AsJSON().Drains).To(BeEmpty())
+				Consistently(bridges.RemoveAndCloseMatchingCallCount, 300*time.Millisecond).Should(Equal(0))
+			})
+		})
+
+		Context("when the backend is put in maintenance while draining", func() {
+			It("severs the draining sessions immediately", func() {
+				backend.Drain("active", time.Minute)
+
+				Expect(backend.SetAdminState(domain.AdminStateMaintenance, "upgrading", "operator")).To(Succeed())
+
+				Expect(bridges.RemoveAndCloseAllCallCount()).To(Equal(1))
+				Expect(backend.AsJSON().Drains).To(BeEmpty())
+			})
+		})
+
+		Context("when the backend becomes unhealthy while draining", func() {
+			It("severs the draining sessions immediately", func() {
+				backend.SetHealthy()
+				backend.Drain("active", time.Minute)
+
+				backend.SetUnhealthy()
+
+				Expect(bridges.RemoveAndCloseMatchingCallCount()).To(Equal(1))
+				Expect(bridges.AsJSON()).To(Equal([]domain.SessionJSON{{ID: "2", Listener: "inactive"}}))
+				Expect(backend.AsJSON().Drains).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("SetHealthy and SetUnhealthy", func() {
+		It("record each change of health state in the event log", func() {
+			eventLog, err := events.NewLog(10, "", lagertest.NewTestLogger("Backend test"))
+			Expect(err).NotTo(HaveOccurred())
+			backend.SetEventLog(eventLog)
+
+			backend.SetHealthy()
+			backend.SetHealthy()
+			backend.SetUnhealthy()
+
+			var kinds []string
+			for _, e := range eventLog.Between(time.Time{}, time.Time{}) {
+				kinds = append(kinds, e.Kind)
+			}
+			Expect(kinds).To(Equal([]string{events.KindBackendHealthy, events.KindBackendUnhealthy}))
+		})
+
+		It("count each change of health state", func() {
+			backend.SetHealthy()
+			backend.SetHealthy()
+			Expect(backend.AsJSON().HealthChanges).To(BeNumerically("==", 1))
+
+			backend.SetUnhealthy()
+			backend.SetUnhealthy()
+			backend.SetHealthy()
+			Expect(backend.AsJSON().HealthChanges).To(BeNumerically("==", 3))
+		})
+	})
+
+	Describe("SetFlapPenalty", func() {
+		It("reports the backend as flapping until the deadline is cleared", func() {
+			Expect(backend.AsJSON().Flapping).To(BeFalse())
+			Expect(backend.AsJSON().FlapPenaltyDeadline).To(BeNil())
+
+			deadline := time.Now().Add(time.Minute)
+			backend.SetFlapPenalty(deadline)
+
+			Expect(backend.AsJSON().Flapping).To(BeTrue())
+			Expect(*backend.AsJSON().FlapPenaltyDeadline).To(Equal(deadline))
+
+			backend.SetFlapPenalty(time.Time{})
+
+			Expect(backend.AsJSON().Flapping).To(BeFalse())
+			Expect(backend.AsJSON().FlapPenaltyDeadline).To(BeNil())
+		})
+	})
+
+	Describe("SetAdminState", func() {
+		It("starts out active", func() {
+			Expect(backend.AdminState()).To(Equal(domain.AdminStateActive))
+			Expect(backend.InRotation()).To(BeTrue())
+			Expect(backend.AsJSON().AdminChangedAt).To(BeNil())
+		})
+
+		It("records the state and who set it why", func() {
+			Expect(backend.SetAdminState(domain.AdminStateDrain, "upgrading", "operator")).To(Succeed())
+
+			Expect(backend.InRotation()).To(BeFalse())
+
+			j := backend.AsJSON()
+			Expect(j.AdminState).To(Equal("drain"))
+			Expect(j.AdminMessage).To(Equal("upgrading"))
+			Expect(j.AdminChangedBy).To(Equal("operator"))
+			Expect(*j.AdminChangedAt).To(BeTemporally("~", time.Now(), time.Second))
+		})
+
+		Context("when a backend being drained by an operator is put in maintenance", func() {
+			It("severs every session on it, drained or not", func() {
+				eventLog, err := events.NewLog(10, "", lagertest.NewTestLogger("Backend test"))
+				Expect(err).NotTo(HaveOccurred())
+				backend.SetEventLog(eventLog)
+				bridges.RemoveAndCloseAllReturns(1)
+
+				Expect(backend.SetAdminState(domain.AdminStateDrain, "upgrading", "operator")).To(Succeed())
+				Expect(bridges.RemoveAndCloseAllCallCount()).To(Equal(0))
+
+				Expect(backend.SetAdminState(domain.AdminStateMaintenance, "upgrading", "operator")).To(Succeed())
+				Expect(bridges.RemoveAndCloseAllCallCount()).To(Equal(1))
+
+				recorded := eventLog.Between(time.Time{}, time.Time{})
+				Expect(recorded).To(HaveLen(1))
+				Expect(recorded[0].Sessions).To(BeNumerically("==", 1))
+				Expect(recorded[0].Reason).To(Equal(events.SeverReasonMaintenance))
+			})
+		})
+
+		It("rejects an unknown state", func() {
+			Expect(backend.SetAdminState("retired", "", "operator")).To(MatchError(ContainSubstring("Unknown admin state")))
+			Expect(backend.AdminState()).To(Equal(domain.AdminStateActive))
+		})
+	})
+
 	Describe("Bridge", func() {
 		var backendConn *domainfakes.FakeConn
 		var clientConn *domainfakes.FakeConn
 
 		var dialErr error
 		var dialedProtocol, dialedAddress string
+		var dialedTimeout time.Duration
 		var bridge *domainfakes.FakeBridge
 		var connectReadyChan, disconnectChan chan interface{}
+		var listener domain.Listener
 
 		BeforeEach(func() {
 			bridge = new(domainfakes.FakeBridge)
+			listener = domain.Listener{Name: "some-listener", IdleTimeout: time.Minute, ConnectTimeout: 2 * time.Second}
 
 			connectReadyChan = make(chan interface{})
 			disconnectChan = make(chan interface{})
 
-			bridge.ConnectStub = func(connectReadyChan, disconnectChan chan interface{}) func() {
-				return func() {
+			bridge.ConnectStub = func(connectReadyChan, disconnectChan chan interface{}) func() string {
+				return func() string {
 					close(connectReadyChan)
 					<-disconnectChan
+					return domain.CloseReasonIdleTimeout
 				}
 			}(connectReadyChan, disconnectChan)
 
@@ -74,15 +351,16 @@ var _ = Describe("Backend", func() {
 			dialErr = nil
 			dialedAddress = ""
 
-			domain.Dialer = func(protocol, address string) (net.Conn, error) {
+			domain.Dialer = func(protocol, address string, timeout time.Duration) (net.Conn, error) {
 				dialedProtocol = protocol
 				dialedAddress = address
+				dialedTimeout = timeout
 				return backendConn, dialErr
 			}
 		})
 
 		AfterEach(func() {
-			domain.Dialer = net.Dial
+			domain.Dialer = net.DialTimeout
 		})
 
 		It("dials the backend address", func(done Done) {
@@ -90,7 +368,7 @@ var _ = Describe("Backend", func() {
 			defer close(disconnectChan)
 
 			go func() {
-				err := backend.Bridge(clientConn)
+				err := backend.Bridge(clientConn, listener)
 				Expect(err).NotTo(HaveOccurred())
 			}()
 
@@ -98,6 +376,7 @@ var _ = Describe("Backend", func() {
 
 			Eventually(dialedProtocol).Should(Equal("tcp"))
 			Eventually(dialedAddress).Should(Equal("1.2.3.4:3306"))
+			Eventually(dialedTimeout).Should(Equal(2 * time.Second))
 		}, 5)
 
 		It("asynchronously creates and connects to a bridge", func(done Done) {
@@ -105,26 +384,115 @@ var _ = Describe("Backend", func() {
 			defer close(disconnectChan)
 
 			go func() {
-				err := backend.Bridge(clientConn)
+				err := backend.Bridge(clientConn, listener)
 				Expect(err).NotTo(HaveOccurred())
 			}()
 
 			<-connectReadyChan
 
 			Expect(bridges.CreateCallCount()).Should(Equal(1))
-			actualClientConn, actualBackendConn := bridges.CreateArgsForCall(0)
+			actualClientConn, actualBackendConn, actualListener := bridges.CreateArgsForCall(0)
 			Expect(actualClientConn).To(Equal(clientConn))
-			Expect(actualBackendConn).To(Equal(backendConn))
+			Expect(actualListener).To(Equal(listener))
+
+			_, err := actualBackendConn.Write([]byte("hello"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backendConn.WriteCallCount()).To(Equal(1))
 
 			Expect(bridge.ConnectCallCount()).To(Equal(1))
 		}, 5)
 
+		It("does not send a PROXY protocol header by default", func(done Done) {
+			defer close(done)
+			defer close(disconnectChan)
+
+			go func() {
+				err := backend.Bridge(clientConn, listener)
+				Expect(err).NotTo(HaveOccurred())
+			}()
+
+			<-connectReadyChan
+
+			Expect(backendConn.WriteCallCount()).To(Equal(0))
+		}, 5)
+
+		Context("when the backend is sent the PROXY protocol", func() {
+			BeforeEach(func() {
+				backend.SetSendProxyProtocol(true)
+
+				clientConn.RemoteAddrReturns(&net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 51234})
+				clientConn.LocalAddrReturns(&net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 3306})
+			})
+
+			It("sends a v2 header naming the client before bridging", func(done Done) {
+				defer close(done)
+				defer close(disconnectChan)
+
+				go func() {
+					err := backend.Bridge(clientConn, listener)
+					Expect(err).NotTo(HaveOccurred())
+				}()
+
+				<-connectReadyChan
+
+				Expect(backendConn.WriteCallCount()).To(Equal(1))
+				Expect(backendConn.WriteArgsForCall(0)).To(Equal([]byte{
+					'\r', '\n', '\r', '\n', 0x00, '\r', '\n', 'Q', 'U', 'I', 'T', '\n',
+					0x21, 0x11, 0, 12,
+					203, 0, 113, 7,
+					10, 0, 0, 1,
+					0xc8, 0x22,
+					0x0c, 0xea,
+				}))
+			}, 5)
+		})
+
+		Context("when clients hit errors on the backend", func() {
+			var reported chan string
+
+			BeforeEach(func() {
+				reported = make(chan string, 1)
+				backend.SetErrorObserver(func(b *domain.Backend, kind string) {
+					Expect(b).To(Equal(backend))
+					reported <- kind
+				})
+			})
+
+			It("reports a failed dial", func() {
+				dialErr = errors.New("connection refused")
+
+				Expect(backend.Bridge(clientConn, listener)).NotTo(Succeed())
+				Expect(reported).To(Receive(Equal(domain.BackendErrorDial)))
+			})
+
+			It("reports a dial that timed out", func() {
+				dialErr = &net.OpError{Op: "dial", Net: "tcp", Err: timeoutError{}}
+
+				Expect(backend.Bridge(clientConn, listener)).NotTo(Succeed())
+				Expect(reported).To(Receive(Equal(domain.BackendErrorConnectTimeout)))
+			})
+
+			It("reports a session the backend reset", func() {
+				bridge.ConnectReturns(domain.CloseReasonBackendReset)
+
+				Expect(backend.Bridge(clientConn, listener)).To(Succeed())
+				Expect(reported).To(Receive(Equal(domain.BackendErrorReset)))
+			})
+
+			It("does not report a session closed normally", func() {
+				bridge.ConnectReturns(domain.CloseReasonClientClosed)
+
+				Expect(backend.Bridge(clientConn, listener)).To(Succeed())
+				Expect(reported).NotTo(Receive())
+			})
+		})
+
 		Context("when the bridge is disconnected", func() {
 			It("removes the bridge", func(done Done) {
 				defer close(done)
 
 				go func() {
-					err := backend.Bridge(clientConn)
+					err := backend.Bridge(clientConn, listener)
 					Expect(err).NotTo(HaveOccurred())
 				}()
 
@@ -137,6 +505,86 @@ var _ = Describe("Backend", func() {
 				Eventually(bridges.RemoveCallCount).Should(Equal(1))
 				Expect(bridges.RemoveArgsForCall(0)).To(Equal(bridge))
 			}, 5)
+
+			It("counts the reason the session was closed", func(done Done) {
+				defer close(done)
+
+				go func() {
+					err := backend.Bridge(clientConn, listener)
+					Expect(err).NotTo(HaveOccurred())
+				}()
+
+				<-connectReadyChan
+				Expect(backend.AsJSON().ClosedSessions).To(BeEmpty())
+
+				close(disconnectChan)
+
+				Eventually(func() map[string]uint64 {
+					return backend.AsJSON().ClosedSessions
+				}).Should(Equal(map[string]uint64{domain.CloseReasonIdleTimeout: 1}))
+			}, 5)
+
+			It("counts the bytes proxied by live and closed sessions", func(done Done) {
+				defer close(done)
+
+				backendConn.WriteStub = func(p []byte) (int, error) { return len(p), nil }
+				backendConn.ReadReturns(200, nil)
+
+				go func() {
+					err := backend.Bridge(clientConn, listener)
+					Expect(err).NotTo(HaveOccurred())
+				}()
+
+				<-connectReadyChan
+
+				_, actualBackendConn, _ := bridges.CreateArgsForCall(0)
+				_, err := actualBackendConn.Write(make([]byte, 10))
+				Expect(err).NotTo(HaveOccurred())
+				_, err = actualBackendConn.Read(make([]byte, 512))
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(backend.AsJSON().BytesFromClient).To(BeNumerically("==", 10))
+				Expect(backend.AsJSON().BytesToClient).To(BeNumerically("==", 200))
+
+				close(disconnectChan)
+				Eventually(bridges.RemoveCallCount).Should(Equal(1))
+
+				Expect(backend.AsJSON().BytesFromClient).To(BeNumerically("==", 10))
+				Expect(backend.AsJSON().BytesToClient).To(BeNumerically("==", 200))
+			}, 5)
+
+			It("keeps counting the bytes of severed sessions", func(done Done) {
+				defer close(done)
+				defer close(disconnectChan)
+
+				backendConn.ReadReturns(100, nil)
+
+				go func() {
+					err := backend.Bridge(clientConn, listener)
+					Expect(err).NotTo(HaveOccurred())
+				}()
+
+				<-connectReadyChan
+
+				_, actualBackendConn, _ := bridges.CreateArgsForCall(0)
+				_, err := actualBackendConn.Read(make([]byte, 512))
+				Expect(err).NotTo(HaveOccurred())
+
+				// the session is off the list before its copy loop returns
+				backend.SeverConnections(events.SeverReasonTrafficDisabled)
+				bridges.AsJSONReturns(nil)
+				Expect(backend.AsJSON().BytesToClient).To(BeNumerically("==", 100))
+
+				_, err = actualBackendConn.Read(make([]byte, 512))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(backend.AsJSON().BytesToClient).To(BeNumerically("==", 200))
+			}, 5)
 		})
 	})
 })
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/cloudfoundry-incubator/switchboard/config"
+)
+
+// BackendTLSHandshakeTimeout bounds how long a backend has to complete the
+// TLS handshake when the proxy originates TLS to it. A listener's connect
+// timeout, when shorter, bounds it too.
+var BackendTLSHandshakeTimeout = 10 * time.Second
+
+// NewBackendTLSConfig builds the TLS settings used to dial a backend, or
+// returns nil when the backend is dialed in plaintext.
+func NewBackendTLSConfig(backendConfig config.Backend) (*tls.Config, error) {
+	if !backendConfig.EnableTLS {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName: backendConfig.TLSServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = backendConfig.Host
+	}
+	if backendConfig.TLSMinVersion != "" {
+		tlsConfig.MinVersion = config.TLSVersions[backendConfig.TLSMinVersion]
+	}
+
+	if backendConfig.TLSCAPath != "" {
+		caPEM, err := ioutil.ReadFile(backendConfig.TLSCAPath)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Error loading TLS CA for backend %s: %s", backendConfig.Name, err))
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New(fmt.Sprintf("Error loading TLS CA for backend %s: no certificates found in %s", backendConfig.Name, backendConfig.TLSCAPath))
+		}
+	}
+
+	if backendConfig.TLSCertificatePath != "" {
+		certificate, err := tls.LoadX509KeyPair(backendConfig.TLSCertificatePath, backendConfig.TLSPrivateKeyPath)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Error loading TLS client certificate for backend %s: %s", backendConfig.Name, err))
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
+
+// handshakeTLS completes the TLS handshake with the backend by the earlier
+// of BackendTLSHandshakeTimeout and connectDeadline, unless the latter is
+// zero.
+func handshakeTLS(backendConn net.Conn, tlsConfig *tls.Config, connectDeadline time.Time) (net.Conn, error) {
+	tlsConn := tls.Client(backendConn, tlsConfig)
+
+	deadline := time.Now().Add(BackendTLSHandshakeTimeout)
+	if !connectDeadline.IsZero() && connectDeadline.Before(deadline) {
+		deadline = connectDeadline
+	}
+
+	err := tlsConn.SetDeadline(deadline)
+	if err == nil {
+		err = tlsConn.Handshake()
+	}
+	if err == nil {
+		err = tlsConn.SetDeadline(time.Time{})
+	}
+	if err != nil {
+		backendConn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
@@ -0,0 +1,190 @@
+package domain_test
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry-incubator/switchboard/config"
+	"github.com/cloudfoundry-incubator/switchboard/domain"
+	"github.com/cloudfoundry-incubator/switchboard/domain/domainfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Backend TLS", func() {
+	var (
+		certDir       string
+		ca            *testCertificate
+		backendConfig config.Backend
+	)
+
+	BeforeEach(func() {
+		var err error
+		certDir, err = ioutil.TempDir("", "backend-tls")
+		Expect(err).NotTo(HaveOccurred())
+
+		ca = newTestCertificate("test-ca", nil)
+		ca.writeTo(certDir, "ca")
+		newTestCertificate("proxy", ca).writeTo(certDir, "client")
+
+		backendConfig = config.Backend{
+			Name:               "backend-0",
+			Host:               "127.0.0.1",
+			EnableTLS:          true,
+			TLSCAPath:          filepath.Join(certDir, "ca.crt"),
+			TLSCertificatePath: filepath.Join(certDir, "client.crt"),
+			TLSPrivateKeyPath:  filepath.Join(certDir, "client.key"),
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(certDir)
+	})
+
+	Describe("NewBackendTLSConfig", func() {
+		It("returns nil when TLS is not enabled", func() {
+			tlsConfig, err := domain.NewBackendTLSConfig(config.Backend{Host: "127.0.0.1"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tlsConfig).To(BeNil())
+		})
+
+		It("verifies the backend host by default", func() {
+			tlsConfig, err := domain.NewBackendTLSConfig(backendConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tlsConfig.ServerName).To(Equal("127.0.0.1"))
+			Expect(tlsConfig.MinVersion).To(Equal(uint16(tls.VersionTLS12)))
+			Expect(tlsConfig.Certificates).To(HaveLen(1))
+		})
+
+		It("honors the configured server name and minimum version", func() {
+			backendConfig.TLSServerName = "mysql.internal"
+			backendConfig.TLSMinVersion = "1.3"
+
+			tlsConfig, err := domain.NewBackendTLSConfig(backendConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tlsConfig.ServerName).To(Equal("mysql.internal"))
+			Expect(tlsConfig.MinVersion).To(Equal(uint16(tls.VersionTLS13)))
+		})
+
+		It("returns an error when the CA cannot be loaded", func() {
+			backendConfig.TLSCAPath = filepath.Join(certDir, "missing.crt")
+
+			_, err := domain.NewBackendTLSConfig(backendConfig)
+			Expect(err).To(MatchError(ContainSubstring("Error loading TLS CA for backend backend-0")))
+		})
+
+		It("returns an error when the client certificate cannot be loaded", func() {
+			backendConfig.TLSPrivateKeyPath = filepath.Join(certDir, "missing.key")
+
+			_, err := domain.NewBackendTLSConfig(backendConfig)
+			Expect(err).To(MatchError(ContainSubstring("Error loading TLS client certificate for backend backend-0")))
+		})
+	})
+
+	Describe("Bridge", func() {
+		var (
+			server         net.Listener
+			clientSubjects chan string
+			bridges        *domainfakes.FakeBridges
+			backend        *domain.Backend
+		)
+
+		BeforeEach(func() {
+			newTestCertificate("127.0.0.1", ca).writeTo(certDir, "server")
+			serverCertificate, err := tls.LoadX509KeyPair(filepath.Join(certDir, "server.crt"), filepath.Join(certDir, "server.key"))
+			Expect(err).NotTo(HaveOccurred())
+
+			server, err = tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+				Certificates: []tls.Certificate{serverCertificate},
+				ClientCAs:    ca.pool(),
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			clientSubjects = make(chan string, 1)
+			go func() {
+				conn, err := server.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+
+				tlsConn := conn.(*tls.Conn)
+				if tlsConn.Handshake() == nil {
+					clientSubjects <- tlsConn.ConnectionState().PeerCertificates[0].Subject.CommonName
+				}
+			}()
+
+			bridges = new(domainfakes.FakeBridges)
+			bridges.CreateReturns(new(domainfakes.FakeBridge))
+			domain.BridgesProvider = func(lager.Logger) domain.Bridges {
+				return bridges
+			}
+
+			backendConfig.Port = uint(server.Addr().(*net.TCPAddr).Port)
+			backend = domain.NewBackend(backendConfig.Name, backendConfig.Host, backendConfig.Port, 0, "", lagertest.NewTestLogger("Backend TLS test"))
+		})
+
+		AfterEach(func() {
+			domain.BridgesProvider = domain.NewBridges
+			server.Close()
+		})
+
+		It("dials the backend over mutual TLS", func() {
+			tlsConfig, err := domain.NewBackendTLSConfig(backendConfig)
+			Expect(err).NotTo(HaveOccurred())
+			backend.SetTLSConfig(tlsConfig)
+
+			err = backend.Bridge(new(domainfakes.FakeConn), domain.Listener{Name: "active"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(clientSubjects).Should(Receive(Equal("proxy")))
+			Expect(bridges.CreateCallCount()).To(Equal(1))
+		})
+
+		Context("when the backend's certificate is not trusted", func() {
+			It("does not bridge the session", func() {
+				backendConfig.TLSCAPath = ""
+				tlsConfig, err := domain.NewBackendTLSConfig(backendConfig)
+				Expect(err).NotTo(HaveOccurred())
+				backend.SetTLSConfig(tlsConfig)
+
+				err = backend.Bridge(new(domainfakes.FakeConn), domain.Listener{Name: "active"})
+				Expect(err).To(MatchError(ContainSubstring("Error establishing TLS connection to backend")))
+				Expect(bridges.CreateCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the backend does not answer the handshake", func() {
+			It("gives up once the listener's connect timeout has passed", func() {
+				stalled, err := net.Listen("tcp", "127.0.0.1:0")
+				Expect(err).NotTo(HaveOccurred())
+				defer stalled.Close()
+				go func() {
+					conn, err := stalled.Accept()
+					if err == nil {
+						defer conn.Close()
+						conn.Read(make([]byte, 1024))
+					}
+				}()
+
+				port := uint(stalled.Addr().(*net.TCPAddr).Port)
+				backend = domain.NewBackend(backendConfig.Name, backendConfig.Host, port, 0, "", lagertest.NewTestLogger("Backend TLS test"))
+				tlsConfig, err := domain.NewBackendTLSConfig(backendConfig)
+				Expect(err).NotTo(HaveOccurred())
+				backend.SetTLSConfig(tlsConfig)
+
+				start := time.Now()
+				err = backend.Bridge(new(domainfakes.FakeConn), domain.Listener{Name: "active", ConnectTimeout: 200 * time.Millisecond})
+				Expect(err).To(MatchError(ContainSubstring("Error establishing TLS connection to backend")))
+				Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+			})
+		})
+	})
+})
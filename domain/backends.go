@@ -7,17 +7,26 @@ import (
 
 var BackendProvider = NewBackend
 
-func NewBackends(backendConfigs []config.Backend, logger lager.Logger) (backends []*Backend) {
+func NewBackends(backendConfigs []config.Backend, logger lager.Logger) (backends []*Backend, err error) {
 	for _, bc := range backendConfigs {
-		backends = append(backends, BackendProvider(
+		backend := BackendProvider(
 			bc.Name,
 			bc.Host,
 			bc.Port,
 			bc.StatusPort,
 			bc.StatusEndpoint,
 			logger,
-		))
+		)
+		backend.SetSendProxyProtocol(bc.SendProxyProtocol)
+
+		tlsConfig, err := NewBackendTLSConfig(bc)
+		if err != nil {
+			return nil, err
+		}
+		backend.SetTLSConfig(tlsConfig)
+
+		backends = append(backends, backend)
 	}
 
-	return backends
+	return backends, nil
 }
@@ -1,70 +1,204 @@
 package domain
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"code.cloudfoundry.org/lager"
 )
 
+var lastSessionID uint64
+
+// Reasons a session was closed, as returned by Bridge.Connect.
+const (
+	CloseReasonClientClosed       = "clientClosed"
+	CloseReasonBackendClosed      = "backendClosed"
+	CloseReasonBackendReset       = "backendReset"
+	CloseReasonSevered            = "severed"
+	CloseReasonIdleTimeout        = "idleTimeout"
+	CloseReasonMaxSessionLifetime = "maxSessionLifetime"
+)
+
 //go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 . Bridge
 type Bridge interface {
-	Connect()
+	Connect() (closeReason string)
 	Close()
+	AsJSON() SessionJSON
+}
+
+type SessionJSON struct {
+	ID              string    `json:"id"`
+	Listener        string    `json:"listener"`
+	Backend         string    `json:"backend"`
+	ClientAddress   string    `json:"clientAddress"`
+	BackendAddress  string    `json:"backendAddress"`
+	StartedAt       time.Time `json:"startedAt"`
+	LastActivity    time.Time `json:"lastActivity"`
+	BytesFromClient uint64    `json:"bytesFromClient"`
+	BytesToClient   uint64    `json:"bytesToClient"`
+
+	ClientCertificateSubject string `json:"clientCertificateSubject,omitempty"`
 }
 
 type bridge struct {
+	// accessed atomically, keep 64-bit aligned
+	bytesFromClient uint64
+	bytesToClient   uint64
+	lastActivity    int64
+
+	id              string
+	listener        Listener
+	startedAt       time.Time
 	done            chan struct{}
 	client, backend net.Conn
 	logger          lager.Logger
 }
 
-func NewBridge(client, backend net.Conn, logger lager.Logger) Bridge {
+func NewBridge(client, backend net.Conn, listener Listener, logger lager.Logger) Bridge {
+	now := time.Now()
+
 	return &bridge{
-		done:    make(chan struct{}),
-		client:  client,
-		backend: backend,
-		logger:  logger,
+		id:           strconv.FormatUint(atomic.AddUint64(&lastSessionID, 1), 10),
+		listener:     listener,
+		startedAt:    now,
+		lastActivity: now.UnixNano(),
+		done:         make(chan struct{}),
+		client:       client,
+		backend:      backend,
+		logger:       logger,
 	}
 }
 
-func (b bridge) Connect() {
+func (b *bridge) Connect() string {
 	b.logger.Debug(fmt.Sprintf("Session established %s", b))
-
-	defer b.logger.Debug(fmt.Sprintf("Session closed %s", b)) // defers are LIFO
 	defer b.client.Close()
 	defer b.backend.Close()
 
-	select {
-	case <-b.safeCopy(b.client, b.backend):
-	case <-b.safeCopy(b.backend, b.client):
-	case <-b.done:
+	closeReason := b.copyUntilClosed()
+	if closeReason == CloseReasonIdleTimeout || closeReason == CloseReasonMaxSessionLifetime {
+		b.logger.Info(fmt.Sprintf("Session closed %s", b), lager.Data{"reason": closeReason, "session": b.AsJSON()})
+	} else {
+		b.logger.Debug(fmt.Sprintf("Session closed %s", b), lager.Data{"reason": closeReason})
+	}
+
+	return closeReason
+}
+
+func (b *bridge) copyUntilClosed() string {
+	toClient := b.safeCopy(b.client, b.backend, &b.bytesToClient)
+	fromClient := b.safeCopy(b.backend, b.client, &b.bytesFromClient)
+
+	var idle, lifetime <-chan time.Time
+	var idleTimer *time.Timer
+	if b.listener.IdleTimeout > 0 {
+		idleTimer = time.NewTimer(b.listener.IdleTimeout)
+		defer idleTimer.Stop()
+		idle = idleTimer.C
+	}
+	if b.listener.MaxSessionLifetime > 0 {
+		lifetimeTimer := time.NewTimer(b.listener.MaxSessionLifetime - time.Since(b.startedAt))
+		defer lifetimeTimer.Stop()
+		lifetime = lifetimeTimer.C
+	}
+
+	for {
+		select {
+		case err := <-toClient:
+			if errors.Is(err, syscall.ECONNRESET) {
+				return CloseReasonBackendReset
+			}
+			return CloseReasonBackendClosed
+		case <-fromClient:
+			return CloseReasonClientClosed
+		case <-b.done:
+			return CloseReasonSevered
+		case <-lifetime:
+			return CloseReasonMaxSessionLifetime
+		case <-idle:
+			// the timer only fires once per timeout, so wait out whatever
+			// is left since the last activity before giving up
+			sinceActivity := time.Since(time.Unix(0, atomic.LoadInt64(&b.lastActivity)))
+			if sinceActivity >= b.listener.IdleTimeout {
+				return CloseReasonIdleTimeout
+			}
+			idleTimer.Reset(b.listener.IdleTimeout - sinceActivity)
+		}
 	}
 }
 
-func (b bridge) Close() {
+func (b *bridge) Close() {
 	close(b.done)
 }
 
-func (b bridge) safeCopy(from, to net.Conn) chan struct{} {
-	copyDone := make(chan struct{})
-	go func() {
-		// We don't want to capture the error because it's not meaningful -
-		// whenever a connection is closed, one half will return without error
-		// but the other half will return an error.
+func (b *bridge) AsJSON() SessionJSON {
+	return SessionJSON{
+		ID:              b.id,
+		Listener:        b.listener.Name,
+		ClientAddress:   remoteAddress(b.client),
+		BackendAddress:  remoteAddress(b.backend),
+		StartedAt:       b.startedAt,
+		LastActivity:    time.Unix(0, atomic.LoadInt64(&b.lastActivity)),
+		BytesFromClient: atomic.LoadUint64(&b.bytesFromClient),
+		BytesToClient:   atomic.LoadUint64(&b.bytesToClient),
 
-		// A more elegant solution might involve sending the error down a channel
-		// and correlating it to the (expected) closure of the other half of the
-		// channel. If it can't correlate then we have an actual error,
-		// otherwise we can safely ignore it.
-		_, _ = io.Copy(from, to)
+		ClientCertificateSubject: clientCertificateSubject(b.client),
+	}
+}
 
-		close(copyDone)
+// safeCopy copies until either side is done, then sends the error reading
+// from the source, if any. The copy error itself is not meaningful - whenever
+// a connection is closed, one half will return without error but the other
+// half will return an error - but a read that failed because the source reset
+// the connection is.
+func (b *bridge) safeCopy(from, to net.Conn, byteCount *uint64) chan error {
+	copyDone := make(chan error, 1)
+	go func() {
+		reader := &activityReader{
+			reader:    to,
+			byteCount: byteCount,
+			bridge:    b,
+		}
+		_, _ = io.Copy(from, reader)
+		copyDone <- reader.err
 	}()
 	return copyDone
 }
 
-func (b bridge) String() string {
+func (b *bridge) String() string {
 	return fmt.Sprintf("from client at %v to backend at %v", b.client.RemoteAddr(), b.backend.RemoteAddr())
 }
+
+// activityReader counts the bytes read through it and records the time of
+// the last read on the bridge, so that sessions can be inspected while live.
+// It keeps the last read error other than io.EOF.
+type activityReader struct {
+	reader    io.Reader
+	byteCount *uint64
+	bridge    *bridge
+	err       error
+}
+
+func (r *activityReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		atomic.AddUint64(r.byteCount, uint64(n))
+		atomic.StoreInt64(&r.bridge.lastActivity, time.Now().UnixNano())
+	}
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+func remoteAddress(conn net.Conn) string {
+	if conn == nil || conn.RemoteAddr() == nil {
+		return ""
+	}
+	return conn.RemoteAddr().String()
+}
@@ -3,6 +3,10 @@ package domain_test
 import (
 	"errors"
 	"io"
+	"net"
+	"os"
+	"syscall"
+	"time"
 
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagertest"
@@ -28,7 +32,7 @@ var _ = Describe("Bridge", func() {
 			backend.ReadReturns(0, io.EOF)
 			client.ReadReturns(0, io.EOF)
 
-			bridge = domain.NewBridge(client, backend, logger)
+			bridge = domain.NewBridge(client, backend, domain.Listener{Name: "some-listener"}, logger)
 		})
 
 		Context("When operating normally", func() {
@@ -84,6 +88,47 @@ var _ = Describe("Bridge", func() {
 			})
 		})
 
+		Context("when data has been forwarded", func() {
+			BeforeEach(func() {
+				clientReadCount := 0
+				client.ReadStub = func(p []byte) (int, error) {
+					if clientReadCount == 0 {
+						clientReadCount++
+						return copy(p, "select 1"), nil
+					}
+					return 0, io.EOF
+				}
+				client.WriteStub = func(p []byte) (int, error) {
+					return len(p), nil
+				}
+				backend.WriteStub = func(p []byte) (int, error) {
+					return len(p), nil
+				}
+			})
+
+			It("reports the session details and bytes transferred", func() {
+				before := time.Now()
+				go bridge.Connect()
+				defer bridge.Close()
+
+				Eventually(func() uint64 {
+					return bridge.AsJSON().BytesFromClient
+				}).Should(BeNumerically("==", len("select 1")))
+
+				session := bridge.AsJSON()
+				Expect(session.ID).NotTo(BeEmpty())
+				Expect(session.Listener).To(Equal("some-listener"))
+				Expect(session.BytesToClient).To(BeNumerically("==", 0))
+				Expect(session.LastActivity).To(BeTemporally(">=", before))
+				Expect(session.LastActivity).To(BeTemporally(">=", session.StartedAt))
+			})
+
+			It("gives each bridge a distinct ID", func() {
+				otherBridge := domain.NewBridge(client, backend, domain.Listener{Name: "some-listener"}, logger)
+				Expect(otherBridge.AsJSON().ID).NotTo(Equal(bridge.AsJSON().ID))
+			})
+		})
+
 		Context("when the client returns an error", func() {
 			BeforeEach(func() {
 				client.ReadReturns(0, errors.New("Error reading from client"))
@@ -119,6 +164,17 @@ var _ = Describe("Bridge", func() {
 			})
 		})
 
+		Context("when the backend resets the connection", func() {
+			BeforeEach(func() {
+				blockUntilClosed(client)
+				backend.ReadReturns(0, &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)})
+			})
+
+			It("reports that the backend reset the session", func() {
+				Expect(bridge.Connect()).To(Equal(domain.CloseReasonBackendReset))
+			})
+		})
+
 		Context("when the backend returns EOF", func() {
 			BeforeEach(func() {
 				backend.ReadStub = func(p []byte) (int, error) {
@@ -139,6 +195,89 @@ var _ = Describe("Bridge", func() {
 				Eventually(backend.CloseCallCount).Should(Equal(1))
 				Eventually(client.CloseCallCount).Should(Equal(1))
 			})
+
+			It("reports that the session was severed", func() {
+				blockUntilClosed(client)
+				blockUntilClosed(backend)
+
+				closeReason := make(chan string)
+				go func() {
+					closeReason <- bridge.Connect()
+				}()
+				bridge.Close()
+
+				Eventually(closeReason).Should(Receive(Equal(domain.CloseReasonSevered)))
+			})
+		})
+
+		Context("when the listener has an idle timeout", func() {
+			var testLogger *lagertest.TestLogger
+
+			BeforeEach(func() {
+				blockUntilClosed(client)
+				blockUntilClosed(backend)
+
+				testLogger = lagertest.NewTestLogger("Bridge test")
+				listener := domain.Listener{Name: "some-listener", IdleTimeout: 50 * time.Millisecond}
+				bridge = domain.NewBridge(client, backend, listener, testLogger)
+			})
+
+			It("closes the session once no bytes have flowed for the timeout", func() {
+				Expect(bridge.Connect()).To(Equal(domain.CloseReasonIdleTimeout))
+				Expect(client.CloseCallCount()).To(Equal(1))
+				Expect(backend.CloseCallCount()).To(Equal(1))
+
+				Expect(string(testLogger.Buffer().Contents())).To(ContainSubstring(`"reason":"idleTimeout"`))
+			})
+
+			It("keeps the session open while bytes are flowing", func() {
+				listener := domain.Listener{
+					Name:               "some-listener",
+					IdleTimeout:        50 * time.Millisecond,
+					MaxSessionLifetime: 300 * time.Millisecond,
+				}
+				bridge = domain.NewBridge(client, backend, listener, testLogger)
+
+				client.ReadStub = func(p []byte) (int, error) {
+					time.Sleep(10 * time.Millisecond)
+					return copy(p, "x"), nil
+				}
+				backend.WriteStub = func(p []byte) (int, error) {
+					return len(p), nil
+				}
+
+				Expect(bridge.Connect()).To(Equal(domain.CloseReasonMaxSessionLifetime))
+			})
+		})
+
+		Context("when the listener has a maximum session lifetime", func() {
+			BeforeEach(func() {
+				blockUntilClosed(client)
+				blockUntilClosed(backend)
+
+				listener := domain.Listener{Name: "some-listener", MaxSessionLifetime: 50 * time.Millisecond}
+				bridge = domain.NewBridge(client, backend, listener, logger)
+			})
+
+			It("closes the session once it has been open for the lifetime", func() {
+				Expect(bridge.Connect()).To(Equal(domain.CloseReasonMaxSessionLifetime))
+				Expect(client.CloseCallCount()).To(Equal(1))
+				Expect(backend.CloseCallCount()).To(Equal(1))
+			})
 		})
 	})
 })
+
+// blockUntilClosed makes reads on the conn wait until it is closed, like an
+// idle network connection.
+func blockUntilClosed(conn *domainfakes.FakeConn) {
+	closed := make(chan struct{})
+	conn.CloseStub = func() error {
+		close(closed)
+		return nil
+	}
+	conn.ReadStub = func([]byte) (int, error) {
+		<-closed
+		return 0, io.EOF
+	}
+}
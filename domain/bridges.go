@@ -12,11 +12,13 @@ var BridgeProvider = NewBridge
 
 //go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 . Bridges
 type Bridges interface {
-	Create(clientConn, backendConn net.Conn) Bridge
+	Create(clientConn, backendConn net.Conn, listener Listener) Bridge
 	Remove(bridge Bridge) error
-	RemoveAndCloseAll()
+	RemoveAndCloseAll() uint
+	RemoveAndCloseMatching(matches func(SessionJSON) bool) []SessionJSON
 	Size() uint
 	Contains(bridge Bridge) bool
+	AsJSON() []SessionJSON
 }
 
 type concurrentBridges struct {
@@ -31,11 +33,11 @@ func NewBridges(logger lager.Logger) Bridges {
 	}
 }
 
-func (b *concurrentBridges) Create(clientConn, backendConn net.Conn) Bridge {
+func (b *concurrentBridges) Create(clientConn, backendConn net.Conn, listener Listener) Bridge {
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 
-	bridge := BridgeProvider(clientConn, backendConn, b.logger)
+	bridge := BridgeProvider(clientConn, backendConn, listener, b.logger)
 	b.bridges = append(b.bridges, bridge)
 	return bridge
 }
@@ -55,14 +57,39 @@ func (b *concurrentBridges) Remove(bridge Bridge) error {
 	return nil
 }
 
-func (b *concurrentBridges) RemoveAndCloseAll() {
+// RemoveAndCloseAll closes every bridge and returns how many there were.
+func (b *concurrentBridges) RemoveAndCloseAll() uint {
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 
 	for _, bridge := range b.bridges {
 		bridge.Close()
 	}
+	closed := uint(len(b.bridges))
 	b.bridges = []Bridge{}
+	return closed
+}
+
+// RemoveAndCloseMatching closes every bridge whose session matches and
+// returns the sessions that were closed.
+func (b *concurrentBridges) RemoveAndCloseMatching(matches func(SessionJSON) bool) []SessionJSON {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	closed := []SessionJSON{}
+	remaining := []Bridge{}
+	for _, bridge := range b.bridges {
+		session := bridge.AsJSON()
+		if matches(session) {
+			bridge.Close()
+			closed = append(closed, session)
+		} else {
+			remaining = append(remaining, bridge)
+		}
+	}
+	b.bridges = remaining
+
+	return closed
 }
 
 func (b *concurrentBridges) Size() uint {
@@ -79,6 +106,17 @@ func (b *concurrentBridges) Contains(bridge Bridge) bool {
 	return b.unsafeContains(bridge)
 }
 
+func (b *concurrentBridges) AsJSON() []SessionJSON {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	sessions := make([]SessionJSON, 0, len(b.bridges))
+	for _, bridge := range b.bridges {
+		sessions = append(sessions, bridge.AsJSON())
+	}
+	return sessions
+}
+
 func (b *concurrentBridges) unsafeContains(bridge Bridge) bool {
 	return b.unsafeIndexOf(bridge) != -1
 }
@@ -23,9 +23,9 @@ var _ = Describe("Bridges", func() {
 	})
 
 	JustBeforeEach(func() {
-		bridge1 = bridges.Create(nil, nil)
-		bridge2 = bridges.Create(nil, nil)
-		bridge3 = bridges.Create(nil, nil)
+		bridge1 = bridges.Create(nil, nil, domain.Listener{Name: "some-listener"})
+		bridge2 = bridges.Create(nil, nil, domain.Listener{Name: "some-listener"})
+		bridge3 = bridges.Create(nil, nil, domain.Listener{Name: "some-listener"})
 	})
 
 	Describe("Concurrent operations", func() {
@@ -42,7 +42,7 @@ var _ = Describe("Bridges", func() {
 
 			go func() {
 				<-readySetGo
-				bridges.Create(nil, nil)
+				bridges.Create(nil, nil, domain.Listener{Name: "some-listener"})
 				close(doneChans[0])
 			}()
 
@@ -92,16 +92,66 @@ var _ = Describe("Bridges", func() {
 
 		Context("when the bridge cannot be found", func() {
 			It("returns an error", func() {
-				err := bridges.Remove(domain.NewBridge(new(domainfakes.FakeConn), new(domainfakes.FakeConn), nil))
+				err := bridges.Remove(domain.NewBridge(new(domainfakes.FakeConn), new(domainfakes.FakeConn), domain.Listener{Name: "some-listener"}, nil))
 				Expect(err).To(HaveOccurred())
 				Expect(err).To(MatchError("Bridge not found"))
 			})
 		})
 	})
 
+	Describe("AsJSON", func() {
+		It("returns a session for every bridge", func() {
+			sessions := bridges.AsJSON()
+			Expect(sessions).To(HaveLen(3))
+			Expect(sessions[0]).To(Equal(bridge1.AsJSON()))
+			Expect(sessions[1]).To(Equal(bridge2.AsJSON()))
+			Expect(sessions[2]).To(Equal(bridge3.AsJSON()))
+		})
+	})
+
+	Describe("RemoveAndCloseMatching", func() {
+		BeforeEach(func() {
+			domain.BridgeProvider = func(_, _ net.Conn, listener domain.Listener, logger lager.Logger) domain.Bridge {
+				bridge := new(domainfakes.FakeBridge)
+				bridge.AsJSONReturns(domain.SessionJSON{Listener: listener.Name})
+				return bridge
+			}
+		})
+
+		AfterEach(func() {
+			domain.BridgeProvider = domain.NewBridge
+		})
+
+		JustBeforeEach(func() {
+			bridge2.(*domainfakes.FakeBridge).AsJSONReturns(domain.SessionJSON{ID: "2", Listener: "some-listener"})
+		})
+
+		It("closes and removes only the matching bridges", func() {
+			closed := bridges.RemoveAndCloseMatching(func(s domain.SessionJSON) bool {
+				return s.ID == "2"
+			})
+
+			Expect(closed).To(Equal([]domain.SessionJSON{{ID: "2", Listener: "some-listener"}}))
+			Expect(bridge1.(*domainfakes.FakeBridge).CloseCallCount()).To(Equal(0))
+			Expect(bridge2.(*domainfakes.FakeBridge).CloseCallCount()).To(Equal(1))
+			Expect(bridge3.(*domainfakes.FakeBridge).CloseCallCount()).To(Equal(0))
+
+			Expect(bridges.Contains(bridge2)).To(BeFalse())
+			Expect(bridges.Size()).To(BeNumerically("==", 2))
+		})
+
+		Context("when nothing matches", func() {
+			It("returns an empty list", func() {
+				closed := bridges.RemoveAndCloseMatching(func(domain.SessionJSON) bool { return false })
+				Expect(closed).To(BeEmpty())
+				Expect(bridges.Size()).To(BeNumerically("==", 3))
+			})
+		})
+	})
+
 	Describe("RemoveAndCloseAll", func() {
 		BeforeEach(func() {
-			domain.BridgeProvider = func(_, _ net.Conn, logger lager.Logger) domain.Bridge {
+			domain.BridgeProvider = func(_, _ net.Conn, _ domain.Listener, logger lager.Logger) domain.Bridge {
 				return new(domainfakes.FakeBridge)
 			}
 		})
@@ -118,8 +168,8 @@ var _ = Describe("Bridges", func() {
 			Expect(bridge3.(*domainfakes.FakeBridge).CloseCallCount()).To(Equal(1))
 		})
 
-		It("removes all bridges", func() {
-			bridges.RemoveAndCloseAll()
+		It("removes all bridges and returns how many there were", func() {
+			Expect(bridges.RemoveAndCloseAll()).To(BeNumerically("==", 3))
 
 			Expect(bridges.Size()).To(BeNumerically("==", 0))
 		})
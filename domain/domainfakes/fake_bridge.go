@@ -8,18 +8,86 @@ import (
 )
 
 type FakeBridge struct {
+	AsJSONStub        func() domain.SessionJSON
+	asJSONMutex       sync.RWMutex
+	asJSONArgsForCall []struct {
+	}
+	asJSONReturns struct {
+		result1 domain.SessionJSON
+	}
+	asJSONReturnsOnCall map[int]struct {
+		result1 domain.SessionJSON
+	}
 	CloseStub        func()
 	closeMutex       sync.RWMutex
 	closeArgsForCall []struct {
 	}
-	ConnectStub        func()
+	ConnectStub        func() string
 	connectMutex       sync.RWMutex
 	connectArgsForCall []struct {
 	}
+	connectReturns struct {
+		result1 string
+	}
+	connectReturnsOnCall map[int]struct {
+		result1 string
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
+func (fake *FakeBridge) AsJSON() domain.SessionJSON {
+	fake.asJSONMutex.Lock()
+	ret, specificReturn := fake.asJSONReturnsOnCall[len(fake.asJSONArgsForCall)]
+	fake.asJSONArgsForCall = append(fake.asJSONArgsForCall, struct {
+	}{})
+	fake.recordInvocation("AsJSON", []interface{}{})
+	fake.asJSONMutex.Unlock()
+	if fake.AsJSONStub != nil {
+		return fake.AsJSONStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.asJSONReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeBridge) AsJSONCallCount() int {
+	fake.asJSONMutex.RLock()
+	defer fake.asJSONMutex.RUnlock()
+	return len(fake.asJSONArgsForCall)
+}
+
+func (fake *FakeBridge) AsJSONCalls(stub func() domain.SessionJSON) {
+	fake.asJSONMutex.Lock()
+	defer fake.asJSONMutex.Unlock()
+	fake.AsJSONStub = stub
+}
+
+func (fake *FakeBridge) AsJSONReturns(result1 domain.SessionJSON) {
+	fake.asJSONMutex.Lock()
+	defer fake.asJSONMutex.Unlock()
+	fake.AsJSONStub = nil
+	fake.asJSONReturns = struct {
+		result1 domain.SessionJSON
+	}{result1}
+}
+
+func (fake *FakeBridge) AsJSONReturnsOnCall(i int, result1 domain.SessionJSON) {
+	fake.asJSONMutex.Lock()
+	defer fake.asJSONMutex.Unlock()
+	fake.AsJSONStub = nil
+	if fake.asJSONReturnsOnCall == nil {
+		fake.asJSONReturnsOnCall = make(map[int]struct {
+			result1 domain.SessionJSON
+		})
+	}
+	fake.asJSONReturnsOnCall[i] = struct {
+		result1 domain.SessionJSON
+	}{result1}
+}
+
 func (fake *FakeBridge) Close() {
 	fake.closeMutex.Lock()
 	fake.closeArgsForCall = append(fake.closeArgsForCall, struct {
@@ -43,15 +111,21 @@ func (fake *FakeBridge) CloseCalls(stub func()) {
 	fake.CloseStub = stub
 }
 
-func (fake *FakeBridge) Connect() {
+func (fake *FakeBridge) Connect() string {
 	fake.connectMutex.Lock()
+	ret, specificReturn := fake.connectReturnsOnCall[len(fake.connectArgsForCall)]
 	fake.connectArgsForCall = append(fake.connectArgsForCall, struct {
 	}{})
 	fake.recordInvocation("Connect", []interface{}{})
 	fake.connectMutex.Unlock()
 	if fake.ConnectStub != nil {
-		fake.ConnectStub()
+		return fake.ConnectStub()
 	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.connectReturns
+	return fakeReturns.result1
 }
 
 func (fake *FakeBridge) ConnectCallCount() int {
@@ -60,15 +134,40 @@ func (fake *FakeBridge) ConnectCallCount() int {
 	return len(fake.connectArgsForCall)
 }
 
-func (fake *FakeBridge) ConnectCalls(stub func()) {
+func (fake *FakeBridge) ConnectCalls(stub func() string) {
 	fake.connectMutex.Lock()
 	defer fake.connectMutex.Unlock()
 	fake.ConnectStub = stub
 }
 
+func (fake *FakeBridge) ConnectReturns(result1 string) {
+	fake.connectMutex.Lock()
+	defer fake.connectMutex.Unlock()
+	fake.ConnectStub = nil
+	fake.connectReturns = struct {
+		result1 string
+	}{result1}
+}
+
+func (fake *FakeBridge) ConnectReturnsOnCall(i int, result1 string) {
+	fake.connectMutex.Lock()
+	defer fake.connectMutex.Unlock()
+	fake.ConnectStub = nil
+	if fake.connectReturnsOnCall == nil {
+		fake.connectReturnsOnCall = make(map[int]struct {
+			result1 string
+		})
+	}
+	fake.connectReturnsOnCall[i] = struct {
+		result1 string
+	}{result1}
+}
+
 func (fake *FakeBridge) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.asJSONMutex.RLock()
+	defer fake.asJSONMutex.RUnlock()
 	fake.closeMutex.RLock()
 	defer fake.closeMutex.RUnlock()
 	fake.connectMutex.RLock()
@@ -9,6 +9,16 @@ import (
 )
 
 type FakeBridges struct {
+	AsJSONStub        func() []domain.SessionJSON
+	asJSONMutex       sync.RWMutex
+	asJSONArgsForCall []struct {
+	}
+	asJSONReturns struct {
+		result1 []domain.SessionJSON
+	}
+	asJSONReturnsOnCall map[int]struct {
+		result1 []domain.SessionJSON
+	}
 	ContainsStub        func(domain.Bridge) bool
 	containsMutex       sync.RWMutex
 	containsArgsForCall []struct {
@@ -20,11 +30,12 @@ type FakeBridges struct {
 	containsReturnsOnCall map[int]struct {
 		result1 bool
 	}
-	CreateStub        func(net.Conn, net.Conn) domain.Bridge
+	CreateStub        func(net.Conn, net.Conn, domain.Listener) domain.Bridge
 	createMutex       sync.RWMutex
 	createArgsForCall []struct {
 		arg1 net.Conn
 		arg2 net.Conn
+		arg3 domain.Listener
 	}
 	createReturns struct {
 		result1 domain.Bridge
@@ -43,10 +54,27 @@ type FakeBridges struct {
 	removeReturnsOnCall map[int]struct {
 		result1 error
 	}
-	RemoveAndCloseAllStub        func()
+	RemoveAndCloseAllStub        func() uint
 	removeAndCloseAllMutex       sync.RWMutex
 	removeAndCloseAllArgsForCall []struct {
 	}
+	removeAndCloseAllReturns struct {
+		result1 uint
+	}
+	removeAndCloseAllReturnsOnCall map[int]struct {
+		result1 uint
+	}
+	RemoveAndCloseMatchingStub        func(func(domain.SessionJSON) bool) []domain.SessionJSON
+	removeAndCloseMatchingMutex       sync.RWMutex
+	removeAndCloseMatchingArgsForCall []struct {
+		arg1 func(domain.SessionJSON) bool
+	}
+	removeAndCloseMatchingReturns struct {
+		result1 []domain.SessionJSON
+	}
+	removeAndCloseMatchingReturnsOnCall map[int]struct {
+		result1 []domain.SessionJSON
+	}
 	SizeStub        func() uint
 	sizeMutex       sync.RWMutex
 	sizeArgsForCall []struct {
@@ -61,6 +89,58 @@ type FakeBridges struct {
 	invocationsMutex sync.RWMutex
 }
 
+func (fake *FakeBridges) AsJSON() []domain.SessionJSON {
+	fake.asJSONMutex.Lock()
+	ret, specificReturn := fake.asJSONReturnsOnCall[len(fake.asJSONArgsForCall)]
+	fake.asJSONArgsForCall = append(fake.asJSONArgsForCall, struct {
+	}{})
+	fake.recordInvocation("AsJSON", []interface{}{})
+	fake.asJSONMutex.Unlock()
+	if fake.AsJSONStub != nil {
+		return fake.AsJSONStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.asJSONReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeBridges) AsJSONCallCount() int {
+	fake.asJSONMutex.RLock()
+	defer fake.asJSONMutex.RUnlock()
+	return len(fake.asJSONArgsForCall)
+}
+
+func (fake *FakeBridges) AsJSONCalls(stub func() []domain.SessionJSON) {
+	fake.asJSONMutex.Lock()
+	defer fake.asJSONMutex.Unlock()
+	fake.AsJSONStub = stub
+}
+
+func (fake *FakeBridges) AsJSONReturns(result1 []domain.SessionJSON) {
+	fake.asJSONMutex.Lock()
+	defer fake.asJSONMutex.Unlock()
+	fake.AsJSONStub = nil
+	fake.asJSONReturns = struct {
+		result1 []domain.SessionJSON
+	}{result1}
+}
+
+func (fake *FakeBridges) AsJSONReturnsOnCall(i int, result1 []domain.SessionJSON) {
+	fake.asJSONMutex.Lock()
+	defer fake.asJSONMutex.Unlock()
+	fake.AsJSONStub = nil
+	if fake.asJSONReturnsOnCall == nil {
+		fake.asJSONReturnsOnCall = make(map[int]struct {
+			result1 []domain.SessionJSON
+		})
+	}
+	fake.asJSONReturnsOnCall[i] = struct {
+		result1 []domain.SessionJSON
+	}{result1}
+}
+
 func (fake *FakeBridges) Contains(arg1 domain.Bridge) bool {
 	fake.containsMutex.Lock()
 	ret, specificReturn := fake.containsReturnsOnCall[len(fake.containsArgsForCall)]
@@ -121,17 +201,18 @@ func (fake *FakeBridges) ContainsReturnsOnCall(i int, result1 bool) {
 	}{result1}
 }
 
-func (fake *FakeBridges) Create(arg1 net.Conn, arg2 net.Conn) domain.Bridge {
+func (fake *FakeBridges) Create(arg1 net.Conn, arg2 net.Conn, arg3 domain.Listener) domain.Bridge {
 	fake.createMutex.Lock()
 	ret, specificReturn := fake.createReturnsOnCall[len(fake.createArgsForCall)]
 	fake.createArgsForCall = append(fake.createArgsForCall, struct {
 		arg1 net.Conn
 		arg2 net.Conn
-	}{arg1, arg2})
-	fake.recordInvocation("Create", []interface{}{arg1, arg2})
+		arg3 domain.Listener
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("Create", []interface{}{arg1, arg2, arg3})
 	fake.createMutex.Unlock()
 	if fake.CreateStub != nil {
-		return fake.CreateStub(arg1, arg2)
+		return fake.CreateStub(arg1, arg2, arg3)
 	}
 	if specificReturn {
 		return ret.result1
@@ -146,17 +227,17 @@ func (fake *FakeBridges) CreateCallCount() int {
 	return len(fake.createArgsForCall)
 }
 
-func (fake *FakeBridges) CreateCalls(stub func(net.Conn, net.Conn) domain.Bridge) {
+func (fake *FakeBridges) CreateCalls(stub func(net.Conn, net.Conn, domain.Listener) domain.Bridge) {
 	fake.createMutex.Lock()
 	defer fake.createMutex.Unlock()
 	fake.CreateStub = stub
 }
 
-func (fake *FakeBridges) CreateArgsForCall(i int) (net.Conn, net.Conn) {
+func (fake *FakeBridges) CreateArgsForCall(i int) (net.Conn, net.Conn, domain.Listener) {
 	fake.createMutex.RLock()
 	defer fake.createMutex.RUnlock()
 	argsForCall := fake.createArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
 func (fake *FakeBridges) CreateReturns(result1 domain.Bridge) {
@@ -242,15 +323,21 @@ func (fake *FakeBridges) RemoveReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
-func (fake *FakeBridges) RemoveAndCloseAll() {
+func (fake *FakeBridges) RemoveAndCloseAll() uint {
 	fake.removeAndCloseAllMutex.Lock()
+	ret, specificReturn := fake.removeAndCloseAllReturnsOnCall[len(fake.removeAndCloseAllArgsForCall)]
 	fake.removeAndCloseAllArgsForCall = append(fake.removeAndCloseAllArgsForCall, struct {
 	}{})
 	fake.recordInvocation("RemoveAndCloseAll", []interface{}{})
 	fake.removeAndCloseAllMutex.Unlock()
 	if fake.RemoveAndCloseAllStub != nil {
-		fake.RemoveAndCloseAllStub()
+		return fake.RemoveAndCloseAllStub()
 	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.removeAndCloseAllReturns
+	return fakeReturns.result1
 }
 
 func (fake *FakeBridges) RemoveAndCloseAllCallCount() int {
@@ -259,12 +346,95 @@ func (fake *FakeBridges) RemoveAndCloseAllCallCount() int {
 	return len(fake.removeAndCloseAllArgsForCall)
 }
 
-func (fake *FakeBridges) RemoveAndCloseAllCalls(stub func()) {
+func (fake *FakeBridges) RemoveAndCloseAllCalls(stub func() uint) {
 	fake.removeAndCloseAllMutex.Lock()
 	defer fake.removeAndCloseAllMutex.Unlock()
 	fake.RemoveAndCloseAllStub = stub
 }
 
+func (fake *FakeBridges) RemoveAndCloseAllReturns(result1 uint) {
+	fake.removeAndCloseAllMutex.Lock()
+	defer fake.removeAndCloseAllMutex.Unlock()
+	fake.RemoveAndCloseAllStub = nil
+	fake.removeAndCloseAllReturns = struct {
+		result1 uint
+	}{result1}
+}
+
+func (fake *FakeBridges) RemoveAndCloseAllReturnsOnCall(i int, result1 uint) {
+	fake.removeAndCloseAllMutex.Lock()
+	defer fake.removeAndCloseAllMutex.Unlock()
+	fake.RemoveAndCloseAllStub = nil
+	if fake.removeAndCloseAllReturnsOnCall == nil {
+		fake.removeAndCloseAllReturnsOnCall = make(map[int]struct {
+			result1 uint
+		})
+	}
+	fake.removeAndCloseAllReturnsOnCall[i] = struct {
+		result1 uint
+	}{result1}
+}
+
+func (fake *FakeBridges) RemoveAndCloseMatching(arg1 func(domain.SessionJSON) bool) []domain.SessionJSON {
+	fake.removeAndCloseMatchingMutex.Lock()
+	ret, specificReturn := fake.removeAndCloseMatchingReturnsOnCall[len(fake.removeAndCloseMatchingArgsForCall)]
+	fake.removeAndCloseMatchingArgsForCall = append(fake.removeAndCloseMatchingArgsForCall, struct {
+		arg1 func(domain.SessionJSON) bool
+	}{arg1})
+	fake.recordInvocation("RemoveAndCloseMatching", []interface{}{arg1})
+	fake.removeAndCloseMatchingMutex.Unlock()
+	if fake.RemoveAndCloseMatchingStub != nil {
+		return fake.RemoveAndCloseMatchingStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.removeAndCloseMatchingReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeBridges) RemoveAndCloseMatchingCallCount() int {
+	fake.removeAndCloseMatchingMutex.RLock()
+	defer fake.removeAndCloseMatchingMutex.RUnlock()
+	return len(fake.removeAndCloseMatchingArgsForCall)
+}
+
+func (fake *FakeBridges) RemoveAndCloseMatchingCalls(stub func(func(domain.SessionJSON) bool) []domain.SessionJSON) {
+	fake.removeAndCloseMatchingMutex.Lock()
+	defer fake.removeAndCloseMatchingMutex.Unlock()
+	fake.RemoveAndCloseMatchingStub = stub
+}
+
+func (fake *FakeBridges) RemoveAndCloseMatchingArgsForCall(i int) func(domain.SessionJSON) bool {
+	fake.removeAndCloseMatchingMutex.RLock()
+	defer fake.removeAndCloseMatchingMutex.RUnlock()
+	argsForCall := fake.removeAndCloseMatchingArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeBridges) RemoveAndCloseMatchingReturns(result1 []domain.SessionJSON) {
+	fake.removeAndCloseMatchingMutex.Lock()
+	defer fake.removeAndCloseMatchingMutex.Unlock()
+	fake.RemoveAndCloseMatchingStub = nil
+	fake.removeAndCloseMatchingReturns = struct {
+		result1 []domain.SessionJSON
+	}{result1}
+}
+
+func (fake *FakeBridges) RemoveAndCloseMatchingReturnsOnCall(i int, result1 []domain.SessionJSON) {
+	fake.removeAndCloseMatchingMutex.Lock()
+	defer fake.removeAndCloseMatchingMutex.Unlock()
+	fake.RemoveAndCloseMatchingStub = nil
+	if fake.removeAndCloseMatchingReturnsOnCall == nil {
+		fake.removeAndCloseMatchingReturnsOnCall = make(map[int]struct {
+			result1 []domain.SessionJSON
+		})
+	}
+	fake.removeAndCloseMatchingReturnsOnCall[i] = struct {
+		result1 []domain.SessionJSON
+	}{result1}
+}
+
 func (fake *FakeBridges) Size() uint {
 	fake.sizeMutex.Lock()
 	ret, specificReturn := fake.sizeReturnsOnCall[len(fake.sizeArgsForCall)]
@@ -320,6 +490,8 @@ func (fake *FakeBridges) SizeReturnsOnCall(i int, result1 uint) {
 func (fake *FakeBridges) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.asJSONMutex.RLock()
+	defer fake.asJSONMutex.RUnlock()
 	fake.containsMutex.RLock()
 	defer fake.containsMutex.RUnlock()
 	fake.createMutex.RLock()
@@ -328,6 +500,8 @@ func (fake *FakeBridges) Invocations() map[string][][]interface{} {
 	defer fake.removeMutex.RUnlock()
 	fake.removeAndCloseAllMutex.RLock()
 	defer fake.removeAndCloseAllMutex.RUnlock()
+	fake.removeAndCloseMatchingMutex.RLock()
+	defer fake.removeAndCloseMatchingMutex.RUnlock()
 	fake.sizeMutex.RLock()
 	defer fake.sizeMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
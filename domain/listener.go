@@ -0,0 +1,256 @@
+package domain
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/cloudfoundry-incubator/switchboard/config"
+)
+
+// Reasons a client connection was turned away. Listener.Admit returns the
+// limits it enforces; the others are decided when the client is bridged.
+const (
+	RejectReasonMaxConnections            = "maxConnections"
+	RejectReasonMaxConnectionsPerClientIP = "maxConnectionsPerClientIP"
+	RejectReasonRateLimited               = "rateLimited"
+	RejectReasonTrafficDisabled           = "trafficDisabled"
+	RejectReasonNoHealthyBackend          = "noHealthyBackend"
+)
+
+// Listener names the proxy port a session was accepted on and carries the
+// limits enforced on that port's sessions. Zero limits are disabled.
+type Listener struct {
+	Name               string
+	IdleTimeout        time.Duration
+	MaxSessionLifetime time.Duration
+
+	// AcceptProxyProtocol expects connections from ProxyProtocolTrustedCIDRs
+	// to start with a PROXY protocol header naming the real client.
+	AcceptProxyProtocol       bool
+	ProxyProtocolTrustedCIDRs []*net.IPNet
+
+	// RejectWithMySQLError makes Reject answer clients with a MySQL ERR
+	// packet rather than just closing their connection.
+	RejectWithMySQLError bool
+	MySQLErrorCode       uint16
+	MySQLErrorMessage    string
+
+	// ConnectTimeout bounds how long dialing a backend may take, and
+	// ConnectRetries how many other healthy backends a client is offered to
+	// when that fails.
+	ConnectTimeout time.Duration
+	ConnectRetries uint
+
+	admission       *admission
+	tlsCertificates *tlsCertificates
+}
+
+type ListenerJSON struct {
+	Name                string            `json:"name"`
+	CurrentConnections  uint              `json:"currentConnections"`
+	RejectedConnections map[string]uint64 `json:"rejectedConnections"`
+}
+
+func NewListener(name string, listenerConfig config.Listener, logger lager.Logger) (Listener, error) {
+	var certificates *tlsCertificates
+	if listenerConfig.TLSEnabled() {
+		var err error
+		certificates, err = newTLSCertificates(listenerConfig, logger)
+		if err != nil {
+			return Listener{}, err
+		}
+	}
+
+	burst := listenerConfig.NewConnectionBurst
+	if burst == 0 {
+		burst = listenerConfig.NewConnectionsPerSecond
+	}
+
+	// the config has been validated, so every CIDR parses
+	var trustedCIDRs []*net.IPNet
+	for _, cidr := range listenerConfig.ProxyProtocolTrustedCIDRs {
+		if _, trustedCIDR, err := net.ParseCIDR(cidr); err == nil {
+			trustedCIDRs = append(trustedCIDRs, trustedCIDR)
+		}
+	}
+
+	return Listener{
+		Name:                      name,
+		IdleTimeout:               listenerConfig.IdleTimeout(),
+		MaxSessionLifetime:        listenerConfig.MaxSessionLifetime(),
+		AcceptProxyProtocol:       listenerConfig.AcceptProxyProtocol,
+		ProxyProtocolTrustedCIDRs: trustedCIDRs,
+		RejectWithMySQLError:      listenerConfig.RejectWithMySQLError,
+		MySQLErrorCode:            listenerConfig.MySQLErrorCode,
+		MySQLErrorMessage:         listenerConfig.MySQLErrorMessage,
+		ConnectTimeout:            listenerConfig.ConnectTimeout(),
+		ConnectRetries:            listenerConfig.ConnectRetries,
+		admission: &admission{
+			maxConnections:            listenerConfig.MaxConnections,
+			maxConnectionsPerClientIP: listenerConfig.MaxConnectionsPerClientIP,
+			ratePerSecond:             float64(listenerConfig.NewConnectionsPerSecond),
+			burst:                     float64(burst),
+			tokens:                    float64(burst),
+			lastRefill:                time.Now(),
+			connectionsByClientIP:     map[string]uint{},
+			rejected:                  map[string]uint64{},
+		},
+		tlsCertificates: certificates,
+	}, nil
+}
+
+// Admit decides whether a new client connection may be proxied. An admitted
+// connection holds its place until release is called; a rejected one is
+// counted under rejectReason.
+func (l Listener) Admit(clientAddr net.Addr) (release func(), rejectReason string) {
+	if l.admission == nil {
+		return func() {}, ""
+	}
+	return l.admission.admit(clientIP(clientAddr), time.Now())
+}
+
+// Reserve holds a place against the listener's connection limit for a
+// connection that must wait before it can be admitted, such as for its PROXY
+// protocol header, so that connections piling up while they wait are still
+// bounded. The place is given up by calling release, after which the
+// connection is admitted as usual; a rejected one is counted under
+// rejectReason.
+func (l Listener) Reserve() (release func(), rejectReason string) {
+	if l.admission == nil {
+		return func() {}, ""
+	}
+	return l.admission.reserve()
+}
+
+// CountRejection counts a connection rejected for a reason Admit does not
+// decide, such as traffic being disabled, so that AsJSON reports every
+// rejection.
+func (l Listener) CountRejection(rejectReason string) {
+	if l.admission == nil {
+		return
+	}
+
+	l.admission.mutex.Lock()
+	defer l.admission.mutex.Unlock()
+
+	l.admission.reject(rejectReason)
+}
+
+func (l Listener) AsJSON() ListenerJSON {
+	listenerJSON := ListenerJSON{
+		Name:                l.Name,
+		RejectedConnections: map[string]uint64{},
+	}
+	if l.admission == nil {
+		return listenerJSON
+	}
+
+	l.admission.mutex.Lock()
+	defer l.admission.mutex.Unlock()
+
+	listenerJSON.CurrentConnections = l.admission.connections
+	for rejectReason, count := range l.admission.rejected {
+		listenerJSON.RejectedConnections[rejectReason] = count
+	}
+	return listenerJSON
+}
+
+// admission holds a listener's connection counts and a token bucket that
+// refills at ratePerSecond up to burst.
+type admission struct {
+	mutex                     sync.Mutex
+	maxConnections            uint
+	maxConnectionsPerClientIP uint
+	ratePerSecond             float64
+	burst                     float64
+	tokens                    float64
+	lastRefill                time.Time
+	connections               uint
+	connectionsByClientIP     map[string]uint
+	rejected                  map[string]uint64
+}
+
+func (a *admission) admit(ip string, now time.Time) (func(), string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.maxConnections > 0 && a.connections >= a.maxConnections {
+		return a.reject(RejectReasonMaxConnections)
+	}
+	if a.maxConnectionsPerClientIP > 0 && a.connectionsByClientIP[ip] >= a.maxConnectionsPerClientIP {
+		return a.reject(RejectReasonMaxConnectionsPerClientIP)
+	}
+	if a.ratePerSecond > 0 {
+		a.tokens += now.Sub(a.lastRefill).Seconds() * a.ratePerSecond
+		if a.tokens > a.burst {
+			a.tokens = a.burst
+		}
+		a.lastRefill = now
+
+		if a.tokens < 1 {
+			return a.reject(RejectReasonRateLimited)
+		}
+		a.tokens--
+	}
+
+	a.connections++
+	a.connectionsByClientIP[ip]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			a.release(ip)
+		})
+	}, ""
+}
+
+func (a *admission) reserve() (func(), string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.maxConnections > 0 && a.connections >= a.maxConnections {
+		return a.reject(RejectReasonMaxConnections)
+	}
+
+	a.connections++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			a.mutex.Lock()
+			defer a.mutex.Unlock()
+
+			a.connections--
+		})
+	}, ""
+}
+
+func (a *admission) reject(rejectReason string) (func(), string) {
+	a.rejected[rejectReason]++
+	return nil, rejectReason
+}
+
+func (a *admission) release(ip string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.connections--
+	a.connectionsByClientIP[ip]--
+	if a.connectionsByClientIP[ip] == 0 {
+		delete(a.connectionsByClientIP, ip)
+	}
+}
+
+func clientIP(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
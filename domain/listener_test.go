@@ -0,0 +1,182 @@
+package domain_test
+
+import (
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry-incubator/switchboard/config"
+	"github.com/cloudfoundry-incubator/switchboard/domain"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Listener", func() {
+	var (
+		listenerConfig config.Listener
+		listener       domain.Listener
+	)
+
+	clientAddr := func(address string) net.Addr {
+		addr, err := net.ResolveTCPAddr("tcp", address)
+		Expect(err).NotTo(HaveOccurred())
+		return addr
+	}
+
+	BeforeEach(func() {
+		listenerConfig = config.Listener{}
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		listener, err = domain.NewListener("active", listenerConfig, lagertest.NewTestLogger("Listener test"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("NewListener", func() {
+		BeforeEach(func() {
+			listenerConfig.IdleTimeoutSeconds = 60
+			listenerConfig.MaxSessionLifetimeSeconds = 3600
+			listenerConfig.ConnectTimeoutMillis = 3000
+			listenerConfig.ConnectRetries = 2
+		})
+
+		It("takes its limits from the config", func() {
+			Expect(listener.Name).To(Equal("active"))
+			Expect(listener.IdleTimeout).To(Equal(time.Minute))
+			Expect(listener.MaxSessionLifetime).To(Equal(time.Hour))
+			Expect(listener.ConnectTimeout).To(Equal(3 * time.Second))
+			Expect(listener.ConnectRetries).To(BeNumerically("==", 2))
+		})
+	})
+
+	Describe("Admit", func() {
+		It("admits every connection when no limits are configured", func() {
+			for i := 0; i < 100; i++ {
+				_, rejectReason := listener.Admit(clientAddr("10.0.0.1:50000"))
+				Expect(rejectReason).To(BeEmpty())
+			}
+			Expect(listener.AsJSON().CurrentConnections).To(BeNumerically("==", 100))
+		})
+
+		It("admits every connection on a listener that was not configured", func() {
+			_, rejectReason := domain.Listener{Name: "active"}.Admit(clientAddr("10.0.0.1:50000"))
+			Expect(rejectReason).To(BeEmpty())
+		})
+
+		Context("when MaxConnections is configured", func() {
+			BeforeEach(func() {
+				listenerConfig.MaxConnections = 2
+			})
+
+			It("rejects connections beyond the limit until one is released", func() {
+				release, rejectReason := listener.Admit(clientAddr("10.0.0.1:50000"))
+				Expect(rejectReason).To(BeEmpty())
+				_, rejectReason = listener.Admit(clientAddr("10.0.0.2:50000"))
+				Expect(rejectReason).To(BeEmpty())
+
+				_, rejectReason = listener.Admit(clientAddr("10.0.0.3:50000"))
+				Expect(rejectReason).To(Equal(domain.RejectReasonMaxConnections))
+
+				release()
+				release() // releasing twice has no further effect
+
+				_, rejectReason = listener.Admit(clientAddr("10.0.0.3:50000"))
+				Expect(rejectReason).To(BeEmpty())
+				_, rejectReason = listener.Admit(clientAddr("10.0.0.4:50000"))
+				Expect(rejectReason).To(Equal(domain.RejectReasonMaxConnections))
+			})
+		})
+
+		Context("when MaxConnectionsPerClientIP is configured", func() {
+			BeforeEach(func() {
+				listenerConfig.MaxConnectionsPerClientIP = 1
+			})
+
+			It("limits each client IP separately", func() {
+				release, rejectReason := listener.Admit(clientAddr("10.0.0.1:50000"))
+				Expect(rejectReason).To(BeEmpty())
+
+				_, rejectReason = listener.Admit(clientAddr("10.0.0.1:50001"))
+				Expect(rejectReason).To(Equal(domain.RejectReasonMaxConnectionsPerClientIP))
+
+				_, rejectReason = listener.Admit(clientAddr("10.0.0.2:50000"))
+				Expect(rejectReason).To(BeEmpty())
+
+				release()
+				_, rejectReason = listener.Admit(clientAddr("10.0.0.1:50001"))
+				Expect(rejectReason).To(BeEmpty())
+			})
+		})
+
+		Context("when NewConnectionsPerSecond is configured", func() {
+			BeforeEach(func() {
+				listenerConfig.NewConnectionsPerSecond = 10
+				listenerConfig.NewConnectionBurst = 2
+			})
+
+			It("admits a burst and then refills at the configured rate", func() {
+				for i := 0; i < 2; i++ {
+					_, rejectReason := listener.Admit(clientAddr("10.0.0.1:50000"))
+					Expect(rejectReason).To(BeEmpty())
+				}
+
+				_, rejectReason := listener.Admit(clientAddr("10.0.0.1:50000"))
+				Expect(rejectReason).To(Equal(domain.RejectReasonRateLimited))
+
+				Eventually(func() string {
+					_, rejectReason := listener.Admit(clientAddr("10.0.0.1:50000"))
+					return rejectReason
+				}, time.Second, 50*time.Millisecond).Should(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Reserve", func() {
+		BeforeEach(func() {
+			listenerConfig.MaxConnections = 2
+		})
+
+		It("holds places against the connection limit until they are released", func() {
+			release, rejectReason := listener.Reserve()
+			Expect(rejectReason).To(BeEmpty())
+			_, rejectReason = listener.Admit(clientAddr("10.0.0.1:50000"))
+			Expect(rejectReason).To(BeEmpty())
+
+			_, rejectReason = listener.Reserve()
+			Expect(rejectReason).To(Equal(domain.RejectReasonMaxConnections))
+			_, rejectReason = listener.Admit(clientAddr("10.0.0.2:50000"))
+			Expect(rejectReason).To(Equal(domain.RejectReasonMaxConnections))
+
+			release()
+			release() // releasing twice has no further effect
+
+			_, rejectReason = listener.Admit(clientAddr("10.0.0.2:50000"))
+			Expect(rejectReason).To(BeEmpty())
+			_, rejectReason = listener.Admit(clientAddr("10.0.0.3:50000"))
+			Expect(rejectReason).To(Equal(domain.RejectReasonMaxConnections))
+		})
+	})
+
+	Describe("AsJSON", func() {
+		BeforeEach(func() {
+			listenerConfig.MaxConnections = 1
+		})
+
+		It("counts rejected connections by reason", func() {
+			listener.Admit(clientAddr("10.0.0.1:50000"))
+			listener.Admit(clientAddr("10.0.0.2:50000"))
+			listener.Admit(clientAddr("10.0.0.3:50000"))
+			listener.CountRejection(domain.RejectReasonTrafficDisabled)
+
+			Expect(listener.AsJSON()).To(Equal(domain.ListenerJSON{
+				Name:               "active",
+				CurrentConnections: 1,
+				RejectedConnections: map[string]uint64{
+					domain.RejectReasonMaxConnections:  2,
+					domain.RejectReasonTrafficDisabled: 1,
+				},
+			}))
+		})
+	})
+})
@@ -0,0 +1,178 @@
+package domain
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/cloudfoundry-incubator/switchboard/config"
+)
+
+// TLSHandshakeTimeout bounds how long a client has to complete its TLS
+// handshake with a listener that terminates TLS.
+var TLSHandshakeTimeout = 10 * time.Second
+
+// TerminateTLS completes a TLS handshake with the client when the listener
+// terminates TLS, and returns the decrypted connection. Connections to other
+// listeners are returned unchanged.
+func (l Listener) TerminateTLS(conn net.Conn) (net.Conn, error) {
+	if l.tlsCertificates == nil {
+		return conn, nil
+	}
+
+	tlsConn := tls.Server(conn, &tls.Config{
+		GetConfigForClient: l.tlsCertificates.configForClient,
+	})
+
+	err := tlsConn.SetDeadline(time.Now().Add(TLSHandshakeTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	err = tlsConn.Handshake()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error completing TLS handshake: %s", err))
+	}
+
+	err = tlsConn.SetDeadline(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+// tlsCertificates holds a listener's certificate and client CAs, reloading
+// them from disk whenever one of the files changes so that certificates can
+// be rotated without restarting the proxy.
+type tlsCertificates struct {
+	mutex           sync.Mutex
+	certificatePath string
+	privateKeyPath  string
+	clientCAPath    string
+	logger          lager.Logger
+
+	modTimes map[string]time.Time
+	config   *tls.Config
+}
+
+func newTLSCertificates(listenerConfig config.Listener, logger lager.Logger) (*tlsCertificates, error) {
+	c := &tlsCertificates{
+		certificatePath: listenerConfig.TLSCertificatePath,
+		privateKeyPath:  listenerConfig.TLSPrivateKeyPath,
+		clientCAPath:    listenerConfig.TLSClientCAPath,
+		logger:          logger,
+	}
+
+	var err error
+	c.config, err = c.load()
+	if err != nil {
+		return nil, err
+	}
+
+	c.modTimes, err = c.readModTimes()
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *tlsCertificates) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	modTimes, err := c.readModTimes()
+	if err != nil {
+		c.logger.Error("Error checking TLS certificates for changes, keeping the loaded ones", err)
+		return c.config, nil
+	}
+
+	if c.changedSince(modTimes) {
+		tlsConfig, err := c.load()
+		if err != nil {
+			c.logger.Error("Error reloading TLS certificates, keeping the loaded ones", err)
+			return c.config, nil
+		}
+
+		c.logger.Info("Reloaded TLS certificates", lager.Data{"certificatePath": c.certificatePath})
+		c.config = tlsConfig
+		c.modTimes = modTimes
+	}
+
+	return c.config, nil
+}
+
+func (c *tlsCertificates) load() (*tls.Config, error) {
+	certificate, err := tls.LoadX509KeyPair(c.certificatePath, c.privateKeyPath)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error loading TLS certificate: %s", err))
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if c.clientCAPath != "" {
+		caPEM, err := ioutil.ReadFile(c.clientCAPath)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Error loading TLS client CA: %s", err))
+		}
+
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New(fmt.Sprintf("Error loading TLS client CA: no certificates found in %s", c.clientCAPath))
+		}
+
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, nil
+}
+
+func (c *tlsCertificates) readModTimes() (map[string]time.Time, error) {
+	modTimes := map[string]time.Time{}
+	for _, path := range []string{c.certificatePath, c.privateKeyPath, c.clientCAPath} {
+		if path == "" {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[path] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+func (c *tlsCertificates) changedSince(modTimes map[string]time.Time) bool {
+	for path, modTime := range modTimes {
+		if !modTime.Equal(c.modTimes[path]) {
+			return true
+		}
+	}
+	return false
+}
+
+func clientCertificateSubject(conn net.Conn) string {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return ""
+	}
+
+	peerCertificates := tlsConn.ConnectionState().PeerCertificates
+	if len(peerCertificates) == 0 {
+		return ""
+	}
+	return peerCertificates[0].Subject.String()
+}
@@ -0,0 +1,226 @@
+package domain_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry-incubator/switchboard/config"
+	"github.com/cloudfoundry-incubator/switchboard/domain"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Listener TLS", func() {
+	var (
+		certDir        string
+		ca             *testCertificate
+		listenerConfig config.Listener
+		listener       domain.Listener
+		server         net.Listener
+	)
+
+	BeforeEach(func() {
+		var err error
+		certDir, err = ioutil.TempDir("", "listener-tls")
+		Expect(err).NotTo(HaveOccurred())
+
+		ca = newTestCertificate("test-ca", nil)
+		ca.writeTo(certDir, "ca")
+		newTestCertificate("proxy-v1", ca).writeTo(certDir, "server")
+
+		listenerConfig = config.Listener{
+			TLSCertificatePath: filepath.Join(certDir, "server.crt"),
+			TLSPrivateKeyPath:  filepath.Join(certDir, "server.key"),
+		}
+
+		server, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		listener, err = domain.NewListener("active", listenerConfig, lagertest.NewTestLogger("Listener TLS test"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(certDir)
+	})
+
+	// handshake connects a TLS client and terminates it on the listener,
+	// returning the server side of the connection and the certificate the
+	// client was shown.
+	handshake := func(clientCertificates []tls.Certificate) (net.Conn, *x509.Certificate, error) {
+		type result struct {
+			conn net.Conn
+			err  error
+		}
+		accepted := make(chan result, 1)
+		go func() {
+			conn, err := server.Accept()
+			if err != nil {
+				accepted <- result{err: err}
+				return
+			}
+			conn, err = listener.TerminateTLS(conn)
+			accepted <- result{conn: conn, err: err}
+		}()
+
+		clientConn, clientErr := tls.Dial("tcp", server.Addr().String(), &tls.Config{
+			RootCAs:      ca.pool(),
+			ServerName:   "127.0.0.1",
+			Certificates: clientCertificates,
+		})
+
+		var serverCertificate *x509.Certificate
+		if clientErr == nil {
+			defer clientConn.Close()
+			// TLS 1.3 reports a rejected client certificate on first read
+			clientConn.Write([]byte("ping"))
+			serverCertificate = clientConn.ConnectionState().PeerCertificates[0]
+		}
+
+		var r result
+		Eventually(accepted).Should(Receive(&r))
+		return r.conn, serverCertificate, r.err
+	}
+
+	It("terminates TLS with the configured certificate", func() {
+		conn, serverCertificate, err := handshake(nil)
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+
+		Expect(serverCertificate.Subject.CommonName).To(Equal("proxy-v1"))
+	})
+
+	It("picks up a rotated certificate without restarting", func() {
+		newTestCertificate("proxy-v2", ca).writeTo(certDir, "server")
+		later := time.Now().Add(time.Minute)
+		Expect(os.Chtimes(listenerConfig.TLSCertificatePath, later, later)).To(Succeed())
+
+		conn, serverCertificate, err := handshake(nil)
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+
+		Expect(serverCertificate.Subject.CommonName).To(Equal("proxy-v2"))
+	})
+
+	It("keeps the loaded certificate when the files on disk are broken", func() {
+		Expect(ioutil.WriteFile(listenerConfig.TLSCertificatePath, []byte("garbage"), 0600)).To(Succeed())
+		later := time.Now().Add(time.Minute)
+		Expect(os.Chtimes(listenerConfig.TLSCertificatePath, later, later)).To(Succeed())
+
+		conn, serverCertificate, err := handshake(nil)
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+
+		Expect(serverCertificate.Subject.CommonName).To(Equal("proxy-v1"))
+	})
+
+	Context("when the certificate cannot be loaded", func() {
+		It("returns an error", func() {
+			listenerConfig.TLSPrivateKeyPath = filepath.Join(certDir, "missing.key")
+
+			_, err := domain.NewListener("active", listenerConfig, lagertest.NewTestLogger("Listener TLS test"))
+			Expect(err).To(MatchError(ContainSubstring("Error loading TLS certificate")))
+		})
+	})
+
+	Context("when a client CA is configured", func() {
+		BeforeEach(func() {
+			listenerConfig.TLSClientCAPath = filepath.Join(certDir, "ca.crt")
+		})
+
+		It("requires a client certificate signed by the CA", func() {
+			_, _, err := handshake(nil)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("records the client certificate subject on the session", func() {
+			client := newTestCertificate("some-app", ca)
+
+			conn, _, err := handshake([]tls.Certificate{client.tlsCertificate()})
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close()
+
+			bridge := domain.NewBridge(conn, nil, listener, lagertest.NewTestLogger("Listener TLS test"))
+			Expect(bridge.AsJSON().ClientCertificateSubject).To(Equal("CN=some-app"))
+		})
+	})
+})
+
+type testCertificate struct {
+	certificate *x509.Certificate
+	der         []byte
+	key         *ecdsa.PrivateKey
+}
+
+// newTestCertificate creates a certificate for 127.0.0.1 signed by parent,
+// or a self-signed CA when parent is nil.
+func newTestCertificate(commonName string, parent *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.certificate, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	Expect(err).NotTo(HaveOccurred())
+
+	certificate, err := x509.ParseCertificate(der)
+	Expect(err).NotTo(HaveOccurred())
+
+	return &testCertificate{certificate: certificate, der: der, key: key}
+}
+
+func (c *testCertificate) writeTo(dir, name string) {
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	Expect(err).NotTo(HaveOccurred())
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	Expect(ioutil.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0600)).To(Succeed())
+	Expect(ioutil.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0600)).To(Succeed())
+}
+
+func (c *testCertificate) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(c.certificate)
+	return pool
+}
+
+func (c *testCertificate) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
@@ -0,0 +1,96 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"time"
+
+	"github.com/cloudfoundry-incubator/switchboard/mysql"
+)
+
+// MySQLRejectionTimeout bounds how long a rejected client has to answer the
+// server greeting before its connection is closed without an error packet.
+var MySQLRejectionTimeout = 5 * time.Second
+
+const (
+	DefaultMySQLErrorCode = 1053 // ER_SERVER_SHUTDOWN
+
+	mysqlServerVersion = "5.7.0-switchboard"
+	mysqlSQLState      = "08004" // server rejected the connection
+)
+
+// Reject turns a client connection away. Listeners in MySQL mode first
+// complete enough of the handshake to send an ERR packet carrying message,
+// so that drivers report it instead of a lost connection. A configured
+// MySQLErrorMessage takes precedence over message.
+func (l Listener) Reject(conn net.Conn, message string) {
+	defer conn.Close()
+
+	if !l.RejectWithMySQLError {
+		return
+	}
+
+	code := l.MySQLErrorCode
+	if code == 0 {
+		code = DefaultMySQLErrorCode
+	}
+	if l.MySQLErrorMessage != "" {
+		message = l.MySQLErrorMessage
+	}
+
+	err := conn.SetDeadline(time.Now().Add(MySQLRejectionTimeout))
+	if err != nil {
+		return
+	}
+
+	err = mysql.WritePacket(conn, 0, mysqlGreeting())
+	if err != nil {
+		return
+	}
+
+	// the client answers the greeting before it reads the server's reply,
+	// and the ERR packet must follow its packet in sequence
+	_, sequence, err := mysql.ReadPacket(conn, mysql.MaxHandshakePacketSize)
+	if err != nil {
+		return
+	}
+
+	_ = mysql.WritePacket(conn, sequence+1, mysqlErrPacket(code, message))
+}
+
+func mysqlGreeting() []byte {
+	capabilities := uint32(mysql.Capabilities)
+	authData := []byte("switchboard-rejects!") // 20 bytes, never checked
+
+	greeting := new(bytes.Buffer)
+	greeting.WriteByte(10) // protocol version
+	greeting.WriteString(mysqlServerVersion)
+	greeting.WriteByte(0)
+	binary.Write(greeting, binary.LittleEndian, uint32(0)) // connection id
+	greeting.Write(authData[:8])
+	greeting.WriteByte(0)
+	binary.Write(greeting, binary.LittleEndian, uint16(capabilities))
+	greeting.WriteByte(mysql.CharsetUTF8)
+	binary.Write(greeting, binary.LittleEndian, uint16(mysql.ServerStatusAutocommit))
+	binary.Write(greeting, binary.LittleEndian, uint16(capabilities>>16))
+	greeting.WriteByte(byte(len(authData) + 1))
+	greeting.Write(make([]byte, 10)) // reserved
+	greeting.Write(authData[8:])
+	greeting.WriteByte(0)
+	greeting.WriteString(mysql.NativePassword)
+	greeting.WriteByte(0)
+
+	return greeting.Bytes()
+}
+
+func mysqlErrPacket(code uint16, message string) []byte {
+	packet := new(bytes.Buffer)
+	packet.WriteByte(mysql.ErrPacket)
+	binary.Write(packet, binary.LittleEndian, code)
+	packet.WriteByte('#')
+	packet.WriteString(mysqlSQLState)
+	packet.WriteString(message)
+
+	return packet.Bytes()
+}
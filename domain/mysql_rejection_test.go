@@ -0,0 +1,98 @@
+package domain_test
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+
+	"github.com/cloudfoundry-incubator/switchboard/domain"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Listener Reject", func() {
+	var (
+		listener           domain.Listener
+		serverConn, client net.Conn
+		rejected           chan struct{}
+	)
+
+	BeforeEach(func() {
+		listener = domain.Listener{Name: "active", RejectWithMySQLError: true}
+		serverConn, client = net.Pipe()
+		rejected = make(chan struct{})
+	})
+
+	JustBeforeEach(func() {
+		go func(listener domain.Listener, serverConn net.Conn, rejected chan struct{}) {
+			listener.Reject(serverConn, "cluster under maintenance")
+			close(rejected)
+		}(listener, serverConn, rejected)
+	})
+
+	AfterEach(func() {
+		client.Close()
+	})
+
+	readPacket := func() (byte, []byte) {
+		header := make([]byte, 4)
+		_, err := io.ReadFull(client, header)
+		Expect(err).NotTo(HaveOccurred())
+
+		payload := make([]byte, int(header[0])|int(header[1])<<8|int(header[2])<<16)
+		_, err = io.ReadFull(client, payload)
+		Expect(err).NotTo(HaveOccurred())
+		return header[3], payload
+	}
+
+	writePacket := func(sequence byte, payload []byte) {
+		header := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), sequence}
+		_, err := client.Write(append(header, payload...))
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	It("greets the client and answers its handshake with an ERR packet", func() {
+		sequence, greeting := readPacket()
+		Expect(sequence).To(BeNumerically("==", 0))
+		Expect(greeting[0]).To(BeNumerically("==", 10), "protocol version")
+		Expect(string(greeting)).To(ContainSubstring("mysql_native_password"))
+
+		writePacket(1, []byte("some handshake response"))
+
+		sequence, errPacket := readPacket()
+		Expect(sequence).To(BeNumerically("==", 2))
+		Expect(errPacket[0]).To(BeNumerically("==", 0xff))
+		Expect(binary.LittleEndian.Uint16(errPacket[1:3])).To(BeNumerically("==", domain.DefaultMySQLErrorCode))
+		Expect(string(errPacket[3:9])).To(Equal("#08004"))
+		Expect(string(errPacket[9:])).To(Equal("cluster under maintenance"))
+
+		Eventually(rejected).Should(BeClosed())
+	})
+
+	Context("when an error code and message are configured", func() {
+		BeforeEach(func() {
+			listener.MySQLErrorCode = 1040
+			listener.MySQLErrorMessage = "try the other region"
+		})
+
+		It("sends them instead", func() {
+			readPacket()
+			writePacket(1, []byte("some handshake response"))
+
+			_, errPacket := readPacket()
+			Expect(binary.LittleEndian.Uint16(errPacket[1:3])).To(BeNumerically("==", 1040))
+			Expect(string(errPacket[9:])).To(Equal("try the other region"))
+		})
+	})
+
+	Context("when the listener is not in MySQL mode", func() {
+		BeforeEach(func() {
+			listener.RejectWithMySQLError = false
+		})
+
+		It("closes the connection without writing anything", func() {
+			_, err := client.Read(make([]byte, 1))
+			Expect(err).To(Equal(io.EOF))
+		})
+	})
+})
@@ -0,0 +1,229 @@
+package domain
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProxyHeaderTimeout bounds how long a trusted peer has to send its PROXY
+// protocol header after connecting.
+var ProxyHeaderTimeout = 5 * time.Second
+
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+const (
+	proxyV1Prefix    = "PROXY "
+	proxyV1MaxLength = 107
+
+	proxyV2Local = 0x20
+	proxyV2Proxy = 0x21
+
+	proxyV2UnspecFamily = 0x00
+	proxyV2TCP4         = 0x11
+	proxyV2TCP6         = 0x21
+)
+
+// proxiedConn is a client connection whose address was taken from a PROXY
+// protocol header rather than from the TCP peer.
+type proxiedConn struct {
+	net.Conn
+	reader     *bufio.Reader
+	remoteAddr net.Addr
+}
+
+func (c *proxiedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c *proxiedConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+// ReadProxyHeader reads a PROXY protocol v1 or v2 header from conn when the
+// listener accepts the protocol and the peer is trusted, and returns a
+// connection that reports the client address from the header. Connections
+// from untrusted peers are returned unchanged.
+func (l Listener) ReadProxyHeader(conn net.Conn) (net.Conn, error) {
+	if !l.AcceptProxyProtocol || !l.trustsProxyFrom(conn.RemoteAddr()) {
+		return conn, nil
+	}
+
+	err := conn.SetReadDeadline(time.Now().Add(ProxyHeaderTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	remoteAddr, err := readProxyHeader(reader)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error reading PROXY protocol header: %s", err))
+	}
+
+	err = conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	// a LOCAL header, e.g. from a load balancer health check, carries no
+	// client address of its own
+	if remoteAddr == nil {
+		remoteAddr = conn.RemoteAddr()
+	}
+
+	return &proxiedConn{Conn: conn, reader: reader, remoteAddr: remoteAddr}, nil
+}
+
+func (l Listener) trustsProxyFrom(addr net.Addr) bool {
+	ip := net.ParseIP(clientIP(addr))
+	if ip == nil {
+		return false
+	}
+
+	for _, cidr := range l.ProxyProtocolTrustedCIDRs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func readProxyHeader(reader *bufio.Reader) (net.Addr, error) {
+	signature, err := reader.Peek(len(proxyV2Signature))
+	if err == nil && bytes.Equal(signature, proxyV2Signature) {
+		return readProxyV2Header(reader)
+	}
+
+	prefix, err := reader.Peek(len(proxyV1Prefix))
+	if err == nil && string(prefix) == proxyV1Prefix {
+		return readProxyV1Header(reader)
+	}
+
+	return nil, errors.New("missing header")
+}
+
+func readProxyV1Header(reader *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= proxyV1MaxLength {
+			return nil, errors.New("v1 header too long")
+		}
+
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+	}
+
+	fields := strings.Fields(strings.TrimSuffix(string(line), "\r\n"))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, errors.New("malformed v1 header")
+	}
+
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil {
+		return nil, errors.New("malformed v1 source address")
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+func readProxyV2Header(reader *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	_, err := io.ReadFull(reader, header)
+	if err != nil {
+		return nil, err
+	}
+
+	command := header[12]
+	family := header[13]
+	addresses := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	_, err = io.ReadFull(reader, addresses)
+	if err != nil {
+		return nil, err
+	}
+
+	switch command {
+	case proxyV2Local:
+		return nil, nil
+	case proxyV2Proxy:
+	default:
+		return nil, errors.New("unsupported v2 version or command")
+	}
+
+	// any TLVs after the addresses are skipped
+	switch family {
+	case proxyV2TCP4:
+		if len(addresses) < 12 {
+			return nil, errors.New("truncated v2 addresses")
+		}
+		return &net.TCPAddr{
+			IP:   net.IP(addresses[0:4]),
+			Port: int(binary.BigEndian.Uint16(addresses[8:10])),
+		}, nil
+	case proxyV2TCP6:
+		if len(addresses) < 36 {
+			return nil, errors.New("truncated v2 addresses")
+		}
+		return &net.TCPAddr{
+			IP:   net.IP(addresses[0:16]),
+			Port: int(binary.BigEndian.Uint16(addresses[32:34])),
+		}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// writeProxyV2Header sends a PROXY protocol v2 header describing a
+// connection from src to dst. Addresses that are not TCP, or that mix IPv4
+// and IPv6, are sent as a LOCAL header.
+func writeProxyV2Header(w io.Writer, src, dst net.Addr) error {
+	header := bytes.NewBuffer(append([]byte{}, proxyV2Signature...))
+
+	srcTCP, srcOK := src.(*net.TCPAddr)
+	dstTCP, dstOK := dst.(*net.TCPAddr)
+
+	var family byte
+	var addresses []byte
+	switch {
+	case srcOK && dstOK && srcTCP.IP.To4() != nil && dstTCP.IP.To4() != nil:
+		family = proxyV2TCP4
+		addresses = append(addresses, srcTCP.IP.To4()...)
+		addresses = append(addresses, dstTCP.IP.To4()...)
+	case srcOK && dstOK && srcTCP.IP.To4() == nil && dstTCP.IP.To4() == nil:
+		family = proxyV2TCP6
+		addresses = append(addresses, srcTCP.IP.To16()...)
+		addresses = append(addresses, dstTCP.IP.To16()...)
+	}
+
+	if addresses == nil {
+		header.Write([]byte{proxyV2Local, proxyV2UnspecFamily, 0, 0})
+	} else {
+		ports := make([]byte, 4)
+		binary.BigEndian.PutUint16(ports[0:2], uint16(srcTCP.Port))
+		binary.BigEndian.PutUint16(ports[2:4], uint16(dstTCP.Port))
+		addresses = append(addresses, ports...)
+
+		length := make([]byte, 2)
+		binary.BigEndian.PutUint16(length, uint16(len(addresses)))
+
+		header.Write([]byte{proxyV2Proxy, family})
+		header.Write(length)
+		header.Write(addresses)
+	}
+
+	_, err := w.Write(header.Bytes())
+	return err
+}
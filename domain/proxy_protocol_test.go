@@ -0,0 +1,123 @@
+package domain_test
+
+import (
+	"io"
+	"net"
+
+	"github.com/cloudfoundry-incubator/switchboard/domain"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PROXY protocol", func() {
+	var (
+		listener         domain.Listener
+		server           net.Listener
+		clientConn       net.Conn
+		serverConn       net.Conn
+		proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+		_, loopback, _   = net.ParseCIDR("127.0.0.0/8")
+		_, elsewhere, _  = net.ParseCIDR("192.0.2.0/24")
+	)
+
+	BeforeEach(func() {
+		listener = domain.Listener{
+			Name:                      "active",
+			AcceptProxyProtocol:       true,
+			ProxyProtocolTrustedCIDRs: []*net.IPNet{loopback},
+		}
+
+		var err error
+		server, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+
+		clientConn, err = net.Dial("tcp", server.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+
+		serverConn, err = server.Accept()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		clientConn.Close()
+		serverConn.Close()
+		server.Close()
+	})
+
+	send := func(data []byte) {
+		_, err := clientConn.Write(data)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	readPayload := func(conn net.Conn) string {
+		payload := make([]byte, len("payload"))
+		_, err := io.ReadFull(conn, payload)
+		Expect(err).NotTo(HaveOccurred())
+		return string(payload)
+	}
+
+	It("takes the client address from a v1 header", func() {
+		send([]byte("PROXY TCP4 203.0.113.7 10.0.0.1 51234 3306\r\npayload"))
+
+		conn, err := listener.ReadProxyHeader(serverConn)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conn.RemoteAddr().String()).To(Equal("203.0.113.7:51234"))
+		Expect(readPayload(conn)).To(Equal("payload"))
+	})
+
+	It("takes the client address from a v2 header", func() {
+		header := append([]byte{}, proxyV2Signature...)
+		header = append(header, 0x21, 0x11, 0, 12)
+		header = append(header, 203, 0, 113, 7, 10, 0, 0, 1, 0xc8, 0x22, 0x0c, 0xea)
+		send(append(header, []byte("payload")...))
+
+		conn, err := listener.ReadProxyHeader(serverConn)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conn.RemoteAddr().String()).To(Equal("203.0.113.7:51234"))
+		Expect(readPayload(conn)).To(Equal("payload"))
+	})
+
+	It("keeps the peer address for a v2 LOCAL header", func() {
+		header := append([]byte{}, proxyV2Signature...)
+		header = append(header, 0x20, 0x00, 0, 0)
+		send(append(header, []byte("payload")...))
+
+		conn, err := listener.ReadProxyHeader(serverConn)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conn.RemoteAddr()).To(Equal(serverConn.RemoteAddr()))
+		Expect(readPayload(conn)).To(Equal("payload"))
+	})
+
+	It("returns an error when a trusted peer sends no header", func() {
+		send([]byte("payload that is not a header\r\n"))
+
+		_, err := listener.ReadProxyHeader(serverConn)
+		Expect(err).To(MatchError(ContainSubstring("PROXY protocol header")))
+	})
+
+	Context("when the peer is not trusted", func() {
+		BeforeEach(func() {
+			listener.ProxyProtocolTrustedCIDRs = []*net.IPNet{elsewhere}
+		})
+
+		It("does not read a header", func() {
+			send([]byte("payload"))
+
+			conn, err := listener.ReadProxyHeader(serverConn)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conn).To(Equal(serverConn))
+		})
+	})
+
+	Context("when the listener does not accept the protocol", func() {
+		BeforeEach(func() {
+			listener.AcceptProxyProtocol = false
+		})
+
+		It("does not read a header", func() {
+			conn, err := listener.ReadProxyHeader(serverConn)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conn).To(Equal(serverConn))
+		})
+	})
+})
@@ -0,0 +1,373 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// DefaultCapacity is how many events are kept when no capacity is
+// configured.
+const DefaultCapacity = 1000
+
+// Kinds of event.
+const (
+	KindBackendHealthy       = "backendHealthy"
+	KindBackendUnhealthy     = "backendUnhealthy"
+	KindActiveBackendChanged = "activeBackendChanged"
+	KindTrafficEnabled       = "trafficEnabled"
+	KindTrafficDisabled      = "trafficDisabled"
+	KindSessionsSevered      = "sessionsSevered"
+)
+
+// Kinds lists every kind of event.
+var Kinds = []string{
+	KindBackendHealthy,
+	KindBackendUnhealthy,
+	KindActiveBackendChanged,
+	KindTrafficEnabled,
+	KindTrafficDisabled,
+	KindSessionsSevered,
+}
+
+// Reasons the active backend changed: the previous one became unhealthy,
+// was taken out of rotation or was held out for flapping; a backend was
+// pinned; failback was triggered; a backend the policy prefers became
+// selectable; the first backend was chosen; or a backend became selectable
+// when there was none.
+const (
+	ReasonUnhealthy        = "unhealthy"
+	ReasonOutOfRotation    = "outOfRotation"
+	ReasonFlapping         = "flapping"
+	ReasonPinned           = "pinned"
+	ReasonFailback         = "failback"
+	ReasonPreferred        = "preferred"
+	ReasonInitial          = "initial"
+	ReasonBackendAvailable = "backendAvailable"
+)
+
+// Reasons sessions were severed.
+const (
+	SeverReasonTrafficDisabled      = "trafficDisabled"
+	SeverReasonActiveBackendChanged = "activeBackendChanged"
+	SeverReasonDrainFinished        = "drainFinished"
+	SeverReasonMaintenance          = "maintenance"
+	SeverReasonOperator             = "operator"
+)
+
+type Event struct {
+	Time            time.Time `json:"time"`
+	Kind            string    `json:"kind"`
+	Listener        string    `json:"listener,omitempty"`
+	Backend         string    `json:"backend,omitempty"`
+	PreviousBackend string    `json:"previousBackend,omitempty"`
+	Reason          string    `json:"reason,omitempty"`
+	Message         string    `json:"message,omitempty"`
+	Sessions        uint      `json:"sessions,omitempty"`
+}
+
+// Summary describes the event in a sentence, e.g. for chat notifications.
+func (e Event) Summary() string {
+	switch e.Kind {
+	case KindBackendHealthy:
+		return fmt.Sprintf("%s became healthy", e.Backend)
+	case KindBackendUnhealthy:
+		return fmt.Sprintf("%s became unhealthy", e.Backend)
+	case KindActiveBackendChanged:
+		return fmt.Sprintf("%s listener moved from %s to %s (%s)", e.Listener, orNone(e.PreviousBackend), orNone(e.Backend), e.Reason)
+	case KindTrafficEnabled:
+		return withMessage("Traffic enabled", e.Message)
+	case KindTrafficDisabled:
+		return withMessage("Traffic disabled", e.Message)
+	case KindSessionsSevered:
+		if e.Listener != "" {
+			return fmt.Sprintf("Severed %d %s sessions to %s (%s)", e.Sessions, e.Listener, e.Backend, e.Reason)
+		}
+		return fmt.Sprintf("Severed %d sessions to %s (%s)", e.Sessions, e.Backend, e.Reason)
+	default:
+		return e.Kind
+	}
+}
+
+func orNone(backend string) string {
+	if backend == "" {
+		return "none"
+	}
+	return backend
+}
+
+func withMessage(summary, message string) string {
+	if message == "" {
+		return summary
+	}
+	return summary + ": " + message
+}
+
+// Log keeps the latest events in a ring buffer, so that incidents can be
+// reconstructed without collecting logs from every VM. With a path the
+// events are also appended to a file, one JSON object per line, and read
+// back on start. Its methods do nothing on a nil *Log, so components run
+// without one.
+type Log struct {
+	capacity int
+	path     string
+	logger   lager.Logger
+
+	mutex       sync.Mutex
+	events      []Event
+	next        int
+	appended    int
+	subscribers map[chan Event]bool
+}
+
+// subscriberBuffer is how many events a subscriber may fall behind by before
+// it misses them.
+const subscriberBuffer = 100
+
+// NewLog returns a log of up to capacity events, reading those already
+// persisted at path if it is set.
+func NewLog(capacity uint, path string, logger lager.Logger) (*Log, error) {
+	if capacity == 0 {
+		capacity = DefaultCapacity
+	}
+
+	l := &Log{
+		capacity:    int(capacity),
+		path:        path,
+		logger:      logger,
+		subscribers: map[chan Event]bool{},
+	}
+
+	if path != "" {
+		err := l.load()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Record adds the event, stamping it with the current time unless it has
+// one.
+func (l *Log) Record(event Event) {
+	if l == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.unsafeAdd(event)
+
+	if l.path != "" {
+		l.unsafePersist(event)
+	}
+
+	for subscriber := range l.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			l.logger.Info("Subscriber fell behind, dropping event", lager.Data{"event": event})
+		}
+	}
+}
+
+// Subscribe returns a channel that receives each event recorded from now on,
+// and a func that ends the subscription. A subscriber that falls too far
+// behind misses events rather than holding up the components recording
+// them.
+func (l *Log) Subscribe() (<-chan Event, func()) {
+	if l == nil {
+		return nil, func() {}
+	}
+
+	subscriber := make(chan Event, subscriberBuffer)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.subscribers[subscriber] = true
+	return subscriber, func() {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+
+		delete(l.subscribers, subscriber)
+	}
+}
+
+// Between returns the events from the start to the end time inclusive,
+// oldest first. A zero time leaves that end of the range open.
+func (l *Log) Between(from, to time.Time) []Event {
+	if l == nil {
+		return []Event{}
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	events := []Event{}
+	for _, event := range l.unsafeEvents() {
+		if !from.IsZero() && event.Time.Before(from) {
+			continue
+		}
+		if !to.IsZero() && event.Time.After(to) {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func (l *Log) BackendHealthChanged(backend string, healthy bool) {
+	kind := KindBackendUnhealthy
+	if healthy {
+		kind = KindBackendHealthy
+	}
+	l.Record(Event{Kind: kind, Backend: backend})
+}
+
+// ActiveBackendChanged records that the listener moved from previous to
+// current, either of which may be blank, and why.
+func (l *Log) ActiveBackendChanged(listener, previous, current, reason string) {
+	l.Record(Event{
+		Kind:            KindActiveBackendChanged,
+		Listener:        listener,
+		Backend:         current,
+		PreviousBackend: previous,
+		Reason:          reason,
+	})
+}
+
+func (l *Log) TrafficChanged(enabled bool, message string) {
+	kind := KindTrafficDisabled
+	if enabled {
+		kind = KindTrafficEnabled
+	}
+	l.Record(Event{Kind: kind, Message: message})
+}
+
+// SessionsSevered records that sessions to the backend were closed by
+// switchboard or an operator. A blank listener means sessions from every listener.
+func (l *Log) SessionsSevered(backend, listener string, sessions uint, reason string) {
+	if sessions == 0 {
+		return
+	}
+	l.Record(Event{
+		Kind:     KindSessionsSevered,
+		Backend:  backend,
+		Listener: listener,
+		Sessions: sessions,
+		Reason:   reason,
+	})
+}
+
+func (l *Log) unsafeAdd(event Event) {
+	if len(l.events) < l.capacity {
+		l.events = append(l.events, event)
+		return
+	}
+	l.events[l.next] = event
+	l.next = (l.next + 1) % l.capacity
+}
+
+// unsafeEvents returns the buffered events, oldest first.
+func (l *Log) unsafeEvents() []Event {
+	events := make([]Event, 0, len(l.events))
+	events = append(events, l.events[l.next:]...)
+	return append(events, l.events[:l.next]...)
+}
+
+// unsafePersist appends the event to the file. Once as many events have been
+// appended as the log holds, the file is rewritten with only the buffered
+// events, so that it stays under twice the capacity.
+func (l *Log) unsafePersist(event Event) {
+	l.appended++
+	if l.appended >= l.capacity {
+		err := l.unsafeRewrite()
+		if err != nil {
+			l.logger.Error("Failed to rewrite event log", err, lager.Data{"path": l.path})
+		}
+		return
+	}
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		l.logger.Error("Failed to persist event", err, lager.Data{"path": l.path})
+		return
+	}
+	defer f.Close()
+
+	err = json.NewEncoder(f).Encode(event)
+	if err != nil {
+		l.logger.Error("Failed to persist event", err, lager.Data{"path": l.path})
+	}
+}
+
+func (l *Log) unsafeRewrite() error {
+	f, err := ioutil.TempFile(filepath.Dir(l.path), filepath.Base(l.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	encoder := json.NewEncoder(f)
+	for _, event := range l.unsafeEvents() {
+		err = encoder.Encode(event)
+		if err != nil {
+			f.Close()
+			return err
+		}
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(f.Name(), l.path)
+	if err != nil {
+		return err
+	}
+	l.appended = 0
+	return nil
+}
+
+// load reads the persisted events, skipping lines that cannot be decoded,
+// such as one cut short by a crash, and compacts the file.
+func (l *Log) load() error {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			l.logger.Info("Skipping unreadable persisted event", lager.Data{"path": l.path, "error": err.Error()})
+			continue
+		}
+		l.unsafeAdd(event)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return l.unsafeRewrite()
+}
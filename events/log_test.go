@@ -0,0 +1,199 @@
+package events_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry-incubator/switchboard/events"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Log", func() {
+	var (
+		logger *lagertest.TestLogger
+		start  time.Time
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("Log test")
+		start = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	})
+
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+
+	kinds := func(evs []events.Event) []string {
+		var k []string
+		for _, e := range evs {
+			k = append(k, e.Kind+":"+e.Backend)
+		}
+		return k
+	}
+
+	It("keeps only the latest events, oldest first", func() {
+		log, err := events.NewLog(2, "", logger)
+		Expect(err).NotTo(HaveOccurred())
+
+		log.Record(events.Event{Time: at(0), Kind: events.KindBackendHealthy, Backend: "backend-0"})
+		log.Record(events.Event{Time: at(1), Kind: events.KindBackendHealthy, Backend: "backend-1"})
+		log.Record(events.Event{Time: at(2), Kind: events.KindBackendUnhealthy, Backend: "backend-0"})
+
+		Expect(kinds(log.Between(time.Time{}, time.Time{}))).To(Equal([]string{
+			"backendHealthy:backend-1",
+			"backendUnhealthy:backend-0",
+		}))
+	})
+
+	It("filters events by time range", func() {
+		log, err := events.NewLog(10, "", logger)
+		Expect(err).NotTo(HaveOccurred())
+		for i := 0; i < 5; i++ {
+			log.Record(events.Event{Time: at(i), Kind: events.KindBackendHealthy, Backend: string(rune('a' + i))})
+		}
+
+		Expect(kinds(log.Between(at(1), at(3)))).To(Equal([]string{"backendHealthy:b", "backendHealthy:c", "backendHealthy:d"}))
+		Expect(kinds(log.Between(at(3), time.Time{}))).To(Equal([]string{"backendHealthy:d", "backendHealthy:e"}))
+		Expect(kinds(log.Between(time.Time{}, at(0)))).To(Equal([]string{"backendHealthy:a"}))
+		Expect(log.Between(at(10), time.Time{})).To(BeEmpty())
+	})
+
+	It("stamps events with the time they were recorded", func() {
+		log, err := events.NewLog(10, "", logger)
+		Expect(err).NotTo(HaveOccurred())
+
+		log.ActiveBackendChanged("active", "backend-0", "backend-1", events.ReasonUnhealthy)
+
+		recorded := log.Between(time.Time{}, time.Time{})
+		Expect(recorded).To(HaveLen(1))
+		Expect(recorded[0].Time).To(BeTemporally("~", time.Now(), time.Second))
+
+		recorded[0].Time = time.Time{}
+		Expect(recorded[0]).To(Equal(events.Event{
+			Kind:            events.KindActiveBackendChanged,
+			Listener:        "active",
+			Backend:         "backend-1",
+			PreviousBackend: "backend-0",
+			Reason:          events.ReasonUnhealthy,
+		}))
+	})
+
+	It("does not record severing no sessions", func() {
+		log, err := events.NewLog(10, "", logger)
+		Expect(err).NotTo(HaveOccurred())
+
+		log.SessionsSevered("backend-0", "", 0, events.SeverReasonTrafficDisabled)
+		Expect(log.Between(time.Time{}, time.Time{})).To(BeEmpty())
+	})
+
+	Describe("Subscribe", func() {
+		It("delivers each event recorded until the subscription ends", func() {
+			log, err := events.NewLog(10, "", logger)
+			Expect(err).NotTo(HaveOccurred())
+			log.Record(events.Event{Time: at(0), Kind: events.KindTrafficDisabled})
+
+			subscription, unsubscribe := log.Subscribe()
+			log.Record(events.Event{Time: at(1), Kind: events.KindTrafficEnabled})
+
+			var received events.Event
+			Eventually(subscription).Should(Receive(&received))
+			Expect(received.Kind).To(Equal(events.KindTrafficEnabled))
+
+			unsubscribe()
+			log.Record(events.Event{Time: at(2), Kind: events.KindTrafficDisabled})
+			Consistently(subscription).ShouldNot(Receive())
+		})
+
+		It("drops events for a subscriber that falls behind", func() {
+			log, err := events.NewLog(1000, "", logger)
+			Expect(err).NotTo(HaveOccurred())
+
+			subscription, unsubscribe := log.Subscribe()
+			defer unsubscribe()
+
+			for i := 0; i < 150; i++ {
+				log.Record(events.Event{Time: at(i), Kind: events.KindBackendHealthy})
+			}
+			Expect(len(subscription)).To(Equal(cap(subscription)))
+			Expect(log.Between(time.Time{}, time.Time{})).To(HaveLen(150))
+		})
+	})
+
+	Describe("Summary", func() {
+		It("describes the event", func() {
+			Expect(events.Event{
+				Kind:            events.KindActiveBackendChanged,
+				Listener:        "active",
+				PreviousBackend: "backend-0",
+				Reason:          events.ReasonUnhealthy,
+			}.Summary()).To(Equal("active listener moved from backend-0 to none (unhealthy)"))
+
+			Expect(events.Event{Kind: events.KindTrafficDisabled, Message: "upgrading"}.Summary()).To(Equal("Traffic disabled: upgrading"))
+			Expect(events.Event{Kind: events.KindTrafficEnabled}.Summary()).To(Equal("Traffic enabled"))
+		})
+	})
+
+	It("does nothing when nil", func() {
+		var log *events.Log
+		log.TrafficChanged(false, "maintenance")
+		Expect(log.Between(time.Time{}, time.Time{})).To(BeEmpty())
+	})
+
+	Context("when persisted to a file", func() {
+		var (
+			dir  string
+			path string
+		)
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "events")
+			Expect(err).NotTo(HaveOccurred())
+			path = filepath.Join(dir, "events.jsonl")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("reads the events back when created again", func() {
+			log, err := events.NewLog(10, path, logger)
+			Expect(err).NotTo(HaveOccurred())
+			log.Record(events.Event{Time: at(0), Kind: events.KindTrafficDisabled, Message: "upgrading"})
+			log.Record(events.Event{Time: at(1), Kind: events.KindTrafficEnabled, Message: "done"})
+
+			reopened, err := events.NewLog(10, path, logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reopened.Between(time.Time{}, time.Time{})).To(Equal(log.Between(time.Time{}, time.Time{})))
+		})
+
+		It("skips lines it cannot read", func() {
+			Expect(ioutil.WriteFile(path, []byte(`{"kind":"trafficEnabled"}`+"\n"+`{"kind":"traf`), 0600)).To(Succeed())
+
+			log, err := events.NewLog(10, path, logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(log.Between(time.Time{}, time.Time{})).To(HaveLen(1))
+		})
+
+		It("keeps the file under twice the capacity", func() {
+			log, err := events.NewLog(3, path, logger)
+			Expect(err).NotTo(HaveOccurred())
+			for i := 0; i < 20; i++ {
+				log.Record(events.Event{Time: at(i), Kind: events.KindBackendHealthy})
+			}
+
+			contents, err := ioutil.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(strings.Split(strings.TrimSpace(string(contents)), "\n"))).To(BeNumerically("<", 6))
+
+			reopened, err := events.NewLog(3, path, logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reopened.Between(time.Time{}, time.Time{})).To(Equal(log.Between(time.Time{}, time.Time{})))
+		})
+	})
+})
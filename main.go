@@ -11,11 +11,15 @@ import (
 	"github.com/cloudfoundry-incubator/switchboard/apiaggregator"
 	"github.com/cloudfoundry-incubator/switchboard/config"
 	"github.com/cloudfoundry-incubator/switchboard/domain"
+	"github.com/cloudfoundry-incubator/switchboard/events"
+	"github.com/cloudfoundry-incubator/switchboard/metrics"
 	apirunner "github.com/cloudfoundry-incubator/switchboard/runner/api"
 	apiaggregatorrunner "github.com/cloudfoundry-incubator/switchboard/runner/apiaggregator"
 	"github.com/cloudfoundry-incubator/switchboard/runner/bridge"
 	"github.com/cloudfoundry-incubator/switchboard/runner/health"
 	"github.com/cloudfoundry-incubator/switchboard/runner/monitor"
+	"github.com/cloudfoundry-incubator/switchboard/runner/notifier"
+	"github.com/cloudfoundry-incubator/switchboard/runner/statsd"
 	"github.com/tedsuo/ifrit"
 	"github.com/tedsuo/ifrit/grouper"
 	"github.com/tedsuo/ifrit/sigmon"
@@ -35,29 +39,117 @@ func main() {
 		logger.Fatal(fmt.Sprintf("staticDir: %s does not exist", rootConfig.StaticDir), nil)
 	}
 
-	backends := domain.NewBackends(rootConfig.Proxy.Backends, logger)
+	backends, err := domain.NewBackends(rootConfig.Proxy.Backends, logger)
+	if err != nil {
+		logger.Fatal("Error configuring backends:", err)
+	}
+
+	eventLog, err := events.NewLog(rootConfig.Events.Capacity, rootConfig.Events.PersistPath, logger.Session("events"))
+	if err != nil {
+		logger.Fatal("Error configuring event log:", err)
+	}
+	for _, backend := range backends {
+		backend.SetEventLog(eventLog)
+	}
+
+	switchboardMetrics := metrics.New()
+	switchboardMetrics.CollectBackends(backends)
+
+	var healthCheckers []monitor.HealthChecker
+	for _, backendConfig := range rootConfig.Proxy.Backends {
+		healthChecker, err := monitor.NewHealthChecker(backendConfig, rootConfig.Proxy.HealthcheckTimeout())
+		if err != nil {
+			logger.Fatal("Error configuring health checks:", err)
+		}
+		healthCheckers = append(healthCheckers, healthChecker)
+	}
+	configureSelectionPolicy := func(clusterMonitor *monitor.ClusterMonitor, listenerConfig config.Listener, useLowestIndex bool) {
+		selectionPolicy, err := monitor.NewSelectionPolicy(listenerConfig, rootConfig.Proxy.Backends, backends, useLowestIndex)
+		if err != nil {
+			logger.Fatal("Error configuring selection policy:", err)
+		}
+		clusterMonitor.SetSelectionPolicy(selectionPolicy)
+	}
+
+	var errorTracker *monitor.ErrorTracker
+	if rootConfig.Proxy.PassiveErrorThreshold > 0 {
+		errorTracker = monitor.NewErrorTracker(rootConfig.Proxy.PassiveErrorThreshold, rootConfig.Proxy.PassiveErrorWindow(), logger.Session("error-tracker"))
+		for _, backend := range backends {
+			backend.SetErrorObserver(errorTracker.RecordError)
+		}
+	}
 
+	// the active monitor runs the health checks, which the inactive one
+	// shares
 	activeNodeClusterMonitor := monitor.NewClusterMonitor(
 		backends,
 		rootConfig.Proxy.HealthcheckTimeout(),
 		logger.Session("active-monitor"),
 		true,
 	)
+	for i, backend := range backends {
+		activeNodeClusterMonitor.SetHealthChecker(backend, healthCheckers[i])
+	}
+	activeNodeClusterMonitor.SetRiseAndFall(rootConfig.Proxy.HealthcheckRise, rootConfig.Proxy.HealthcheckFall)
+	activeNodeClusterMonitor.SetInterval(rootConfig.Proxy.HealthcheckInterval(), rootConfig.Proxy.HealthcheckJitter())
+	activeNodeClusterMonitor.SetFlapDamping(rootConfig.Proxy.FlapThreshold, rootConfig.Proxy.FlapWindow(), rootConfig.Proxy.FlapPenalty())
+	if errorTracker != nil {
+		activeNodeClusterMonitor.SetErrorTracker(errorTracker)
+	}
+	activeNodeClusterMonitor.SetMetrics(switchboardMetrics)
+	activeNodeClusterMonitor.SetEventLog(eventLog, "active")
+	configureSelectionPolicy(activeNodeClusterMonitor, rootConfig.Proxy.ActiveListener, true)
+
+	activeListener, err := domain.NewListener("active", rootConfig.Proxy.ActiveListener, logger.Session("active-listener"))
+	if err != nil {
+		logger.Fatal("Error configuring active listener:", err)
+	}
+	listeners := []domain.Listener{activeListener}
 
 	activeNodeBridgeRunner := bridge.NewRunner(
+		activeListener,
 		rootConfig.Proxy.Port,
 		rootConfig.Proxy.ShutdownDelay(),
+		rootConfig.Proxy.DrainTimeout(),
 		logger.Session("active-bridge-runner"),
 	)
 	clusterStateManager := api.NewClusterAPI(logger)
+	clusterStateManager.SetEventLog(eventLog)
+	clusterMessage := func() string {
+		return clusterStateManager.AsJSON().Message
+	}
+	activeNodeBridgeRunner.ClusterMessage = clusterMessage
+	activeNodeBridgeRunner.Metrics = switchboardMetrics
+	switchboardMetrics.CollectTrafficEnabled(func() bool {
+		return clusterStateManager.AsJSON().TrafficEnabled
+	})
+
+	// a backend that a client could not connect to is rechecked rather than
+	// waiting for the next interval
+	activeNodeBridgeRunner.BackendFailed = activeNodeClusterMonitor.RecheckBackend
+	if errorTracker != nil {
+		errorTracker.BackendSuspect = activeNodeClusterMonitor.RecheckBackend
+	}
 
 	activeNodeClusterMonitor.RegisterBackendSubscriber(activeNodeBridgeRunner.ActiveBackendChan)
 	activeNodeClusterMonitor.RegisterBackendSubscriber(clusterStateManager.ActiveBackendChan)
 
 	clusterStateManager.RegisterTrafficEnabledChan(activeNodeBridgeRunner.TrafficEnabledChan)
+	clusterStateManager.RegisterFailback(activeNodeClusterMonitor.Failback)
+	clusterStateManager.RegisterPin(activeNodeClusterMonitor.Pin)
+	clusterStateManager.RegisterReselect(activeNodeClusterMonitor.Reselect)
 	go clusterStateManager.ListenForActiveBackend()
 
-	apiHandler := api.NewHandler(clusterStateManager, backends, logger, rootConfig.API, rootConfig.StaticDir)
+	var inactiveListener domain.Listener
+	if rootConfig.Proxy.InactiveMysqlPort != 0 {
+		inactiveListener, err = domain.NewListener("inactive", rootConfig.Proxy.InactiveListener, logger.Session("inactive-listener"))
+		if err != nil {
+			logger.Fatal("Error configuring inactive listener:", err)
+		}
+		listeners = append(listeners, inactiveListener)
+	}
+
+	apiHandler := api.NewHandler(clusterStateManager, backends, listeners, logger, rootConfig.API, rootConfig.StaticDir, switchboardMetrics.Handler(), eventLog)
 	aggregatorHandler := apiaggregator.NewHandler(logger, rootConfig.API)
 
 	members := grouper.Members{
@@ -79,6 +171,17 @@ func main() {
 		},
 	}
 
+	if len(rootConfig.Notifications.Webhooks) > 0 {
+		webhookNotifier := notifier.NewWebhookNotifier(rootConfig.Notifications, logger.Session("notifier"))
+
+		// the notifier starts first and stops last, so that it is passed
+		// every event
+		members = append(grouper.Members{{
+			Name:   "notifier",
+			Runner: notifier.NewRunner(webhookNotifier, eventLog, logger.Session("notifier")),
+		}}, members...)
+	}
+
 	if rootConfig.HealthPort != rootConfig.API.Port {
 		members = append(members, grouper.Member{
 			Name:   "health",
@@ -86,6 +189,18 @@ func main() {
 		})
 	}
 
+	if rootConfig.Metrics.StatsdEnabled() {
+		statsdEmitter, err := metrics.NewStatsdEmitter(switchboardMetrics, rootConfig.Metrics)
+		if err != nil {
+			logger.Fatal("Error configuring StatsD emitter:", err)
+		}
+
+		members = append(members, grouper.Member{
+			Name:   "statsd-emitter",
+			Runner: statsd.NewRunner(statsdEmitter, rootConfig.Metrics.StatsdFlushInterval(), logger.Session("statsd-emitter")),
+		})
+	}
+
 	if rootConfig.Proxy.InactiveMysqlPort != 0 {
 		inactiveNodeClusterMonitor := monitor.NewClusterMonitor(
 			backends,
@@ -93,15 +208,27 @@ func main() {
 			logger.Session("inactive-monitor"),
 			false,
 		)
+		inactiveNodeClusterMonitor.ShareHealthChecks(activeNodeClusterMonitor)
+		inactiveNodeClusterMonitor.SetEventLog(eventLog, "inactive")
+		configureSelectionPolicy(inactiveNodeClusterMonitor, rootConfig.Proxy.InactiveListener, false)
 
 		inactiveNodeBridgeRunner := bridge.NewRunner(
+			inactiveListener,
 			rootConfig.Proxy.InactiveMysqlPort,
 			0,
+			rootConfig.Proxy.DrainTimeout(),
 			logger.Session("inactive-bridge-runner"),
 		)
 
+		inactiveNodeBridgeRunner.ClusterMessage = clusterMessage
+		inactiveNodeBridgeRunner.Metrics = switchboardMetrics
+		inactiveNodeBridgeRunner.BackendFailed = activeNodeClusterMonitor.RecheckBackend
+		inactiveNodeBridgeRunner.HealthyBackends = inactiveNodeClusterMonitor.HealthyBackends
+
 		inactiveNodeClusterMonitor.RegisterBackendSubscriber(inactiveNodeBridgeRunner.ActiveBackendChan)
 		clusterStateManager.RegisterTrafficEnabledChan(inactiveNodeBridgeRunner.TrafficEnabledChan)
+		clusterStateManager.RegisterFailback(inactiveNodeClusterMonitor.Failback)
+		clusterStateManager.RegisterReselect(inactiveNodeClusterMonitor.Reselect)
 
 		members = append(members,
 			grouper.Member{
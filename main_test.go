@@ -119,6 +119,20 @@ func getBackendsFromApi(req *http.Request) []map[string]interface{} {
 	return returnedBackends
 }
 
+func getSessionsFromApi(req *http.Request) []map[string]interface{} {
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+	returnedSessions := []map[string]interface{}{}
+
+	decoder := json.NewDecoder(resp.Body)
+	err = decoder.Decode(&returnedSessions)
+	Expect(err).NotTo(HaveOccurred())
+	return returnedSessions
+}
+
 func matchConnectionDisconnect() types.GomegaMatcher {
 	//exact error depends on environment
 	return MatchError(
@@ -535,6 +549,113 @@ var _ = Describe("Switchboard", func() {
 			})
 		})
 
+		Describe("/v0/sessions", func() {
+			It("lists proxied sessions with their listener and backend", func() {
+				var conn net.Conn
+				Eventually(func() (err error) {
+					conn, err = net.Dial("tcp", fmt.Sprintf("localhost:%d", proxyPort))
+					return err
+				}, startupTimeout).Should(Succeed())
+				defer conn.Close()
+
+				_, err := sendData(conn, "some data")
+				Expect(err).NotTo(HaveOccurred())
+
+				url := fmt.Sprintf("http://localhost:%d/v0/sessions?listener=active", switchboardAPIPort)
+				req, err := http.NewRequest("GET", url, nil)
+				Expect(err).NotTo(HaveOccurred())
+				req.SetBasicAuth("username", "password")
+
+				Eventually(func() []map[string]interface{} {
+					return getSessionsFromApi(req)
+				}).Should(ContainElement(And(
+					HaveKeyWithValue("listener", "active"),
+					HaveKeyWithValue("backend", initialActiveBackend.Name),
+					HaveKeyWithValue("clientAddress", conn.LocalAddr().String()),
+					HaveKeyWithValue("bytesFromClient", BeNumerically("==", len("some data"))),
+				)))
+			})
+
+			It("severs a single session by ID", func() {
+				var conn, otherConn net.Conn
+				Eventually(func() (err error) {
+					conn, err = net.Dial("tcp", fmt.Sprintf("localhost:%d", proxyPort))
+					return err
+				}, startupTimeout).Should(Succeed())
+				defer conn.Close()
+
+				otherConn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", proxyPort))
+				Expect(err).NotTo(HaveOccurred())
+				defer otherConn.Close()
+
+				_, err = sendData(conn, "some data")
+				Expect(err).NotTo(HaveOccurred())
+				_, err = sendData(otherConn, "some data")
+				Expect(err).NotTo(HaveOccurred())
+
+				url := fmt.Sprintf("http://localhost:%d/v0/sessions", switchboardAPIPort)
+				req, err := http.NewRequest("GET", url, nil)
+				Expect(err).NotTo(HaveOccurred())
+				req.SetBasicAuth("username", "password")
+
+				var sessionID string
+				for _, s := range getSessionsFromApi(req) {
+					if s["clientAddress"] == conn.LocalAddr().String() {
+						sessionID = s["id"].(string)
+					}
+				}
+				Expect(sessionID).NotTo(BeEmpty())
+
+				req, err = http.NewRequest("DELETE", url+"/"+sessionID, nil)
+				Expect(err).NotTo(HaveOccurred())
+				req.SetBasicAuth("username", "password")
+
+				resp, err := http.DefaultClient.Do(req)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+				Eventually(func() error {
+					_, err := sendData(conn, "data after sever")
+					return err
+				}).Should(matchConnectionDisconnect())
+
+				_, err = sendData(otherConn, "data after other session severed")
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when the active listener has an idle timeout", func() {
+			BeforeEach(func() {
+				rootConfig.Proxy.ActiveListener = config.Listener{IdleTimeoutSeconds: 1}
+			})
+
+			It("closes idle sessions and counts them on the backend", func() {
+				var conn net.Conn
+				Eventually(func() (err error) {
+					conn, err = net.Dial("tcp", fmt.Sprintf("localhost:%d", proxyPort))
+					return err
+				}, startupTimeout).Should(Succeed())
+				defer conn.Close()
+
+				_, err := sendData(conn, "some data")
+				Expect(err).NotTo(HaveOccurred())
+
+				// wait without sending anything, so the session goes idle
+				Expect(conn.SetReadDeadline(time.Now().Add(5 * time.Second))).To(Succeed())
+				_, err = conn.Read(make([]byte, 1024))
+				Expect(err).To(matchConnectionDisconnect())
+
+				url := fmt.Sprintf("http://localhost:%d/v0/backends", switchboardAPIPort)
+				req, err := http.NewRequest("GET", url, nil)
+				Expect(err).NotTo(HaveOccurred())
+				req.SetBasicAuth("username", "password")
+
+				Eventually(func() interface{} {
+					return getBackendsFromApi(req)[0]["closedSessions"]
+				}).Should(HaveKeyWithValue("idleTimeout", BeNumerically("==", 1)))
+			})
+		})
+
 		Describe("/v0/cluster", func() {
 			Describe("GET", func() {
 				It("returns valid JSON in body", func() {
@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/switchboard/domain"
+)
+
+// Metrics are the metrics switchboard exports for Prometheus. Events are
+// recorded as they happen by the bridge runners and cluster monitors, and
+// the state of the backends and cluster is collected at each scrape. Its
+// methods do nothing on a nil *Metrics, so components run without them.
+type Metrics struct {
+	registry *Registry
+
+	backendHealthy        *Vec
+	backendHealthChanges  *Vec
+	backendSessions       *Vec
+	backendBytes          *Vec
+	backendClosedSessions *Vec
+	activeBackend         *Vec
+	failovers             *Vec
+	connectionsAccepted   *Vec
+	connectionsRejected   *Vec
+	healthchecks          *Vec
+	healthcheckDuration   *HistogramVec
+	trafficEnabled        *Vec
+
+	mutex          sync.Mutex
+	activeBackends map[string]string
+}
+
+func New() *Metrics {
+	r := NewRegistry()
+
+	m := &Metrics{
+		registry: r,
+
+		backendHealthy:        r.NewGaugeVec("switchboard_backend_healthy", "Whether the backend passed its latest health checks.", "backend"),
+		backendHealthChanges:  r.NewCounterVec("switchboard_backend_health_changes_total", "Times the backend became healthy or unhealthy.", "backend"),
+		backendSessions:       r.NewGaugeVec("switchboard_backend_sessions", "Sessions currently bridged to the backend.", "backend"),
+		backendBytes:          r.NewCounterVec("switchboard_backend_bytes_total", "Bytes proxied to and from the backend.", "backend", "direction"),
+		backendClosedSessions: r.NewCounterVec("switchboard_backend_closed_sessions_total", "Sessions to the backend that have closed, by reason.", "backend", "reason"),
+		activeBackend:         r.NewGaugeVec("switchboard_active_backend", "Set to 1 for the backend each listener sends new sessions to.", "listener", "backend"),
+		failovers:             r.NewCounterVec("switchboard_failovers_total", "Times a listener moved away from its active backend.", "listener"),
+		connectionsAccepted:   r.NewCounterVec("switchboard_connections_accepted_total", "Client connections admitted by the listener.", "listener"),
+		connectionsRejected:   r.NewCounterVec("switchboard_connections_rejected_total", "Client connections turned away by the listener, by reason.", "listener", "reason"),
+		healthchecks:          r.NewCounterVec("switchboard_healthchecks_total", "Health checks of the backend, by result.", "backend", "result"),
+		healthcheckDuration:   r.NewHistogramVec("switchboard_healthcheck_duration_seconds", "How long health checks of the backend took.", DefaultBuckets, "backend"),
+		trafficEnabled:        r.NewGaugeVec("switchboard_traffic_enabled", "Whether traffic to the cluster is enabled."),
+
+		activeBackends: map[string]string{},
+	}
+
+	r.RegisterCollector(m.collectActiveBackends)
+	return m
+}
+
+// CollectBackends exports the health, sessions and bytes proxied of each
+// backend.
+func (m *Metrics) CollectBackends(backends []*domain.Backend) {
+	m.registry.RegisterCollector(func() {
+		m.backendClosedSessions.Reset()
+
+		for _, backend := range backends {
+			j := backend.AsJSON()
+
+			m.backendHealthy.Set(boolValue(j.Healthy), j.Name)
+			m.backendHealthChanges.Set(float64(j.HealthChanges), j.Name)
+			m.backendSessions.Set(float64(j.CurrentSessionCount), j.Name)
+			m.backendBytes.Set(float64(j.BytesFromClient), j.Name, "fromClient")
+			m.backendBytes.Set(float64(j.BytesToClient), j.Name, "toClient")
+			for closeReason, count := range j.ClosedSessions {
+				m.backendClosedSessions.Set(float64(count), j.Name, closeReason)
+			}
+		}
+	})
+}
+
+// CollectTrafficEnabled exports whether traffic to the cluster is enabled.
+func (m *Metrics) CollectTrafficEnabled(trafficEnabled func() bool) {
+	m.registry.RegisterCollector(func() {
+		m.trafficEnabled.Set(boolValue(trafficEnabled()))
+	})
+}
+
+func (m *Metrics) Handler() http.Handler {
+	return m.registry.Handler()
+}
+
+// ActiveBackendChanged records that the listener moved from previous to
+// current, either of which may be nil. Moving away from a backend counts as a
+// failover.
+func (m *Metrics) ActiveBackendChanged(listener string, previous, current *domain.Backend) {
+	if m == nil {
+		return
+	}
+
+	m.mutex.Lock()
+	if current == nil {
+		delete(m.activeBackends, listener)
+	} else {
+		m.activeBackends[listener] = current.AsJSON().Name
+	}
+	m.mutex.Unlock()
+
+	if previous != nil && previous != current {
+		m.failovers.Inc(listener)
+	}
+}
+
+func (m *Metrics) ConnectionAccepted(listener string) {
+	if m == nil {
+		return
+	}
+	m.connectionsAccepted.Inc(listener)
+}
+
+func (m *Metrics) ConnectionRejected(listener, rejectReason string) {
+	if m == nil {
+		return
+	}
+	m.connectionsRejected.Inc(listener, rejectReason)
+}
+
+func (m *Metrics) HealthcheckCompleted(backend *domain.Backend, healthy bool, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	name := backend.AsJSON().Name
+	result := "unhealthy"
+	if healthy {
+		result = "healthy"
+	}
+
+	m.healthchecks.Inc(name, result)
+	m.healthcheckDuration.Observe(duration.Seconds(), name)
+}
+
+func (m *Metrics) collectActiveBackends() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.activeBackend.Reset()
+	for listener, backend := range m.activeBackends {
+		m.activeBackend.Set(1, listener, backend)
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
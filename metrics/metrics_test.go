@@ -0,0 +1,106 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry-incubator/switchboard/domain"
+	"github.com/cloudfoundry-incubator/switchboard/metrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metrics", func() {
+	var (
+		m                  *metrics.Metrics
+		backend0, backend1 *domain.Backend
+	)
+
+	BeforeEach(func() {
+		logger := lagertest.NewTestLogger("Metrics test")
+		backend0 = domain.NewBackend("backend-0", "10.10.0.2", 3306, 9200, "", logger)
+		backend1 = domain.NewBackend("backend-1", "10.10.1.2", 3306, 9200, "", logger)
+
+		m = metrics.New()
+	})
+
+	scrape := func() string {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest("GET", "/metrics", nil)
+		Expect(err).NotTo(HaveOccurred())
+		m.Handler().ServeHTTP(recorder, request)
+		return recorder.Body.String()
+	}
+
+	It("exports the state of each backend", func() {
+		backend0.SetHealthy()
+		m.CollectBackends([]*domain.Backend{backend0, backend1})
+
+		body := scrape()
+		Expect(body).To(ContainSubstring(`switchboard_backend_healthy{backend="backend-0"} 1`))
+		Expect(body).To(ContainSubstring(`switchboard_backend_healthy{backend="backend-1"} 0`))
+		Expect(body).To(ContainSubstring(`switchboard_backend_sessions{backend="backend-0"} 0`))
+		Expect(body).To(ContainSubstring(`switchboard_backend_bytes_total{backend="backend-0",direction="fromClient"} 0`))
+	})
+
+	It("exports whether traffic is enabled", func() {
+		trafficEnabled := true
+		m.CollectTrafficEnabled(func() bool { return trafficEnabled })
+		Expect(scrape()).To(ContainSubstring("switchboard_traffic_enabled 1\n"))
+
+		trafficEnabled = false
+		Expect(scrape()).To(ContainSubstring("switchboard_traffic_enabled 0\n"))
+	})
+
+	It("exports each listener's active backend and counts failovers", func() {
+		m.ActiveBackendChanged("active", nil, backend0)
+		body := scrape()
+		Expect(body).To(ContainSubstring(`switchboard_active_backend{listener="active",backend="backend-0"} 1`))
+		Expect(body).NotTo(ContainSubstring("switchboard_failovers_total{"))
+
+		m.ActiveBackendChanged("active", backend0, backend1)
+		body = scrape()
+		Expect(body).To(ContainSubstring(`switchboard_active_backend{listener="active",backend="backend-1"} 1`))
+		Expect(body).NotTo(ContainSubstring(`switchboard_active_backend{listener="active",backend="backend-0"}`))
+		Expect(body).To(ContainSubstring(`switchboard_failovers_total{listener="active"} 1`))
+
+		m.ActiveBackendChanged("active", backend1, nil)
+		body = scrape()
+		Expect(body).NotTo(ContainSubstring(`switchboard_active_backend{`))
+		Expect(body).To(ContainSubstring(`switchboard_failovers_total{listener="active"} 2`))
+	})
+
+	It("counts accepted and rejected connections", func() {
+		m.ConnectionAccepted("active")
+		m.ConnectionAccepted("active")
+		m.ConnectionRejected("active", domain.RejectReasonTrafficDisabled)
+
+		body := scrape()
+		Expect(body).To(ContainSubstring(`switchboard_connections_accepted_total{listener="active"} 2`))
+		Expect(body).To(ContainSubstring(`switchboard_connections_rejected_total{listener="active",reason="trafficDisabled"} 1`))
+	})
+
+	It("records health check results and durations", func() {
+		m.HealthcheckCompleted(backend0, true, 20*time.Millisecond)
+		m.HealthcheckCompleted(backend0, false, 2*time.Second)
+
+		body := scrape()
+		Expect(body).To(ContainSubstring(`switchboard_healthchecks_total{backend="backend-0",result="healthy"} 1`))
+		Expect(body).To(ContainSubstring(`switchboard_healthchecks_total{backend="backend-0",result="unhealthy"} 1`))
+		Expect(body).To(ContainSubstring(`switchboard_healthcheck_duration_seconds_bucket{backend="backend-0",le="0.025"} 1`))
+		Expect(body).To(ContainSubstring(`switchboard_healthcheck_duration_seconds_count{backend="backend-0"} 2`))
+	})
+
+	It("does nothing on a nil Metrics", func() {
+		var nilMetrics *metrics.Metrics
+
+		Expect(func() {
+			nilMetrics.ActiveBackendChanged("active", nil, backend0)
+			nilMetrics.ConnectionAccepted("active")
+			nilMetrics.ConnectionRejected("active", domain.RejectReasonRateLimited)
+			nilMetrics.HealthcheckCompleted(backend0, true, time.Millisecond)
+		}).NotTo(Panic())
+	})
+})
@@ -0,0 +1,382 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the buckets latencies
+// are counted in.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry holds metric families and writes them in the Prometheus text
+// exposition format, version 0.0.4.
+type Registry struct {
+	mutex      sync.Mutex
+	families   []family
+	collectors []func()
+}
+
+type family interface {
+	write(w *bufio.Writer)
+	samples() []Sample
+}
+
+// Sample is the value of one series, for pushing metrics to systems other
+// than Prometheus.
+type Sample struct {
+	Name        string
+	Kind        string
+	LabelNames  []string
+	LabelValues []string
+	Value       float64
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// RegisterCollector adds a function that is called before each scrape, to
+// set metrics from state that is kept elsewhere.
+func (r *Registry) RegisterCollector(collect func()) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.collectors = append(r.collectors, collect)
+}
+
+func (r *Registry) NewCounterVec(name, help string, labelNames ...string) *Vec {
+	return r.newVec("counter", name, help, labelNames)
+}
+
+func (r *Registry) NewGaugeVec(name, help string, labelNames ...string) *Vec {
+	return r.newVec("gauge", name, help, labelNames)
+}
+
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	h := &HistogramVec{
+		name:       name,
+		help:       help,
+		buckets:    append([]float64(nil), buckets...),
+		labelNames: labelNames,
+		series:     map[string]*histogramSeries{},
+	}
+	sort.Float64s(h.buckets)
+	r.register(h)
+	return h
+}
+
+func (r *Registry) newVec(kind, name, help string, labelNames []string) *Vec {
+	v := &Vec{
+		kind:       kind,
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		series:     map[string]*series{},
+	}
+	r.register(v)
+	return v
+}
+
+func (r *Registry) register(f family) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.families = append(r.families, f)
+}
+
+// Write runs the collectors and writes every family in the order it was
+// registered.
+func (r *Registry) Write(w io.Writer) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, collect := range r.collectors {
+		collect()
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, f := range r.families {
+		f.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Samples runs the collectors and returns the value of every series. A
+// histogram is given as the counters of its sum and count.
+func (r *Registry) Samples() []Sample {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, collect := range r.collectors {
+		collect()
+	}
+
+	var samples []Sample
+	for _, f := range r.families {
+		samples = append(samples, f.samples()...)
+	}
+	return samples
+}
+
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = r.Write(w)
+	})
+}
+
+// Vec is a counter or gauge with a series for each combination of label
+// values.
+type Vec struct {
+	kind       string
+	name       string
+	help       string
+	labelNames []string
+
+	mutex  sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+}
+
+func (v *Vec) Inc(labelValues ...string) {
+	v.Add(1, labelValues...)
+}
+
+func (v *Vec) Add(delta float64, labelValues ...string) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	v.unsafeSeries(labelValues).value += delta
+}
+
+// Set sets the value of a gauge, or of a counter whose total is kept
+// elsewhere and set by a collector.
+func (v *Vec) Set(value float64, labelValues ...string) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	v.unsafeSeries(labelValues).value = value
+}
+
+// Reset removes every series, e.g. so that a collector only exports the
+// label values that still exist.
+func (v *Vec) Reset() {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	v.series = map[string]*series{}
+}
+
+func (v *Vec) unsafeSeries(labelValues []string) *series {
+	checkLabels(v.name, v.labelNames, labelValues)
+
+	key := seriesKey(labelValues)
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		v.series[key] = s
+	}
+	return s
+}
+
+func (v *Vec) write(w *bufio.Writer) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	var keys []string
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	writeHeader(w, v.name, v.help, v.kind)
+	for _, key := range keys {
+		s := v.series[key]
+		writeSample(w, v.name, v.labelNames, s.labelValues, "", "", s.value)
+	}
+}
+
+func (v *Vec) samples() []Sample {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	var keys []string
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var samples []Sample
+	for _, key := range keys {
+		s := v.series[key]
+		samples = append(samples, Sample{
+			Name:        v.name,
+			Kind:        v.kind,
+			LabelNames:  v.labelNames,
+			LabelValues: s.labelValues,
+			Value:       s.value,
+		})
+	}
+	return samples
+}
+
+// HistogramVec counts observations in buckets, with a series for each
+// combination of label values.
+type HistogramVec struct {
+	name       string
+	help       string
+	buckets    []float64
+	labelNames []string
+
+	mutex  sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labelValues  []string
+	bucketCounts []uint64
+	count        uint64
+	sum          float64
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	checkLabels(h.name, h.labelNames, labelValues)
+
+	key := seriesKey(labelValues)
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{
+			labelValues:  append([]string(nil), labelValues...),
+			bucketCounts: make([]uint64, len(h.buckets)),
+		}
+		h.series[key] = s
+	}
+
+	for i, upperBound := range h.buckets {
+		if value <= upperBound {
+			s.bucketCounts[i]++
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var keys []string
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	writeHeader(w, h.name, h.help, "histogram")
+	for _, key := range keys {
+		s := h.series[key]
+		for i, upperBound := range h.buckets {
+			writeSample(w, h.name+"_bucket", h.labelNames, s.labelValues, "le", formatValue(upperBound), float64(s.bucketCounts[i]))
+		}
+		writeSample(w, h.name+"_bucket", h.labelNames, s.labelValues, "le", "+Inf", float64(s.count))
+		writeSample(w, h.name+"_sum", h.labelNames, s.labelValues, "", "", s.sum)
+		writeSample(w, h.name+"_count", h.labelNames, s.labelValues, "", "", float64(s.count))
+	}
+}
+
+func (h *HistogramVec) samples() []Sample {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var keys []string
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var samples []Sample
+	for _, key := range keys {
+		s := h.series[key]
+		samples = append(samples,
+			Sample{Name: h.name + "_sum", Kind: "counter", LabelNames: h.labelNames, LabelValues: s.labelValues, Value: s.sum},
+			Sample{Name: h.name + "_count", Kind: "counter", LabelNames: h.labelNames, LabelValues: s.labelValues, Value: float64(s.count)},
+		)
+	}
+	return samples
+}
+
+func checkLabels(name string, labelNames, labelValues []string) {
+	if len(labelNames) != len(labelValues) {
+		panic(fmt.Sprintf("metric %s has %d labels, got %d values", name, len(labelNames), len(labelValues)))
+	}
+}
+
+func seriesKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+func writeHeader(w *bufio.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+// writeSample writes one line, with an extra label after the series' own
+// when extraName is set, e.g. a histogram bucket's le.
+func writeSample(w *bufio.Writer, name string, labelNames, labelValues []string, extraName, extraValue string, value float64) {
+	w.WriteString(name)
+
+	var labels []string
+	for i, labelName := range labelNames {
+		labels = append(labels, fmt.Sprintf("%s=\"%s\"", labelName, escapeLabelValue(labelValues[i])))
+	}
+	if extraName != "" {
+		labels = append(labels, fmt.Sprintf("%s=\"%s\"", extraName, extraValue))
+	}
+	if len(labels) > 0 {
+		w.WriteString("{" + strings.Join(labels, ",") + "}")
+	}
+
+	w.WriteString(" " + formatValue(value) + "\n")
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
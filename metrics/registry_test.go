@@ -0,0 +1,126 @@
+package metrics_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/cloudfoundry-incubator/switchboard/metrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry", func() {
+	var registry *metrics.Registry
+
+	BeforeEach(func() {
+		registry = metrics.NewRegistry()
+	})
+
+	write := func() string {
+		var b bytes.Buffer
+		Expect(registry.Write(&b)).To(Succeed())
+		return b.String()
+	}
+
+	It("writes counters and gauges in the text exposition format", func() {
+		counter := registry.NewCounterVec("requests_total", "Requests served.", "code", "method")
+		gauge := registry.NewGaugeVec("up", "Whether it is up.")
+
+		counter.Inc("200", "GET")
+		counter.Add(2, "200", "GET")
+		counter.Inc("500", "POST")
+		gauge.Set(1)
+
+		Expect(write()).To(Equal(`# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{code="200",method="GET"} 3
+requests_total{code="500",method="POST"} 1
+# HELP up Whether it is up.
+# TYPE up gauge
+up 1
+`))
+	})
+
+	It("writes histograms with cumulative buckets", func() {
+		histogram := registry.NewHistogramVec("latency_seconds", "Latency.", []float64{1, 0.1}, "backend")
+
+		histogram.Observe(0.05, "b0")
+		histogram.Observe(0.5, "b0")
+		histogram.Observe(2, "b0")
+
+		Expect(write()).To(Equal(`# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{backend="b0",le="0.1"} 1
+latency_seconds_bucket{backend="b0",le="1"} 2
+latency_seconds_bucket{backend="b0",le="+Inf"} 3
+latency_seconds_sum{backend="b0"} 2.55
+latency_seconds_count{backend="b0"} 3
+`))
+	})
+
+	It("escapes label values and help text", func() {
+		gauge := registry.NewGaugeVec("info", "Says \\ and\nmore.", "name")
+		gauge.Set(1, "a \"quoted\" \\ name\n")
+
+		Expect(write()).To(ContainSubstring(`# HELP info Says \\ and\nmore.`))
+		Expect(write()).To(ContainSubstring(`info{name="a \"quoted\" \\ name\n"} 1`))
+	})
+
+	It("runs the collectors before each scrape", func() {
+		gauge := registry.NewGaugeVec("value", "A value.")
+		value := 0.0
+		registry.RegisterCollector(func() {
+			value++
+			gauge.Set(value)
+		})
+
+		Expect(write()).To(ContainSubstring("value 1\n"))
+		Expect(write()).To(ContainSubstring("value 2\n"))
+	})
+
+	It("drops series on Reset", func() {
+		gauge := registry.NewGaugeVec("value", "A value.", "name")
+		gauge.Set(1, "old")
+		gauge.Reset()
+		gauge.Set(1, "new")
+
+		Expect(write()).NotTo(ContainSubstring("old"))
+	})
+
+	It("returns every series as samples, histograms as their sum and count", func() {
+		gauge := registry.NewGaugeVec("up", "Whether it is up.", "instance")
+		histogram := registry.NewHistogramVec("latency_seconds", "Latency.", []float64{1}, "path")
+
+		gauge.Set(1, "a")
+		histogram.Observe(0.5, "/")
+		histogram.Observe(2, "/")
+
+		Expect(registry.Samples()).To(Equal([]metrics.Sample{
+			{Name: "up", Kind: "gauge", LabelNames: []string{"instance"}, LabelValues: []string{"a"}, Value: 1},
+			{Name: "latency_seconds_sum", Kind: "counter", LabelNames: []string{"path"}, LabelValues: []string{"/"}, Value: 2.5},
+			{Name: "latency_seconds_count", Kind: "counter", LabelNames: []string{"path"}, LabelValues: []string{"/"}, Value: 2},
+		}))
+	})
+
+	It("panics on the wrong number of label values", func() {
+		counter := registry.NewCounterVec("requests_total", "Requests served.", "code")
+
+		Expect(func() { counter.Inc() }).To(Panic())
+	})
+
+	Describe("Handler", func() {
+		It("serves the metrics with the exposition content type", func() {
+			registry.NewGaugeVec("up", "Whether it is up.").Set(1)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest("GET", "/metrics", nil)
+			Expect(err).NotTo(HaveOccurred())
+			registry.Handler().ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("text/plain; version=0.0.4; charset=utf-8"))
+			Expect(recorder.Body.String()).To(ContainSubstring("up 1\n"))
+		})
+	})
+})
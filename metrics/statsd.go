@@ -0,0 +1,159 @@
+package metrics
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/switchboard/config"
+)
+
+// maxPacketSize keeps each datagram within the MTU of most networks, as
+// recommended for StatsD.
+const maxPacketSize = 1432
+
+// StatsdEmitter pushes the metrics to a StatsD or DogStatsD agent over UDP.
+// Gauges are sent with their current value on every push. Counters are sent
+// with how much they grew past the highest value already pushed, since
+// StatsD agents sum the counts they receive.
+type StatsdEmitter struct {
+	registry *Registry
+	prefix   string
+	format   string
+	conn     net.Conn
+
+	mutex    sync.Mutex
+	counters map[statsdMetric]float64
+	gauges   map[statsdMetric]bool
+}
+
+// statsdMetric is the name and tags of a series, e.g.
+// switchboard.backend_sessions and |#backend:mysql-0 for DogStatsD. Plain
+// StatsD has no tags, so the label values are appended to the name instead,
+// e.g. switchboard.backend_sessions.mysql-0.
+type statsdMetric struct {
+	name string
+	tags string
+}
+
+func NewStatsdEmitter(m *Metrics, metricsConfig config.Metrics) (*StatsdEmitter, error) {
+	conn, err := net.Dial("udp", metricsConfig.StatsdAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	format := metricsConfig.StatsdFormat
+	if format == "" {
+		format = config.StatsdFormatDogStatsD
+	}
+
+	return &StatsdEmitter{
+		registry: m.registry,
+		prefix:   metricsConfig.StatsdPrefix,
+		format:   format,
+		conn:     conn,
+		counters: map[statsdMetric]float64{},
+		gauges:   map[statsdMetric]bool{},
+	}, nil
+}
+
+// Emit sends every metric to the agent once.
+func (e *StatsdEmitter) Emit() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	var lines []string
+	gauges := map[statsdMetric]bool{}
+
+	for _, sample := range e.registry.Samples() {
+		metric := e.metric(sample)
+
+		switch sample.Kind {
+		case "gauge":
+			gauges[metric] = true
+			lines = append(lines, e.line(metric, sample.Value, "g"))
+		default:
+			// counters only reset when switchboard restarts, and so does
+			// the emitter, so one that went down merely dipped; nothing is
+			// sent until it passes the highest value already sent
+			if delta := sample.Value - e.counters[metric]; delta > 0 {
+				e.counters[metric] = sample.Value
+				lines = append(lines, e.line(metric, delta, "c"))
+			}
+		}
+	}
+
+	// a series that is gone, such as a listener's former active backend,
+	// is zeroed once so that agents do not keep reporting its last value
+	for metric := range e.gauges {
+		if !gauges[metric] {
+			lines = append(lines, e.line(metric, 0, "g"))
+		}
+	}
+	e.gauges = gauges
+
+	return e.send(lines)
+}
+
+func (e *StatsdEmitter) Close() error {
+	return e.conn.Close()
+}
+
+func (e *StatsdEmitter) metric(sample Sample) statsdMetric {
+	metric := statsdMetric{name: e.prefix + statsdName(sample.Name)}
+
+	if e.format == config.StatsdFormatStatsD {
+		for _, labelValue := range sample.LabelValues {
+			metric.name += "." + statsdNameEscaper.Replace(labelValue)
+		}
+		return metric
+	}
+
+	var tags []string
+	for i, labelName := range sample.LabelNames {
+		tags = append(tags, labelName+":"+dogstatsdTagEscaper.Replace(sample.LabelValues[i]))
+	}
+	if len(tags) > 0 {
+		metric.tags = "|#" + strings.Join(tags, ",")
+	}
+	return metric
+}
+
+func (e *StatsdEmitter) line(metric statsdMetric, value float64, kind string) string {
+	return metric.name + ":" + strconv.FormatFloat(value, 'f', -1, 64) + "|" + kind + metric.tags
+}
+
+// send batches the lines into as few datagrams as fit them.
+func (e *StatsdEmitter) send(lines []string) error {
+	var packet []byte
+	for _, line := range lines {
+		if len(packet) > 0 && len(packet)+1+len(line) > maxPacketSize {
+			if _, err := e.conn.Write(packet); err != nil {
+				return err
+			}
+			packet = packet[:0]
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+
+	if len(packet) > 0 {
+		if _, err := e.conn.Write(packet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statsdName turns a Prometheus metric name into a StatsD one, e.g.
+// switchboard_failovers_total into switchboard.failovers.
+func statsdName(name string) string {
+	name = strings.TrimSuffix(name, "_total")
+	return strings.Replace(name, "switchboard_", "switchboard.", 1)
+}
+
+var statsdNameEscaper = strings.NewReplacer(".", "_", ":", "_", "|", "_", "@", "_", "#", "_", " ", "_", "\n", "_")
+var dogstatsdTagEscaper = strings.NewReplacer(",", "_", "|", "_", "#", "_", " ", "_", "\n", "_")
@@ -0,0 +1,140 @@
+package metrics_test
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry-incubator/switchboard/config"
+	"github.com/cloudfoundry-incubator/switchboard/domain"
+	"github.com/cloudfoundry-incubator/switchboard/metrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StatsdEmitter", func() {
+	var (
+		m             *metrics.Metrics
+		agent         net.PacketConn
+		metricsConfig config.Metrics
+		emitter       *metrics.StatsdEmitter
+		backend       *domain.Backend
+		backends      []*domain.Backend
+	)
+
+	BeforeEach(func() {
+		var err error
+		agent, err = net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+
+		logger := lagertest.NewTestLogger("StatsdEmitter test")
+		backend = domain.NewBackend("backend-0", "10.10.0.2", 3306, 9200, "", logger)
+
+		m = metrics.New()
+		backends = []*domain.Backend{backend}
+		m.CollectBackends(backends)
+		metricsConfig = config.Metrics{StatsdAddress: agent.LocalAddr().String()}
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		emitter, err = metrics.NewStatsdEmitter(m, metricsConfig)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(emitter.Close()).To(Succeed())
+		Expect(agent.Close()).To(Succeed())
+	})
+
+	// receive reads the lines of every datagram sent by one Emit
+	receive := func() []string {
+		var lines []string
+		buf := make([]byte, 65536)
+		for {
+			Expect(agent.SetReadDeadline(time.Now().Add(100 * time.Millisecond))).To(Succeed())
+			n, _, err := agent.ReadFrom(buf)
+			if err != nil {
+				return lines
+			}
+			lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+		}
+	}
+
+	It("sends gauges with DogStatsD tags", func() {
+		backend.SetHealthy()
+
+		Expect(emitter.Emit()).To(Succeed())
+		lines := receive()
+		Expect(lines).To(ContainElement("switchboard.backend_healthy:1|g|#backend:backend-0"))
+		Expect(lines).To(ContainElement("switchboard.backend_sessions:0|g|#backend:backend-0"))
+	})
+
+	It("sends how much each counter grew since the last push", func() {
+		m.ConnectionRejected("active", domain.RejectReasonMaxConnections)
+		m.ConnectionRejected("active", domain.RejectReasonMaxConnections)
+		m.ActiveBackendChanged("active", nil, backend)
+		backend.SetHealthy()
+
+		Expect(emitter.Emit()).To(Succeed())
+		lines := receive()
+		Expect(lines).To(ContainElement("switchboard.connections_rejected:2|c|#listener:active,reason:maxConnections"))
+		Expect(lines).To(ContainElement("switchboard.backend_health_changes:1|c|#backend:backend-0"))
+		Expect(lines).To(ContainElement("switchboard.active_backend:1|g|#listener:active,backend:backend-0"))
+
+		m.ConnectionRejected("active", domain.RejectReasonMaxConnections)
+		m.ActiveBackendChanged("active", backend, nil)
+
+		Expect(emitter.Emit()).To(Succeed())
+		lines = receive()
+		Expect(lines).To(ContainElement("switchboard.connections_rejected:1|c|#listener:active,reason:maxConnections"))
+		Expect(lines).To(ContainElement("switchboard.failovers:1|c|#listener:active"))
+		Expect(lines).To(ContainElement("switchboard.active_backend:0|g|#listener:active,backend:backend-0"))
+		Expect(lines).NotTo(ContainElement(ContainSubstring("switchboard.backend_health_changes")))
+
+		Expect(emitter.Emit()).To(Succeed())
+		Expect(receive()).NotTo(ContainElement(ContainSubstring("switchboard.active_backend")))
+	})
+
+	It("sends nothing for a counter that went down until it passes its highest value", func() {
+		backend.SetHealthy()
+		backend.SetUnhealthy()
+		backend.SetHealthy()
+
+		Expect(emitter.Emit()).To(Succeed())
+		Expect(receive()).To(ContainElement("switchboard.backend_health_changes:3|c|#backend:backend-0"))
+
+		// the collector now reads a backend whose count is lower
+		logger := lagertest.NewTestLogger("StatsdEmitter test")
+		backends[0] = domain.NewBackend("backend-0", "10.10.0.2", 3306, 9200, "", logger)
+		backends[0].SetHealthy()
+
+		Expect(emitter.Emit()).To(Succeed())
+		Expect(receive()).NotTo(ContainElement(ContainSubstring("switchboard.backend_health_changes")))
+
+		for i := 0; i < 3; i++ {
+			backends[0].SetUnhealthy()
+			backends[0].SetHealthy()
+		}
+
+		Expect(emitter.Emit()).To(Succeed())
+		Expect(receive()).To(ContainElement("switchboard.backend_health_changes:4|c|#backend:backend-0"))
+	})
+
+	Context("when the format is plain StatsD", func() {
+		BeforeEach(func() {
+			metricsConfig.StatsdFormat = config.StatsdFormatStatsD
+			metricsConfig.StatsdPrefix = "prod."
+		})
+
+		It("appends the label values to the name", func() {
+			m.ConnectionRejected("active", domain.RejectReasonMaxConnections)
+
+			Expect(emitter.Emit()).To(Succeed())
+			lines := receive()
+			Expect(lines).To(ContainElement("prod.switchboard.connections_rejected.active.maxConnections:1|c"))
+			Expect(lines).To(ContainElement("prod.switchboard.backend_healthy.backend-0:0|g"))
+		})
+	})
+})
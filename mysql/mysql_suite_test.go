@@ -0,0 +1,13 @@
+package mysql_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestMySQL(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "MySQL Suite")
+}
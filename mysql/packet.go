@@ -0,0 +1,82 @@
+// Package mysql holds the parts of the MySQL client/server protocol that
+// switchboard speaks itself: packet framing and the values exchanged in a
+// handshake. It is shared by the rejection of clients with an ERR packet and
+// the MySQL health check.
+package mysql
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	clientLongPassword     = 0x00000001
+	clientProtocol41       = 0x00000200
+	clientTransactions     = 0x00002000
+	clientSecureConnection = 0x00008000
+	clientPluginAuth       = 0x00080000
+)
+
+const (
+	// Capabilities are the capability flags switchboard offers in a
+	// handshake: the 4.1 protocol with pluggable authentication.
+	Capabilities = clientLongPassword | clientProtocol41 | clientTransactions |
+		clientSecureConnection | clientPluginAuth
+
+	CharsetUTF8            = 33
+	ServerStatusAutocommit = 0x0002
+	NativePassword         = "mysql_native_password"
+
+	ComQuit  = 0x01
+	ComQuery = 0x03
+
+	// the first byte of a packet's payload tells an OK, EOF or ERR packet
+	// apart from the rest
+	OKPacket  = 0x00
+	EOFPacket = 0xfe
+	ErrPacket = 0xff
+
+	// MaxPacketSize is the largest payload a single packet can carry.
+	MaxPacketSize = 1<<24 - 1
+
+	// MaxHandshakePacketSize caps the packets read while logging in or
+	// being rejected, which are far smaller than MaxPacketSize, so that a
+	// peer cannot have switchboard buffer megabytes for each handshake.
+	MaxHandshakePacketSize = 1 << 16
+)
+
+// WritePacket writes payload as one packet with the sequence number.
+func WritePacket(w io.Writer, sequence byte, payload []byte) error {
+	length := len(payload)
+	if length > MaxPacketSize {
+		return errors.New(fmt.Sprintf("Packet of %d bytes is too long", length))
+	}
+	header := []byte{byte(length), byte(length >> 8), byte(length >> 16), sequence}
+
+	_, err := w.Write(append(header, payload...))
+	return err
+}
+
+// ReadPacket reads one packet and returns its payload and sequence number.
+// A payload longer than maxLength is refused before it is read.
+func ReadPacket(r io.Reader, maxLength int) ([]byte, byte, error) {
+	header := make([]byte, 4)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	if length > maxLength {
+		return nil, 0, errors.New(fmt.Sprintf("Packet of %d bytes exceeds the limit of %d", length, maxLength))
+	}
+
+	payload := make([]byte, length)
+	_, err = io.ReadFull(r, payload)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return payload, header[3], nil
+}
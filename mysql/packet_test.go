@@ -0,0 +1,36 @@
+package mysql_test
+
+import (
+	"bytes"
+
+	"github.com/cloudfoundry-incubator/switchboard/mysql"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Packets", func() {
+	It("reads back what was written", func() {
+		buffer := new(bytes.Buffer)
+		Expect(mysql.WritePacket(buffer, 3, []byte("payload"))).To(Succeed())
+		Expect(buffer.Bytes()[:4]).To(Equal([]byte{7, 0, 0, 3}))
+
+		payload, sequence, err := mysql.ReadPacket(buffer, mysql.MaxHandshakePacketSize)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(payload).To(Equal([]byte("payload")))
+		Expect(sequence).To(BeNumerically("==", 3))
+	})
+
+	It("refuses a packet longer than the limit before reading it", func() {
+		buffer := bytes.NewBuffer([]byte{0xff, 0xff, 0xff, 0})
+
+		_, _, err := mysql.ReadPacket(buffer, mysql.MaxHandshakePacketSize)
+		Expect(err).To(MatchError(ContainSubstring("exceeds the limit")))
+	})
+
+	It("returns an error for a truncated packet", func() {
+		buffer := bytes.NewBuffer([]byte{7, 0, 0, 0, 'p', 'a'})
+
+		_, _, err := mysql.ReadPacket(buffer, mysql.MaxHandshakePacketSize)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -4,23 +4,68 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"sync"
 	"time"
 
 	"code.cloudfoundry.org/lager"
 	"github.com/cloudfoundry-incubator/switchboard/domain"
+	"github.com/cloudfoundry-incubator/switchboard/events"
+	"github.com/cloudfoundry-incubator/switchboard/metrics"
 )
 
 type Runner struct {
 	logger             lager.Logger
+	listener           domain.Listener
 	port               uint
 	TrafficEnabledChan chan bool
 	ActiveBackendChan  chan *domain.Backend
+	ClusterMessage     func() string
 	timeout            time.Duration
+	drainTimeout       time.Duration
+	retriedBackends    *retriedBackends
+
+	// BackendFailed is told about backends that clients could not be
+	// connected to, and HealthyBackends offers the backends to retry them on
+	// when the listener allows it, most preferred first.
+	BackendFailed   func(*domain.Backend)
+	HealthyBackends func() []*domain.Backend
+
+	// Metrics records the listener's connections and active backend changes.
+	Metrics *metrics.Metrics
+}
+
+// retriedBackends are the backends other than the active one that clients
+// were retried on. Their sessions from the listener are severed along with
+// the active backend's when traffic is disabled.
+type retriedBackends struct {
+	mutex    sync.Mutex
+	backends map[*domain.Backend]bool
+}
+
+func (t *retriedBackends) add(backend *domain.Backend) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.backends[backend] = true
+}
+
+func (t *retriedBackends) takeAll() []*domain.Backend {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var backends []*domain.Backend
+	for backend := range t.backends {
+		backends = append(backends, backend)
+	}
+	t.backends = map[*domain.Backend]bool{}
+	return backends
 }
 
 func NewRunner(
+	listener domain.Listener,
 	port uint,
 	timeout time.Duration,
+	drainTimeout time.Duration,
 	logger lager.Logger,
 ) Runner {
 	backendChan := make(chan *domain.Backend)
@@ -28,10 +73,13 @@ func NewRunner(
 
 	return Runner{
 		logger:             logger,
+		listener:           listener,
 		ActiveBackendChan:  backendChan,
 		TrafficEnabledChan: trafficEnabledChan,
 		port:               port,
 		timeout:            timeout,
+		drainTimeout:       drainTimeout,
+		retriedBackends:    &retriedBackends{backends: map[*domain.Backend]bool{}},
 	}
 }
 
@@ -47,6 +95,7 @@ func (r Runner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 	go func(shutdown <-chan interface{}, listener net.Listener) {
 		trafficEnabled := true
 		var activeBackend *domain.Backend
+		drainingBackends := map[*domain.Backend]bool{}
 		e := make(chan error)
 		c := make(chan net.Conn)
 
@@ -59,7 +108,18 @@ func (r Runner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 				// ENABLED -> DISABLED
 				if trafficEnabled && !t {
 					if activeBackend != nil {
-						activeBackend.SeverConnections()
+						activeBackend.SeverConnections(events.SeverReasonTrafficDisabled)
+					}
+
+					for b := range drainingBackends {
+						b.FinishDrain(r.listener.Name)
+					}
+					drainingBackends = map[*domain.Backend]bool{}
+
+					for _, b := range r.retriedBackends.takeAll() {
+						if b != activeBackend {
+							b.SeverListenerSessions(r.listener.Name, events.SeverReasonTrafficDisabled)
+						}
 					}
 				}
 
@@ -67,12 +127,32 @@ func (r Runner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 
 			case a := <-r.ActiveBackendChan:
 				// NEW ACTIVE BACKEND
+				r.Metrics.ActiveBackendChanged(r.listener.Name, activeBackend, a)
+
 				if activeBackend != nil {
-					activeBackend.SeverConnections()
+					// a planned switch away from a healthy backend lets
+					// its sessions finish, a failed backend or one taken
+					// into maintenance is cut off
+					adminState := activeBackend.AdminState()
+					switch {
+					case !activeBackend.Healthy() || adminState == domain.AdminStateMaintenance:
+						activeBackend.SeverConnections(events.SeverReasonActiveBackendChanged)
+					case r.drainTimeout > 0:
+						activeBackend.Drain(r.listener.Name, r.drainTimeout)
+						drainingBackends[activeBackend] = true
+					case adminState == domain.AdminStateDrain:
+						// an operator draining the backend waits for its
+						// sessions to finish on their own
+					default:
+						activeBackend.SeverConnections(events.SeverReasonActiveBackendChanged)
+					}
 				}
 
 				activeBackend = a
 				if a != nil {
+					a.CancelDrain(r.listener.Name)
+					delete(drainingBackends, a)
+
 					r.logger.Info("Done severing connections, new active backend:", lager.Data{"backend": a.AsJSON()})
 				} else {
 					r.logger.Info("Done severing connections, new active backend:", lager.Data{"backend": nil})
@@ -80,23 +160,52 @@ func (r Runner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 
 			case clientConn := <-c:
 				if !trafficEnabled {
-					clientConn.Close()
+					if release, ok := r.reserve(clientConn); ok {
+						go r.rejectWhileDisabled(clientConn, release)
+					}
 					continue
 				}
 
-				go func(clientConn net.Conn, activeBackend *domain.Backend) {
-					if activeBackend == nil {
-						clientConn.Close()
-						r.logger.Error("No active backend", err)
-						return
+				if r.listener.AcceptProxyProtocol {
+					releaseReservation, ok := r.reserve(clientConn)
+					if !ok {
+						continue
 					}
 
-					err := activeBackend.Bridge(clientConn)
-					if err != nil {
-						clientConn.Close()
-						r.logger.Error("Error routing to backend", err)
+					// the real client is only known once its PROXY
+					// protocol header has been read
+					go func(clientConn net.Conn, activeBackend *domain.Backend, releaseReservation func()) {
+						proxiedConn, err := r.listener.ReadProxyHeader(clientConn)
+						if err != nil {
+							releaseReservation()
+							clientConn.Close()
+							r.logger.Error("Error reading PROXY protocol header", err, lager.Data{"client": clientConn.RemoteAddr().String()})
+							return
+						}
+
+						if activeBackend == nil {
+							r.rejectWithoutBackend(proxiedConn, releaseReservation)
+							return
+						}
+
+						releaseReservation()
+						if release, ok := r.admit(proxiedConn); ok {
+							r.bridge(proxiedConn, activeBackend, release)
+						}
+					}(clientConn, activeBackend, releaseReservation)
+					continue
+				}
+
+				if activeBackend == nil {
+					if release, ok := r.reserve(clientConn); ok {
+						go r.rejectWithoutBackend(clientConn, release)
 					}
-				}(clientConn, activeBackend)
+					continue
+				}
+
+				if release, ok := r.admit(clientConn); ok {
+					go r.bridge(clientConn, activeBackend, release)
+				}
 			case err := <-e:
 				if err != nil {
 					r.logger.Error("Error accepting client connection", err)
@@ -120,6 +229,180 @@ func (r Runner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 	return nil
 }
 
+func (r Runner) admit(clientConn net.Conn) (func(), bool) {
+	release, rejectReason := r.listener.Admit(clientConn.RemoteAddr())
+	if rejectReason != "" {
+		r.turnAway(clientConn, rejectReason)
+		return nil, false
+	}
+
+	r.Metrics.ConnectionAccepted(r.listener.Name)
+	return release, true
+}
+
+// reserve holds a place against the listener's connection limit for a
+// connection that waits on the client in its own goroutine before it is
+// admitted or rejected, so that a flood of such connections is turned away
+// at once rather than each tying up a goroutine and a file descriptor.
+func (r Runner) reserve(clientConn net.Conn) (func(), bool) {
+	release, rejectReason := r.listener.Reserve()
+	if rejectReason != "" {
+		r.turnAway(clientConn, rejectReason)
+		return nil, false
+	}
+
+	return release, true
+}
+
+// turnAway closes a connection that Admit or Reserve rejected, which have
+// already counted it on the listener.
+func (r Runner) turnAway(clientConn net.Conn, rejectReason string) {
+	clientConn.Close()
+	r.logRejection(clientConn, rejectReason)
+}
+
+// countRejection counts a connection rejected for a reason the listener's
+// admission does not decide, so that the listener reports every rejection.
+func (r Runner) countRejection(clientConn net.Conn, rejectReason string) {
+	r.listener.CountRejection(rejectReason)
+	r.logRejection(clientConn, rejectReason)
+}
+
+func (r Runner) logRejection(clientConn net.Conn, rejectReason string) {
+	r.logger.Info("Rejected client connection", lager.Data{
+		"client": clientConn.RemoteAddr().String(),
+		"reason": rejectReason,
+	})
+	r.Metrics.ConnectionRejected(r.listener.Name, rejectReason)
+}
+
+// rejectWhileDisabled turns away a connection that was accepted while
+// traffic is disabled, after reading its PROXY protocol header if one is
+// expected. It must not run on the accept loop: the cluster message is
+// locked while traffic is being disabled. The connection's reserved place
+// is released once it has been turned away.
+func (r Runner) rejectWhileDisabled(clientConn net.Conn, release func()) {
+	defer release()
+
+	proxiedConn, err := r.listener.ReadProxyHeader(clientConn)
+	if err != nil {
+		r.countRejection(clientConn, domain.RejectReasonTrafficDisabled)
+		clientConn.Close()
+		return
+	}
+	r.countRejection(proxiedConn, domain.RejectReasonTrafficDisabled)
+
+	message := "Traffic to the cluster is disabled"
+	if r.ClusterMessage != nil {
+		if clusterMessage := r.ClusterMessage(); clusterMessage != "" {
+			message = clusterMessage
+		}
+	}
+
+	r.reject(proxiedConn, message)
+}
+
+// reject turns a client connection away with message. A listener that
+// terminates TLS completes the handshake first, as its clients expect a
+// ServerHello and would not understand a plaintext ERR packet; there is no
+// point in doing so when the listener only closes rejected connections.
+func (r Runner) reject(clientConn net.Conn, message string) {
+	if !r.listener.RejectWithMySQLError {
+		clientConn.Close()
+		return
+	}
+
+	decryptedConn, err := r.listener.TerminateTLS(clientConn)
+	if err != nil {
+		clientConn.Close()
+		return
+	}
+
+	r.listener.Reject(decryptedConn, message)
+}
+
+// rejectWithoutBackend turns away a connection accepted while the listener
+// has no active backend, before it is admitted. The connection's reserved
+// place is released once it has been turned away.
+func (r Runner) rejectWithoutBackend(clientConn net.Conn, release func()) {
+	defer release()
+
+	r.logger.Error("No active backend", nil)
+	r.countRejection(clientConn, domain.RejectReasonNoHealthyBackend)
+	r.reject(clientConn, "No healthy backend is available")
+}
+
+func (r Runner) bridge(clientConn net.Conn, activeBackend *domain.Backend, release func()) {
+	defer release()
+
+	decryptedConn, err := r.listener.TerminateTLS(clientConn)
+	if err != nil {
+		clientConn.Close()
+		r.logger.Error("Error terminating TLS", err, lager.Data{"client": clientConn.RemoteAddr().String()})
+		return
+	}
+
+	r.bridgeWithRetries(decryptedConn, activeBackend)
+}
+
+// bridgeWithRetries connects the client to backend, moving on to the next
+// healthy backend each time that fails until the listener's retries or
+// connect timeout run out. Nothing has been sent to the client before a
+// backend is connected, so each attempt starts afresh.
+func (r Runner) bridgeWithRetries(clientConn net.Conn, backend *domain.Backend) {
+	var deadline time.Time
+	if r.listener.ConnectTimeout > 0 {
+		deadline = time.Now().Add(r.listener.ConnectTimeout)
+	}
+
+	tried := map[*domain.Backend]bool{}
+	listener := r.listener
+	for {
+		err := backend.Bridge(clientConn, listener)
+		if err == nil {
+			return
+		}
+
+		r.logger.Error("Error routing to backend", err, lager.Data{"backend": backend.AsJSON()})
+		if r.BackendFailed != nil {
+			r.BackendFailed(backend)
+		}
+		tried[backend] = true
+
+		backend = r.nextBackend(tried)
+		if backend == nil {
+			clientConn.Close()
+			return
+		}
+
+		// retries share what is left of the connect timeout
+		if !deadline.IsZero() {
+			listener.ConnectTimeout = time.Until(deadline)
+			if listener.ConnectTimeout <= 0 {
+				clientConn.Close()
+				r.logger.Info("Connect timeout exceeded, not retrying", lager.Data{"attempts": len(tried)})
+				return
+			}
+		}
+
+		r.logger.Info("Retrying on another backend", lager.Data{"backend": backend.AsJSON(), "attempt": len(tried) + 1})
+		r.retriedBackends.add(backend)
+	}
+}
+
+func (r Runner) nextBackend(tried map[*domain.Backend]bool) *domain.Backend {
+	if uint(len(tried)) > r.listener.ConnectRetries || r.HealthyBackends == nil {
+		return nil
+	}
+
+	for _, backend := range r.HealthyBackends() {
+		if !tried[backend] {
+			return backend
+		}
+	}
+	return nil
+}
+
 func blockingAccept(l net.Listener, c chan<- net.Conn, e chan<- error) {
 	clientConn, err := l.Accept()
 
@@ -1,15 +1,31 @@
 package bridge_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
 	"net"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"time"
 
 	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry-incubator/switchboard/config"
+	"github.com/cloudfoundry-incubator/switchboard/domain"
+	"github.com/cloudfoundry-incubator/switchboard/metrics"
 	"github.com/cloudfoundry-incubator/switchboard/runner/bridge"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 	"github.com/tedsuo/ifrit"
 )
 
@@ -20,7 +36,7 @@ var _ = Describe("Bridge Runner", func() {
 		proxyPort := 10000 + GinkgoParallelNode()
 		logger := lagertest.NewTestLogger("ProxyRunner test")
 
-		proxyRunner := bridge.NewRunner( uint(proxyPort), timeout, logger)
+		proxyRunner := bridge.NewRunner(domain.Listener{Name: "active"}, uint(proxyPort), timeout, 0, logger)
 		proxyProcess := ifrit.Invoke(proxyRunner)
 
 		Eventually(func() error {
@@ -44,4 +60,605 @@ var _ = Describe("Bridge Runner", func() {
 		_, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", proxyPort))
 		Expect(err).To(HaveOccurred())
 	})
+
+	Context("when the listener limits connections", func() {
+		It("closes the connections it rejects", func() {
+			proxyPort := 10900 + GinkgoParallelNode()
+			logger := lagertest.NewTestLogger("ProxyRunner test")
+
+			backendListener, backend := echoBackend("backend", logger)
+			defer backendListener.Close()
+
+			listener, err := domain.NewListener("active", config.Listener{MaxConnections: 1}, logger)
+			Expect(err).NotTo(HaveOccurred())
+			proxyRunner := bridge.NewRunner(listener, uint(proxyPort), 0, 0, logger)
+			proxyProcess := ifrit.Invoke(proxyRunner)
+			defer func() {
+				proxyProcess.Signal(os.Kill)
+				Eventually(proxyProcess.Wait()).Should(Receive())
+			}()
+			proxyRunner.ActiveBackendChan <- backend
+
+			var admittedConn net.Conn
+			Eventually(func() (err error) {
+				admittedConn, err = net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", proxyPort))
+				return err
+			}).Should(Succeed())
+			defer admittedConn.Close()
+			Expect(echo(admittedConn, "admitted")).To(Succeed())
+
+			rejectedConn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", proxyPort))
+			Expect(err).NotTo(HaveOccurred())
+			defer rejectedConn.Close()
+			Expect(echo(rejectedConn, "rejected")).NotTo(Succeed())
+
+			Expect(listener.AsJSON().RejectedConnections).To(Equal(map[string]uint64{
+				domain.RejectReasonMaxConnections: 1,
+			}))
+			Expect(logger).To(gbytes.Say("Rejected client connection"))
+
+			admittedConn.Close()
+			Eventually(func() error {
+				conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", proxyPort))
+				if err != nil {
+					return err
+				}
+				defer conn.Close()
+				return echo(conn, "admitted after release")
+			}).Should(Succeed())
+		})
+		Context("when the listener accepts the PROXY protocol", func() {
+			It("counts connections waiting for their header against the limit", func() {
+				proxyPort := 10950 + GinkgoParallelNode()
+				logger := lagertest.NewTestLogger("ProxyRunner test")
+
+				listener, err := domain.NewListener("active", config.Listener{
+					MaxConnections:            1,
+					AcceptProxyProtocol:       true,
+					ProxyProtocolTrustedCIDRs: []string{"127.0.0.1/32"},
+				}, logger)
+				Expect(err).NotTo(HaveOccurred())
+				proxyRunner := bridge.NewRunner(listener, uint(proxyPort), 0, 0, logger)
+				proxyProcess := ifrit.Invoke(proxyRunner)
+				defer func() {
+					proxyProcess.Signal(os.Kill)
+					Eventually(proxyProcess.Wait()).Should(Receive())
+				}()
+
+				var waitingConn net.Conn
+				Eventually(func() (err error) {
+					waitingConn, err = net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", proxyPort))
+					return err
+				}).Should(Succeed())
+				defer waitingConn.Close()
+				Eventually(func() uint {
+					return listener.AsJSON().CurrentConnections
+				}).Should(BeNumerically("==", 1))
+
+				rejectedConn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", proxyPort))
+				Expect(err).NotTo(HaveOccurred())
+				defer rejectedConn.Close()
+				rejectedConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+				_, err = rejectedConn.Read(make([]byte, 1))
+				Expect(err).To(Equal(io.EOF))
+
+				Expect(listener.AsJSON().RejectedConnections).To(Equal(map[string]uint64{
+					domain.RejectReasonMaxConnections: 1,
+				}))
+			})
+		})
+	})
+
+	Context("when metrics are recorded", func() {
+		It("counts connections and active backend changes", func() {
+			proxyPort := 11300 + GinkgoParallelNode()
+			logger := lagertest.NewTestLogger("ProxyRunner test")
+
+			backendListener, backend := echoBackend("backend", logger)
+			defer backendListener.Close()
+
+			listener, err := domain.NewListener("active", config.Listener{MaxConnections: 1}, logger)
+			Expect(err).NotTo(HaveOccurred())
+			proxyRunner := bridge.NewRunner(listener, uint(proxyPort), 0, 0, logger)
+			m := metrics.New()
+			proxyRunner.Metrics = m
+			proxyProcess := ifrit.Invoke(proxyRunner)
+			defer func() {
+				proxyProcess.Signal(os.Kill)
+				Eventually(proxyProcess.Wait()).Should(Receive())
+			}()
+			proxyRunner.ActiveBackendChan <- backend
+
+			var admittedConn net.Conn
+			Eventually(func() (err error) {
+				admittedConn, err = net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", proxyPort))
+				return err
+			}).Should(Succeed())
+			defer admittedConn.Close()
+			Expect(echo(admittedConn, "admitted")).To(Succeed())
+
+			rejectedConn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", proxyPort))
+			Expect(err).NotTo(HaveOccurred())
+			defer rejectedConn.Close()
+			Expect(echo(rejectedConn, "rejected")).NotTo(Succeed())
+
+			recorder := httptest.NewRecorder()
+			m.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+			body := recorder.Body.String()
+			Expect(body).To(ContainSubstring(`switchboard_connections_accepted_total{listener="active"} 1`))
+			Expect(body).To(ContainSubstring(`switchboard_connections_rejected_total{listener="active",reason="maxConnections"} 1`))
+			Expect(body).To(ContainSubstring(`switchboard_active_backend{listener="active",backend="backend"} 1`))
+		})
+	})
+
+	Context("when traffic is disabled on a listener in MySQL mode", func() {
+		It("answers clients with an ERR packet carrying the cluster message", func() {
+			proxyPort := 11100 + GinkgoParallelNode()
+			logger := lagertest.NewTestLogger("ProxyRunner test")
+
+			listener, err := domain.NewListener("active", config.Listener{RejectWithMySQLError: true}, logger)
+			Expect(err).NotTo(HaveOccurred())
+			proxyRunner := bridge.NewRunner(listener, uint(proxyPort), 0, 0, logger)
+			proxyRunner.ClusterMessage = func() string {
+				return "cluster under maintenance"
+			}
+			proxyProcess := ifrit.Invoke(proxyRunner)
+			defer func() {
+				proxyProcess.Signal(os.Kill)
+				Eventually(proxyProcess.Wait()).Should(Receive())
+			}()
+			proxyRunner.TrafficEnabledChan <- false
+
+			clientConn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", proxyPort))
+			Expect(err).NotTo(HaveOccurred())
+			defer clientConn.Close()
+			clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+
+			greeting := readMySQLPacket(clientConn)
+			Expect(greeting[0]).To(BeNumerically("==", 10), "protocol version")
+
+			_, err = clientConn.Write([]byte{4, 0, 0, 1, 'a', 'u', 't', 'h'})
+			Expect(err).NotTo(HaveOccurred())
+
+			errPacket := readMySQLPacket(clientConn)
+			Expect(errPacket[0]).To(BeNumerically("==", 0xff))
+			Expect(string(errPacket)).To(HaveSuffix("cluster under maintenance"))
+
+			Expect(listener.AsJSON().RejectedConnections).To(Equal(map[string]uint64{
+				domain.RejectReasonTrafficDisabled: 1,
+			}))
+			Expect(logger).To(gbytes.Say("Rejected client connection"))
+		})
+	})
+
+	Context("when the listener has no active backend", func() {
+		It("rejects clients without admitting them", func() {
+			proxyPort := 11125 + GinkgoParallelNode()
+			logger := lagertest.NewTestLogger("ProxyRunner test")
+
+			listener, err := domain.NewListener("active", config.Listener{}, logger)
+			Expect(err).NotTo(HaveOccurred())
+			proxyRunner := bridge.NewRunner(listener, uint(proxyPort), 0, 0, logger)
+			m := metrics.New()
+			proxyRunner.Metrics = m
+			proxyProcess := ifrit.Invoke(proxyRunner)
+			defer func() {
+				proxyProcess.Signal(os.Kill)
+				Eventually(proxyProcess.Wait()).Should(Receive())
+			}()
+
+			var clientConn net.Conn
+			Eventually(func() (err error) {
+				clientConn, err = net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", proxyPort))
+				return err
+			}).Should(Succeed())
+			defer clientConn.Close()
+			clientConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			_, err = clientConn.Read(make([]byte, 1))
+			Expect(err).To(Equal(io.EOF))
+
+			Expect(listener.AsJSON().RejectedConnections).To(Equal(map[string]uint64{
+				domain.RejectReasonNoHealthyBackend: 1,
+			}))
+
+			recorder := httptest.NewRecorder()
+			m.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+			body := recorder.Body.String()
+			Expect(body).To(ContainSubstring(`switchboard_connections_rejected_total{listener="active",reason="noHealthyBackend"} 1`))
+			Expect(body).NotTo(ContainSubstring(`switchboard_connections_accepted_total{listener="active"} 1`))
+		})
+	})
+
+	Context("when traffic is disabled on a TLS listener in MySQL mode", func() {
+		var certDir string
+
+		BeforeEach(func() {
+			var err error
+			certDir, err = ioutil.TempDir("", "bridge-tls")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(certDir)
+		})
+
+		It("sends the ERR packet over TLS", func() {
+			proxyPort := 11150 + GinkgoParallelNode()
+			logger := lagertest.NewTestLogger("ProxyRunner test")
+
+			roots := writeTestCertificate(certDir)
+			listener, err := domain.NewListener("active", config.Listener{
+				TLSCertificatePath:   filepath.Join(certDir, "server.crt"),
+				TLSPrivateKeyPath:    filepath.Join(certDir, "server.key"),
+				RejectWithMySQLError: true,
+			}, logger)
+			Expect(err).NotTo(HaveOccurred())
+
+			proxyRunner := bridge.NewRunner(listener, uint(proxyPort), 0, 0, logger)
+			proxyRunner.ClusterMessage = func() string {
+				return "cluster under maintenance"
+			}
+			proxyProcess := ifrit.Invoke(proxyRunner)
+			defer func() {
+				proxyProcess.Signal(os.Kill)
+				Eventually(proxyProcess.Wait()).Should(Receive())
+			}()
+			proxyRunner.TrafficEnabledChan <- false
+
+			rawConn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", proxyPort))
+			Expect(err).NotTo(HaveOccurred())
+			defer rawConn.Close()
+			rawConn.SetDeadline(time.Now().Add(5 * time.Second))
+
+			clientConn := tls.Client(rawConn, &tls.Config{RootCAs: roots, ServerName: "127.0.0.1"})
+			Expect(clientConn.Handshake()).To(Succeed())
+
+			greeting := readMySQLPacket(clientConn)
+			Expect(greeting[0]).To(BeNumerically("==", 10), "protocol version")
+
+			_, err = clientConn.Write([]byte{4, 0, 0, 1, 'a', 'u', 't', 'h'})
+			Expect(err).NotTo(HaveOccurred())
+
+			errPacket := readMySQLPacket(clientConn)
+			Expect(errPacket[0]).To(BeNumerically("==", 0xff))
+			Expect(string(errPacket)).To(HaveSuffix("cluster under maintenance"))
+		})
+	})
+
+	Context("when the active backend cannot be dialed", func() {
+		var (
+			proxyPort      int
+			listener       domain.Listener
+			failedBackends chan *domain.Backend
+			deadBackend    *domain.Backend
+			liveBackend    *domain.Backend
+			liveListener   net.Listener
+			clientConn     net.Conn
+			proxyRunner    bridge.Runner
+			proxyProcess   ifrit.Process
+		)
+
+		BeforeEach(func() {
+			proxyPort = 11200 + GinkgoParallelNode()
+			listener = domain.Listener{Name: "inactive"}
+			failedBackends = make(chan *domain.Backend, 10)
+		})
+
+		JustBeforeEach(func() {
+			logger := lagertest.NewTestLogger("ProxyRunner test")
+
+			var deadListener net.Listener
+			deadListener, deadBackend = echoBackend("dead", logger)
+			deadListener.Close()
+			liveListener, liveBackend = echoBackend("live", logger)
+
+			proxyRunner = bridge.NewRunner(listener, uint(proxyPort), 0, 0, logger)
+			proxyRunner.BackendFailed = func(backend *domain.Backend) {
+				failedBackends <- backend
+			}
+			proxyRunner.HealthyBackends = func() []*domain.Backend {
+				return []*domain.Backend{deadBackend, liveBackend}
+			}
+			proxyProcess = ifrit.Invoke(proxyRunner)
+			proxyRunner.ActiveBackendChan <- deadBackend
+
+			Eventually(func() (err error) {
+				clientConn, err = net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", proxyPort))
+				return err
+			}).Should(Succeed())
+		})
+
+		AfterEach(func() {
+			clientConn.Close()
+			liveListener.Close()
+			proxyProcess.Signal(os.Kill)
+			Eventually(proxyProcess.Wait()).Should(Receive())
+		})
+
+		It("asks for the backend to be rechecked and closes the client", func() {
+			Eventually(failedBackends).Should(Receive(Equal(deadBackend)))
+
+			clientConn.SetReadDeadline(time.Now().Add(time.Second))
+			_, err := clientConn.Read(make([]byte, 1))
+			Expect(err).To(Equal(io.EOF))
+		})
+
+		Context("when the listener retries on other backends", func() {
+			BeforeEach(func() {
+				listener.ConnectRetries = 1
+				listener.ConnectTimeout = time.Second
+			})
+
+			It("connects the client to the next healthy backend", func() {
+				Expect(echo(clientConn, "retried")).To(Succeed())
+
+				Expect(failedBackends).To(Receive(Equal(deadBackend)))
+				Expect(liveBackend.SessionsAsJSON()).To(HaveLen(1))
+			})
+
+			It("severs the retried session when traffic is disabled", func() {
+				Expect(echo(clientConn, "retried")).To(Succeed())
+				Expect(liveBackend.SessionsAsJSON()).To(HaveLen(1))
+
+				proxyRunner.TrafficEnabledChan <- false
+
+				clientConn.SetReadDeadline(time.Now().Add(time.Second))
+				_, err := clientConn.Read(make([]byte, 1))
+				Expect(err).To(Equal(io.EOF))
+				Eventually(liveBackend.SessionsAsJSON).Should(BeEmpty())
+			})
+		})
+	})
+
+	Context("when the listener accepts the PROXY protocol", func() {
+		It("records the client named in the header on the session", func() {
+			proxyPort := 11000 + GinkgoParallelNode()
+			logger := lagertest.NewTestLogger("ProxyRunner test")
+
+			backendListener, backend := echoBackend("backend", logger)
+			defer backendListener.Close()
+
+			listener, err := domain.NewListener("active", config.Listener{
+				AcceptProxyProtocol:       true,
+				ProxyProtocolTrustedCIDRs: []string{"127.0.0.0/8"},
+			}, logger)
+			Expect(err).NotTo(HaveOccurred())
+			proxyRunner := bridge.NewRunner(listener, uint(proxyPort), 0, 0, logger)
+			proxyProcess := ifrit.Invoke(proxyRunner)
+			defer func() {
+				proxyProcess.Signal(os.Kill)
+				Eventually(proxyProcess.Wait()).Should(Receive())
+			}()
+			proxyRunner.ActiveBackendChan <- backend
+
+			var clientConn net.Conn
+			Eventually(func() (err error) {
+				clientConn, err = net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", proxyPort))
+				return err
+			}).Should(Succeed())
+			defer clientConn.Close()
+
+			_, err = clientConn.Write([]byte("PROXY TCP4 203.0.113.7 127.0.0.1 51234 3306\r\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(echo(clientConn, "after header")).To(Succeed())
+
+			sessions := backend.SessionsAsJSON()
+			Expect(sessions).To(HaveLen(1))
+			Expect(sessions[0].ClientAddress).To(Equal("203.0.113.7:51234"))
+		})
+	})
+
+	Describe("when the active backend changes", func() {
+		var (
+			proxyPort                int
+			proxyRunner              bridge.Runner
+			proxyProcess             ifrit.Process
+			oldBackend, newBackend   *domain.Backend
+			oldListener, newListener net.Listener
+			drainTimeout             time.Duration
+			clientConn               net.Conn
+		)
+
+		BeforeEach(func() {
+			proxyPort = 10700 + GinkgoParallelNode()
+			drainTimeout = 0
+		})
+
+		JustBeforeEach(func() {
+			logger := lagertest.NewTestLogger("ProxyRunner test")
+
+			oldListener, oldBackend = echoBackend("old-backend", logger)
+			newListener, newBackend = echoBackend("new-backend", logger)
+			oldBackend.SetHealthy()
+			newBackend.SetHealthy()
+
+			proxyRunner = bridge.NewRunner(domain.Listener{Name: "active"}, uint(proxyPort), 0, drainTimeout, logger)
+			proxyProcess = ifrit.Invoke(proxyRunner)
+			proxyRunner.ActiveBackendChan <- oldBackend
+
+			Eventually(func() (err error) {
+				clientConn, err = net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", proxyPort))
+				return err
+			}).Should(Succeed())
+			Expect(echo(clientConn, "before switch")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			proxyProcess.Signal(os.Kill)
+			Eventually(proxyProcess.Wait()).Should(Receive())
+			clientConn.Close()
+			oldListener.Close()
+			newListener.Close()
+		})
+
+		Context("when no drain timeout is configured", func() {
+			It("severs the sessions on the old backend", func() {
+				proxyRunner.ActiveBackendChan <- newBackend
+
+				Eventually(func() error {
+					return echo(clientConn, "after switch")
+				}).Should(HaveOccurred())
+			})
+
+			Context("when an operator is draining the old backend", func() {
+				It("leaves the sessions on the old backend to finish", func() {
+					Expect(oldBackend.SetAdminState(domain.AdminStateDrain, "upgrading", "operator")).To(Succeed())
+					proxyRunner.ActiveBackendChan <- newBackend
+
+					Consistently(func() error {
+						return echo(clientConn, "after switch")
+					}).Should(Succeed())
+				})
+			})
+		})
+
+		Context("when a drain timeout is configured", func() {
+			BeforeEach(func() {
+				drainTimeout = time.Second
+			})
+
+			It("lets the sessions on the old backend finish until the timeout", func() {
+				proxyRunner.ActiveBackendChan <- newBackend
+
+				Eventually(func() []domain.DrainJSON {
+					return oldBackend.AsJSON().Drains
+				}).Should(HaveLen(1))
+				Expect(oldBackend.AsJSON().Drains[0].RemainingSessions).To(BeNumerically("==", 1))
+
+				Consistently(func() error {
+					return echo(clientConn, "while draining")
+				}, drainTimeout/2).Should(Succeed())
+
+				Eventually(func() error {
+					return echo(clientConn, "after drain timeout")
+				}, 2*drainTimeout).Should(HaveOccurred())
+				Expect(oldBackend.AsJSON().Drains).To(BeEmpty())
+			})
+
+			It("routes new sessions to the new backend while draining", func() {
+				proxyRunner.ActiveBackendChan <- newBackend
+
+				newConn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", proxyPort))
+				Expect(err).NotTo(HaveOccurred())
+				defer newConn.Close()
+				Expect(echo(newConn, "new session")).To(Succeed())
+
+				Expect(newBackend.AsJSON().CurrentSessionCount).To(BeNumerically("==", 1))
+			})
+
+			Context("when the old backend is unhealthy", func() {
+				It("severs the sessions on the old backend immediately", func() {
+					oldBackend.SetUnhealthy()
+					proxyRunner.ActiveBackendChan <- newBackend
+
+					Eventually(func() error {
+						return echo(clientConn, "after switch")
+					}, drainTimeout/2).Should(HaveOccurred())
+				})
+			})
+
+			Context("when the old backend is in maintenance", func() {
+				It("severs the sessions on the old backend immediately", func() {
+					Expect(oldBackend.SetAdminState(domain.AdminStateMaintenance, "upgrading", "operator")).To(Succeed())
+					proxyRunner.ActiveBackendChan <- newBackend
+
+					Eventually(func() error {
+						return echo(clientConn, "after switch")
+					}, drainTimeout/2).Should(HaveOccurred())
+				})
+			})
+
+			Context("when the old backend becomes active again before the timeout", func() {
+				It("stops draining it", func() {
+					proxyRunner.ActiveBackendChan <- newBackend
+					proxyRunner.ActiveBackendChan <- oldBackend
+
+					Eventually(func() []domain.DrainJSON {
+						return oldBackend.AsJSON().Drains
+					}).Should(BeEmpty())
+
+					Consistently(func() error {
+						return echo(clientConn, "after switching back")
+					}, 2*drainTimeout).Should(Succeed())
+				})
+			})
+		})
+	})
 })
+
+func echoBackend(name string, logger *lagertest.TestLogger) (net.Listener, *domain.Backend) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(conn, conn)
+		}
+	}()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	return listener, domain.NewBackend(name, "127.0.0.1", uint(port), 0, "", logger)
+}
+
+func echo(conn net.Conn, message string) error {
+	conn.SetDeadline(time.Now().Add(time.Second))
+
+	_, err := conn.Write([]byte(message))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.ReadFull(conn, make([]byte, len(message)))
+	return err
+}
+
+func readMySQLPacket(conn net.Conn) []byte {
+	header := make([]byte, 4)
+	_, err := io.ReadFull(conn, header)
+	Expect(err).NotTo(HaveOccurred())
+
+	payload := make([]byte, int(header[0])|int(header[1])<<8|int(header[2])<<16)
+	_, err = io.ReadFull(conn, payload)
+	Expect(err).NotTo(HaveOccurred())
+	return payload
+}
+
+// writeTestCertificate writes a self-signed certificate for 127.0.0.1 to
+// server.crt and server.key in dir, and returns a pool that trusts it.
+func writeTestCertificate(dir string) *x509.CertPool {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "switchboard"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+	certificate, err := x509.ParseCertificate(der)
+	Expect(err).NotTo(HaveOccurred())
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	Expect(ioutil.WriteFile(filepath.Join(dir, "server.crt"), certPEM, 0600)).To(Succeed())
+	Expect(ioutil.WriteFile(filepath.Join(dir, "server.key"), keyPEM, 0600)).To(Succeed())
+
+	roots := x509.NewCertPool()
+	roots.AddCert(certificate)
+	return roots
+}
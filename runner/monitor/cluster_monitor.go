@@ -1,19 +1,18 @@
 package monitor
 
 import (
-	"fmt"
 	"net/http"
+	"sort"
 	"time"
 
 	"sync"
 
-	"math"
-
-	"encoding/json"
+	"math/rand"
 
 	"code.cloudfoundry.org/lager"
-	"github.com/cloudfoundry-incubator/galera-healthcheck/api"
 	"github.com/cloudfoundry-incubator/switchboard/domain"
+	"github.com/cloudfoundry-incubator/switchboard/events"
+	"github.com/cloudfoundry-incubator/switchboard/metrics"
 )
 
 //go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 . UrlGetter
@@ -30,8 +29,13 @@ func HttpUrlGetterProvider(healthcheckTimeout time.Duration) UrlGetter {
 var UrlGetterProvider = HttpUrlGetterProvider
 
 type BackendStatus struct {
-	Index    int
+	Index int
+	// Position is the backend's position in the config, which breaks ties
+	// between backends the selection policy ranks equally.
+	Position int
 	Healthy  bool
+	Flapping bool
+	Checked  bool
 	Counters *DecisionCounters
 }
 
@@ -40,7 +44,38 @@ type ClusterMonitor struct {
 	logger             lager.Logger
 	healthcheckTimeout time.Duration
 	backendSubscribers []chan<- *domain.Backend
-	useLowestIndex     bool
+	selectionPolicy    SelectionPolicy
+	recheckChans       map[*domain.Backend]chan struct{}
+	failbackChan       chan struct{}
+	reselectChan       chan struct{}
+	healthCheckers     map[*domain.Backend]HealthChecker
+	rise               uint
+	fall               uint
+	interval           time.Duration
+	jitter             time.Duration
+	flapDetectors      map[*domain.Backend]*FlapDetector
+	errorTracker       *ErrorTracker
+	metrics            *metrics.Metrics
+	eventLog           *events.Log
+	listener           string
+
+	// healthChecks is the monitor whose check loops report the backends'
+	// status, which is this one unless it shares another's. checkedChans are
+	// told after each check, so that every monitor using the checks chooses
+	// its active backend again.
+	healthChecks *ClusterMonitor
+	checkedChan  chan struct{}
+	checkedChans []chan struct{}
+
+	// statusMutex guards the Healthy, Flapping, Checked and Index of each
+	// BackendStatus, which are written by the backend's check loop and read
+	// to choose the active backend
+	statusMutex sync.Mutex
+	statuses    map[*domain.Backend]*BackendStatus
+
+	mutex           sync.RWMutex
+	healthyBackends []*domain.Backend
+	pinnedBackend   *domain.Backend
 }
 
 func NewClusterMonitor(
@@ -49,57 +84,193 @@ func NewClusterMonitor(
 	logger lager.Logger,
 	useLowestIndex bool,
 ) *ClusterMonitor {
-	return &ClusterMonitor{
+	recheckChans := map[*domain.Backend]chan struct{}{}
+	for _, backend := range backends {
+		recheckChans[backend] = make(chan struct{}, 1)
+	}
+
+	c := &ClusterMonitor{
 		backends:           backends,
 		logger:             logger,
 		healthcheckTimeout: healthcheckTimeout,
-		useLowestIndex:     useLowestIndex,
+		selectionPolicy:    NewIndexPolicy(useLowestIndex),
+		recheckChans:       recheckChans,
+		failbackChan:       make(chan struct{}, 1),
+		reselectChan:       make(chan struct{}, 1),
+		healthCheckers:     map[*domain.Backend]HealthChecker{},
+		interval:           healthcheckTimeout / 5,
+		flapDetectors:      map[*domain.Backend]*FlapDetector{},
+		checkedChan:        make(chan struct{}, 1),
+		statuses:           map[*domain.Backend]*BackendStatus{},
+	}
+	c.healthChecks = c
+	c.checkedChans = []chan struct{}{c.checkedChan}
+
+	for i, backend := range backends {
+		c.statuses[backend] = &BackendStatus{
+			Index:    i,
+			Position: i,
+			Counters: c.SetupCounters(),
+		}
 	}
+	return c
 }
 
-func (c *ClusterMonitor) Monitor(stopChan <-chan interface{}) {
-	client := UrlGetterProvider(c.healthcheckTimeout)
+// ShareHealthChecks makes the monitor choose its active backend from the
+// health checks of source instead of running its own, so that each backend
+// is checked once however many listeners there are, and every listener
+// agrees on its health. Its own health check settings are then unused. It
+// must be called before either monitor is started.
+func (c *ClusterMonitor) ShareHealthChecks(source *ClusterMonitor) {
+	c.healthChecks = source
+	source.checkedChans = append(source.checkedChans, c.checkedChan)
+}
 
-	backendHealthMap := make(map[*domain.Backend]*BackendStatus)
+// SetInterval sets how long each backend waits between health checks, plus
+// a random delay of up to jitter. The interval defaults to a fifth of the
+// health check timeout.
+func (c *ClusterMonitor) SetInterval(interval, jitter time.Duration) {
+	c.interval = interval
+	c.jitter = jitter
+}
+
+// SetRiseAndFall makes a backend pass rise consecutive checks before it is
+// marked healthy again, and fail fall consecutive checks before it is marked
+// unhealthy. Zero counts as one. The first check of each backend decides its
+// state on its own.
+func (c *ClusterMonitor) SetRiseAndFall(rise, fall uint) {
+	c.rise = rise
+	c.fall = fall
+}
+
+// SetFlapDamping holds a backend out of selection for up to penalty once its
+// health changes threshold times within window, so that a node that keeps
+// going in and out of sync does not move all clients each time. The penalty
+// decays as the changes slide out of the window. A threshold of zero disables
+// flap damping. Each backend has one detector, kept by the monitor that runs
+// its health checks.
+func (c *ClusterMonitor) SetFlapDamping(threshold uint, window, penalty time.Duration) {
+	c.flapDetectors = map[*domain.Backend]*FlapDetector{}
+	if threshold == 0 {
+		return
+	}
 
 	for _, backend := range c.backends {
-		backendHealthMap[backend] = &BackendStatus{
-			Index:    -1,
-			Counters: c.SetupCounters(),
+		c.flapDetectors[backend] = NewFlapDetector(threshold, window, penalty)
+	}
+}
+
+// SetErrorTracker makes a backend that clients are hitting errors on fail its
+// health checks while the tracker finds it suspect, whatever its health check
+// reports.
+func (c *ClusterMonitor) SetErrorTracker(errorTracker *ErrorTracker) {
+	c.errorTracker = errorTracker
+}
+
+// SetMetrics records the result and duration of each health check the
+// monitor runs. A monitor sharing another's checks records none, so that each
+// check is counted once.
+func (c *ClusterMonitor) SetMetrics(metrics *metrics.Metrics) {
+	c.metrics = metrics
+}
+
+// SetEventLog records each change of the listener's active backend, and why,
+// in the event log.
+func (c *ClusterMonitor) SetEventLog(eventLog *events.Log, listener string) {
+	c.eventLog = eventLog
+	c.listener = listener
+}
+
+// SetSelectionPolicy selects how the active backend is chosen among the
+// healthy ones. It defaults to an IndexPolicy in the direction given to
+// NewClusterMonitor.
+func (c *ClusterMonitor) SetSelectionPolicy(selectionPolicy SelectionPolicy) {
+	c.selectionPolicy = selectionPolicy
+}
+
+// SetHealthChecker selects how the backend's health is checked. Backends
+// without one are checked with a GaleraHealthChecker.
+func (c *ClusterMonitor) SetHealthChecker(backend *domain.Backend, healthChecker HealthChecker) {
+	c.healthCheckers[backend] = healthChecker
+}
+
+func (c *ClusterMonitor) Monitor(stopChan <-chan interface{}) {
+	if c.healthChecks == c {
+		client := UrlGetterProvider(c.healthcheckTimeout)
+		for backend, healthStatus := range c.statuses {
+			go c.checkBackend(backend, healthStatus, client, stopChan)
 		}
 	}
 
 	go func() {
 		var activeBackend *domain.Backend
+		chosenBefore := false
 
-		for {
-			select {
-			case <-time.After(c.healthcheckTimeout / 5):
-				var wg sync.WaitGroup
-
-				for backend, healthStatus := range backendHealthMap {
-					wg.Add(1)
-					go func(backend *domain.Backend, healthStatus *BackendStatus) {
-						defer wg.Done()
-						c.QueryBackendHealth(backend, healthStatus, client)
-					}(backend, healthStatus)
+		chooseActiveBackend := func(statuses map[*domain.Backend]*BackendStatus, current *domain.Backend, failingBack bool) {
+			healthyBackends := HealthyBackends(statuses, c.selectionPolicy, current)
+
+			pinnedBackend := c.PinnedBackend()
+			healthyBackends = pinFirst(healthyBackends, pinnedBackend)
+
+			c.setHealthyBackends(healthyBackends)
+
+			var newActiveBackend *domain.Backend
+			if len(healthyBackends) > 0 {
+				newActiveBackend = healthyBackends[0]
+			}
+
+			if newActiveBackend != activeBackend {
+				if newActiveBackend != nil {
+					c.logger.Info("New active backend", lager.Data{"backend": newActiveBackend.AsJSON()})
+				}
+
+				var reason string
+				switch {
+				case activeBackend == nil && !chosenBefore:
+					reason = events.ReasonInitial
+				case activeBackend == nil:
+					reason = events.ReasonBackendAvailable
+				case !contains(healthyBackends, activeBackend):
+					reason = leftReason(activeBackend, statuses[activeBackend])
+				case newActiveBackend == pinnedBackend:
+					reason = events.ReasonPinned
+				case failingBack:
+					reason = events.ReasonFailback
+				default:
+					reason = events.ReasonPreferred
 				}
+				c.eventLog.ActiveBackendChanged(c.listener, backendName(activeBackend), backendName(newActiveBackend), reason)
+				chosenBefore = chosenBefore || newActiveBackend != nil
 
-				wg.Wait()
+				activeBackend = newActiveBackend
+				for _, s := range c.backendSubscribers {
+					s <- activeBackend
+				}
+			}
+		}
 
-				newActiveBackend := ChooseActiveBackend(backendHealthMap, c.useLowestIndex)
+		for {
+			select {
+			case <-c.checkedChan:
+				if statuses := c.healthChecks.checkedStatuses(); statuses != nil {
+					chooseActiveBackend(statuses, activeBackend, false)
+				}
 
-				if newActiveBackend != activeBackend {
-					if newActiveBackend != nil {
-						c.logger.Info("New active backend", lager.Data{"backend": newActiveBackend.AsJSON()})
-					}
+			case <-c.reselectChan:
+				if statuses := c.healthChecks.checkedStatuses(); statuses != nil {
+					chooseActiveBackend(statuses, activeBackend, false)
+				}
 
-					activeBackend = newActiveBackend
-					for _, s := range c.backendSubscribers {
-						s <- activeBackend
-					}
+			case <-c.failbackChan:
+				statuses := c.healthChecks.checkedStatuses()
+				if statuses == nil {
+					continue
 				}
 
+				c.logger.Info("Failing back to the preferred backend")
+				// ranking without a current active backend ignores stickiness
+				chooseActiveBackend(statuses, nil, true)
+
 			case <-stopChan:
 				return
 			}
@@ -107,6 +278,169 @@ func (c *ClusterMonitor) Monitor(stopChan <-chan interface{}) {
 	}()
 }
 
+// checkedStatuses copies the status of every backend once each has been
+// checked, so that a quick backend does not win by default. It returns nil
+// until then.
+func (c *ClusterMonitor) checkedStatuses() map[*domain.Backend]*BackendStatus {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+
+	statuses := map[*domain.Backend]*BackendStatus{}
+	for backend, healthStatus := range c.statuses {
+		if !healthStatus.Checked {
+			return nil
+		}
+		status := *healthStatus
+		statuses[backend] = &status
+	}
+	return statuses
+}
+
+// checkBackend checks the backend every interval, or at once when it is
+// rechecked, and tells every monitor using the checks after each one. Each
+// backend has its own loop, so one that hangs until the timeout does not hold
+// up the others.
+func (c *ClusterMonitor) checkBackend(
+	backend *domain.Backend,
+	healthStatus *BackendStatus,
+	client UrlGetter,
+	stopChan <-chan interface{},
+) {
+	for {
+		select {
+		case <-time.After(c.nextInterval()):
+		case <-c.recheckChans[backend]:
+			c.logger.Info("Rechecking backend after a failed connection", lager.Data{"backend": backend.AsJSON()})
+		case <-stopChan:
+			return
+		}
+
+		c.QueryBackendHealth(backend, healthStatus, client)
+
+		for _, checkedChan := range c.checkedChans {
+			select {
+			case checkedChan <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func (c *ClusterMonitor) nextInterval() time.Duration {
+	if c.jitter <= 0 {
+		return c.interval
+	}
+	return c.interval + time.Duration(rand.Int63n(int64(c.jitter)))
+}
+
+// RecheckBackend queries the backend's health as soon as possible instead of
+// waiting for the next interval, e.g. because a client could not connect to
+// it. It does not block; a backend already waiting for a recheck is not
+// queued twice.
+func (c *ClusterMonitor) RecheckBackend(backend *domain.Backend) {
+	select {
+	case c.healthChecks.recheckChans[backend] <- struct{}{}:
+	default:
+	}
+}
+
+// Failback chooses the active backend again without favoring the current
+// one, so that a sticky listener moves to the backend its policy prefers. It
+// does not block.
+func (c *ClusterMonitor) Failback() {
+	select {
+	case c.failbackChan <- struct{}{}:
+	default:
+	}
+}
+
+// Pin makes the backend active for as long as it is healthy, whatever the
+// selection policy prefers. Pinning nil releases the pin. It does not block.
+func (c *ClusterMonitor) Pin(backend *domain.Backend) {
+	c.mutex.Lock()
+	c.pinnedBackend = backend
+	c.mutex.Unlock()
+
+	c.Reselect()
+}
+
+// Reselect chooses the active backend again, e.g. because an operator took a
+// backend out of rotation. It does not block.
+func (c *ClusterMonitor) Reselect() {
+	select {
+	case c.reselectChan <- struct{}{}:
+	default:
+	}
+}
+
+func (c *ClusterMonitor) PinnedBackend() *domain.Backend {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.pinnedBackend
+}
+
+// leftReason is why the active backend was left when it is no longer
+// selectable.
+func leftReason(backend *domain.Backend, status *BackendStatus) string {
+	switch {
+	case !status.Healthy:
+		return events.ReasonUnhealthy
+	case !backend.InRotation():
+		return events.ReasonOutOfRotation
+	default:
+		return events.ReasonFlapping
+	}
+}
+
+func backendName(backend *domain.Backend) string {
+	if backend == nil {
+		return ""
+	}
+	return backend.AsJSON().Name
+}
+
+func contains(backends []*domain.Backend, backend *domain.Backend) bool {
+	for _, b := range backends {
+		if b == backend {
+			return true
+		}
+	}
+	return false
+}
+
+// pinFirst moves the pinned backend to the front if it is healthy.
+func pinFirst(healthyBackends []*domain.Backend, pinnedBackend *domain.Backend) []*domain.Backend {
+	if pinnedBackend == nil {
+		return healthyBackends
+	}
+
+	for i, backend := range healthyBackends {
+		if backend == pinnedBackend {
+			pinned := []*domain.Backend{pinnedBackend}
+			pinned = append(pinned, healthyBackends[:i]...)
+			return append(pinned, healthyBackends[i+1:]...)
+		}
+	}
+	return healthyBackends
+}
+
+// HealthyBackends lists the backends that passed their latest health check,
+// most preferred first.
+func (c *ClusterMonitor) HealthyBackends() []*domain.Backend {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return append([]*domain.Backend(nil), c.healthyBackends...)
+}
+
+func (c *ClusterMonitor) setHealthyBackends(healthyBackends []*domain.Backend) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.healthyBackends = healthyBackends
+}
+
 func (c *ClusterMonitor) RegisterBackendSubscriber(newSubscriber chan<- *domain.Backend) {
 	c.backendSubscribers = append(c.backendSubscribers, newSubscriber)
 }
@@ -120,84 +454,98 @@ func (c *ClusterMonitor) SetupCounters() *DecisionCounters {
 		return (counters.GetCount("dial") % logFreq) == 0
 	})
 
+	counters.AddCondition("rise", func() bool {
+		return counters.GetCount("consecutiveHealthyChecks") >= uint64(c.rise)
+	})
+	counters.AddCondition("fall", func() bool {
+		return counters.GetCount("consecutiveUnhealthyChecks") >= uint64(c.fall)
+	})
+
 	return counters
 }
 
-func ChooseActiveBackend(backendHealths map[*domain.Backend]*BackendStatus, useLowestIndex bool) *domain.Backend {
-	var lowestIndexedHealthyBackend, highestIndexedHealthyBackend *domain.Backend
-	lowestHealthyIndex := math.MaxUint32
-	highestHealthyIndex := -1
+// ChooseActiveBackend returns the healthy backend the policy prefers, given
+// the listener's current active backend.
+func ChooseActiveBackend(backendHealths map[*domain.Backend]*BackendStatus, policy SelectionPolicy, activeBackend *domain.Backend) *domain.Backend {
+	healthyBackends := HealthyBackends(backendHealths, policy, activeBackend)
+	if len(healthyBackends) == 0 {
+		return nil
+	}
+	return healthyBackends[0]
+}
 
+// HealthyBackends orders the healthy backends as the policy prefers them,
+// leaving out those held out for flapping or taken out of rotation. Backends
+// the policy ranks equally keep their order in the config.
+func HealthyBackends(backendHealths map[*domain.Backend]*BackendStatus, policy SelectionPolicy, activeBackend *domain.Backend) []*domain.Backend {
+	var candidates []Candidate
+	positions := map[*domain.Backend]int{}
+	holdOutFlapping := holdsOutFlapping(backendHealths)
 	for backend, backendStatus := range backendHealths {
-		if !backendStatus.Healthy {
-			continue
-		}
-		if backendStatus.Index <= lowestHealthyIndex {
-			lowestHealthyIndex = backendStatus.Index
-			lowestIndexedHealthyBackend = backend
-		}
-		if backendStatus.Index >= highestHealthyIndex {
-			highestHealthyIndex = backendStatus.Index
-			highestIndexedHealthyBackend = backend
+		if selectable(backend, backendStatus) && !(holdOutFlapping && backendStatus.Flapping) {
+			candidates = append(candidates, Candidate{
+				Backend:  backend,
+				Index:    backendStatus.Index,
+				Active:   backend == activeBackend,
+				Sessions: backend.SessionCount(),
+			})
+			positions[backend] = backendStatus.Position
 		}
 	}
 
-	if useLowestIndex {
-		return lowestIndexedHealthyBackend
-	} else {
-		return highestIndexedHealthyBackend
+	// the map is ranged over in random order, so the candidates are put in
+	// config order before the stable sort by preference
+	sort.Slice(candidates, func(i, j int) bool {
+		return positions[candidates[i].Backend] < positions[candidates[j].Backend]
+	})
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return policy.Less(candidates[i], candidates[j])
+	})
+
+	var healthyBackends []*domain.Backend
+	for _, candidate := range candidates {
+		healthyBackends = append(healthyBackends, candidate.Backend)
 	}
+	return healthyBackends
 }
 
-func (c *ClusterMonitor) determineStateFromBackend(backend *domain.Backend, client UrlGetter, shouldLog bool) (bool, *int) {
-	j := backend.AsJSON()
-
-	url := fmt.Sprintf("http://%s:%d/api/v1/status", j.Host, j.StatusPort)
-	resp, err := client.Get(url)
-
-	healthy := false
-	var index *int
-
-	if err == nil {
-		if resp.StatusCode == http.StatusOK {
-			var v1StatusResponse api.V1StatusResponse
+// holdsOutFlapping reports whether flapping backends are kept out of
+// selection. They are only selected when no other backend is healthy, since a
+// flapping backend is better than none.
+func holdsOutFlapping(backendHealths map[*domain.Backend]*BackendStatus) bool {
+	for backend, backendStatus := range backendHealths {
+		if selectable(backend, backendStatus) && !backendStatus.Flapping {
+			return true
+		}
+	}
+	return false
+}
 
-			_ = json.NewDecoder(resp.Body).Decode(&v1StatusResponse)
+func selectable(backend *domain.Backend, backendStatus *BackendStatus) bool {
+	return backendStatus.Healthy && backend.InRotation()
+}
 
-			healthy = v1StatusResponse.Healthy
-			indexVal := int(v1StatusResponse.WsrepLocalIndex)
-			index = &indexVal
-		}
+func (c *ClusterMonitor) determineStateFromBackend(backend *domain.Backend, client UrlGetter, shouldLog bool) (bool, *int) {
+	healthChecker, ok := c.healthCheckers[backend]
+	if !ok {
+		healthChecker = NewGaleraHealthChecker(client)
 	}
 
-	if shouldLog {
-		if !healthy && err == nil {
-			c.logger.Error(
-				"Healthcheck failed on backend",
-				fmt.Errorf("Backend reported as unhealthy"),
-				lager.Data{
-					"backend":  backend.AsJSON(),
-					"endpoint": url,
-					"resp":     fmt.Sprintf("%#v", resp),
-				},
-			)
-		}
+	healthy, index, err := healthChecker.Check(backend)
 
+	if shouldLog {
 		if err != nil {
 			c.logger.Error(
 				"Healthcheck failed on backend",
-				fmt.Errorf("Error during healthcheck http get"),
+				err,
 				lager.Data{
-					"backend":  backend.AsJSON(),
-					"endpoint": url,
-					"resp":     fmt.Sprintf("%#v", resp),
-					"err":      err.Error(),
+					"backend": backend.AsJSON(),
 				},
 			)
 		}
 
 		if healthy {
-			c.logger.Debug("Healthcheck succeeded", lager.Data{"endpoint": url})
+			c.logger.Debug("Healthcheck succeeded", lager.Data{"backend": backend.AsJSON()})
 		}
 	}
 
@@ -209,21 +557,70 @@ func (c *ClusterMonitor) QueryBackendHealth(backend *domain.Backend, healthMonit
 	healthMonitor.Counters.IncrementCount("dial")
 	shouldLog := healthMonitor.Counters.Should("log")
 
+	checkStart := time.Now()
 	healthy, index := c.determineStateFromBackend(backend, client, shouldLog)
+	c.metrics.HealthcheckCompleted(backend, healthy, time.Since(checkStart))
+
+	if healthy && c.errorTracker != nil && c.errorTracker.Suspect(backend, time.Now()) {
+		c.logger.Info("Backend passed its health check but clients are hitting errors on it, counting the check as failed", lager.Data{"backend": backend.AsJSON()})
+		healthy = false
+	}
+	firstCheck := healthMonitor.Counters.GetCount("dial") == 1
+
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
 
 	if index != nil {
 		healthMonitor.Index = *index
 	}
+	wasHealthy := healthMonitor.Healthy
+	healthMonitor.Checked = true
 
 	if healthy {
+		healthMonitor.Counters.IncrementCount("consecutiveHealthyChecks")
+		healthMonitor.Counters.ResetCount("consecutiveUnhealthyChecks")
+	} else {
+		healthMonitor.Counters.IncrementCount("consecutiveUnhealthyChecks")
+		healthMonitor.Counters.ResetCount("consecutiveHealthyChecks")
+	}
+
+	switch {
+	case healthy && (firstCheck || healthMonitor.Counters.Should("rise")):
 		c.logger.Debug("Querying Backend: healthy", lager.Data{"backend": backend.AsJSON(), "healthMonitor": healthMonitor})
 		backend.SetHealthy()
 		healthMonitor.Healthy = true
-		healthMonitor.Counters.ResetCount("consecutiveUnhealthyChecks")
-	} else {
+	case !healthy && (firstCheck || healthMonitor.Counters.Should("fall")):
 		c.logger.Debug("Querying Backend: unhealthy", lager.Data{"backend": backend.AsJSON(), "healthMonitor": healthMonitor})
 		backend.SetUnhealthy()
 		healthMonitor.Healthy = false
-		healthMonitor.Counters.IncrementCount("consecutiveUnhealthyChecks")
+	default:
+		c.logger.Debug("Querying Backend: keeping state until the threshold is reached", lager.Data{
+			"backend":       backend.AsJSON(),
+			"healthMonitor": healthMonitor,
+			"healthy":       healthy,
+		})
+	}
+
+	if flapDetector, ok := c.flapDetectors[backend]; ok {
+		now := time.Now()
+		if !firstCheck && healthMonitor.Healthy != wasHealthy {
+			flapDetector.RecordTransition(now)
+		}
+		c.updateFlapping(backend, healthMonitor, flapDetector.PenaltyDeadline(now))
 	}
 }
+
+func (c *ClusterMonitor) updateFlapping(backend *domain.Backend, healthMonitor *BackendStatus, penaltyDeadline time.Time) {
+	flapping := !penaltyDeadline.IsZero()
+	if flapping && !healthMonitor.Flapping {
+		c.logger.Info("Backend is flapping, holding it out of selection", lager.Data{
+			"backend":         backend.AsJSON(),
+			"penaltyDeadline": penaltyDeadline,
+		})
+	} else if !flapping && healthMonitor.Flapping {
+		c.logger.Info("Backend stopped flapping", lager.Data{"backend": backend.AsJSON()})
+	}
+
+	healthMonitor.Flapping = flapping
+	backend.SetFlapPenalty(penaltyDeadline)
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -16,6 +17,8 @@ import (
 
 	"code.cloudfoundry.org/lager/lagertest"
 	"github.com/cloudfoundry-incubator/switchboard/domain"
+	"github.com/cloudfoundry-incubator/switchboard/events"
+	"github.com/cloudfoundry-incubator/switchboard/metrics"
 	"github.com/cloudfoundry-incubator/switchboard/runner/monitor"
 	"github.com/cloudfoundry-incubator/switchboard/runner/monitor/monitorfakes"
 )
@@ -279,6 +282,307 @@ var _ = Describe("ClusterMonitor", func() {
 				})
 			})
 		})
+
+		Context("when a selection policy is set", func() {
+			It("publishes the backend the policy prefers", func() {
+				clusterMonitor.SetSelectionPolicy(monitor.NewPreferredPolicy([]*domain.Backend{backend2}, monitor.NewIndexPolicy(true)))
+				clusterMonitor.Monitor(stopMonitoringChan)
+
+				Eventually(subscriberA).Should(Receive(Equal(backend2)))
+				Eventually(clusterMonitor.HealthyBackends).Should(Equal([]*domain.Backend{backend2, backend1, backend3}))
+			})
+		})
+
+		Context("when failback is sticky", func() {
+			It("keeps the active backend until failback is triggered", func() {
+				backend1Healthy := false
+				urlGetter.GetStub = func(url string) (*http.Response, error) {
+					m.RLock()
+					defer m.RUnlock()
+
+					if url == backend1.HealthcheckUrl() && !backend1Healthy {
+						return unhealthyResponse(0), nil
+					}
+					return healthyResponse(backendToIndex[backendForURL(backends, url)]), nil
+				}
+
+				clusterMonitor.SetSelectionPolicy(monitor.NewStickyPolicy(monitor.NewIndexPolicy(true)))
+				clusterMonitor.Monitor(stopMonitoringChan)
+
+				Eventually(subscriberA).Should(Receive(Equal(backend2)))
+
+				m.Lock()
+				backend1Healthy = true
+				m.Unlock()
+
+				Eventually(backend1.Healthy).Should(BeTrue())
+				Consistently(subscriberA, 4*healthcheckTimeout/5).ShouldNot(Receive())
+
+				clusterMonitor.Failback()
+
+				Eventually(subscriberA).Should(Receive(Equal(backend1)))
+			})
+		})
+
+		Describe("Pin", func() {
+			It("keeps the pinned backend active while it is healthy", func() {
+				clusterMonitor.Monitor(stopMonitoringChan)
+				Eventually(subscriberA).Should(Receive(Equal(backend1)))
+
+				clusterMonitor.Pin(backend3)
+
+				Eventually(subscriberA).Should(Receive(Equal(backend3)))
+				Expect(clusterMonitor.PinnedBackend()).To(Equal(backend3))
+
+				clusterMonitor.Pin(nil)
+
+				Eventually(subscriberA).Should(Receive(Equal(backend1)))
+				Expect(clusterMonitor.PinnedBackend()).To(BeNil())
+			})
+
+			It("moves off the pinned backend when it becomes unhealthy", func() {
+				backend3Healthy := true
+				urlGetter.GetStub = func(url string) (*http.Response, error) {
+					m.RLock()
+					defer m.RUnlock()
+
+					if url == backend3.HealthcheckUrl() && !backend3Healthy {
+						return unhealthyResponse(0), nil
+					}
+					return healthyResponse(backendToIndex[backendForURL(backends, url)]), nil
+				}
+
+				clusterMonitor.Monitor(stopMonitoringChan)
+				clusterMonitor.Pin(backend3)
+				Eventually(subscriberA).Should(Receive(Equal(backend3)))
+
+				m.Lock()
+				backend3Healthy = false
+				m.Unlock()
+
+				Eventually(subscriberA).Should(Receive(Equal(backend1)))
+			})
+		})
+
+		Describe("Reselect", func() {
+			It("moves off a backend taken out of rotation until it is back", func() {
+				clusterMonitor.Monitor(stopMonitoringChan)
+				Eventually(subscriberA).Should(Receive(Equal(backend1)))
+
+				Expect(backend1.SetAdminState(domain.AdminStateDrain, "upgrading", "operator")).To(Succeed())
+				clusterMonitor.Reselect()
+
+				Eventually(subscriberA).Should(Receive(Equal(backend2)))
+				Expect(clusterMonitor.HealthyBackends()).NotTo(ContainElement(backend1))
+
+				Expect(backend1.SetAdminState(domain.AdminStateActive, "", "operator")).To(Succeed())
+				clusterMonitor.Reselect()
+
+				Eventually(subscriberA).Should(Receive(Equal(backend1)))
+			})
+		})
+
+		Describe("SetEventLog", func() {
+			It("records each change of active backend and why", func() {
+				eventLog, err := events.NewLog(100, "", logger)
+				Expect(err).NotTo(HaveOccurred())
+				clusterMonitor.SetEventLog(eventLog, "active")
+
+				clusterMonitor.Monitor(stopMonitoringChan)
+				Eventually(subscriberA).Should(Receive(Equal(backend1)))
+
+				clusterMonitor.Pin(backend3)
+				Eventually(subscriberA).Should(Receive(Equal(backend3)))
+
+				Expect(backend3.SetAdminState(domain.AdminStateMaintenance, "patching", "operator")).To(Succeed())
+				clusterMonitor.Reselect()
+				Eventually(subscriberA).Should(Receive(Equal(backend1)))
+
+				var changes []events.Event
+				for _, e := range eventLog.Between(time.Time{}, time.Time{}) {
+					if e.Kind == events.KindActiveBackendChanged {
+						e.Time = time.Time{}
+						changes = append(changes, e)
+					}
+				}
+				Expect(changes).To(Equal([]events.Event{
+					{Kind: events.KindActiveBackendChanged, Listener: "active", Backend: "backend-1", Reason: events.ReasonInitial},
+					{Kind: events.KindActiveBackendChanged, Listener: "active", Backend: "backend-3", PreviousBackend: "backend-1", Reason: events.ReasonPinned},
+					{Kind: events.KindActiveBackendChanged, Listener: "active", Backend: "backend-1", PreviousBackend: "backend-3", Reason: events.ReasonOutOfRotation},
+				}))
+			})
+		})
+
+		Describe("RecheckBackend", func() {
+			It("queries the backend before the next interval", func() {
+				urlGetter.GetStub = func(url string) (*http.Response, error) {
+					return unhealthyResponse(0), nil
+				}
+
+				slowMonitor := monitor.NewClusterMonitor(backends, 10*time.Second, logger, useLowestIndex)
+				slowMonitor.Monitor(stopMonitoringChan)
+
+				slowMonitor.RecheckBackend(backend2)
+
+				Eventually(backend2.Healthy, time.Second).Should(BeFalse())
+				Expect(backend1.Healthy()).To(BeTrue())
+				Expect(backend3.Healthy()).To(BeTrue())
+			})
+		})
+
+		Describe("SetInterval", func() {
+			It("checks the backends at the interval instead of a fifth of the timeout", func() {
+				slowMonitor := monitor.NewClusterMonitor(backends, 10*time.Second, logger, useLowestIndex)
+				slowMonitor.SetInterval(20*time.Millisecond, 10*time.Millisecond)
+				slowMonitor.Monitor(stopMonitoringChan)
+
+				Eventually(urlGetter.GetCallCount, time.Second).Should(BeNumerically(">=", 30))
+			})
+		})
+
+		Describe("ShareHealthChecks", func() {
+			var (
+				sharingMonitor    *monitor.ClusterMonitor
+				sharingSubscriber chan *domain.Backend
+			)
+
+			JustBeforeEach(func() {
+				sharingMonitor = monitor.NewClusterMonitor(backends, healthcheckTimeout, logger, false)
+				sharingMonitor.ShareHealthChecks(clusterMonitor)
+				sharingSubscriber = make(chan *domain.Backend, 100)
+				sharingMonitor.RegisterBackendSubscriber(sharingSubscriber)
+			})
+
+			It("chooses from the other monitor's checks without running its own", func() {
+				clients := 0
+				monitor.UrlGetterProvider = func(time.Duration) monitor.UrlGetter {
+					clients++
+					return urlGetter
+				}
+
+				clusterMonitor.Monitor(stopMonitoringChan)
+				sharingMonitor.Monitor(stopMonitoringChan)
+
+				Eventually(subscriberA).Should(Receive(Equal(backend1)))
+				Eventually(sharingSubscriber).Should(Receive(Equal(backend3)))
+				Expect(clients).To(Equal(1))
+
+				m.Lock()
+				backendToIndex[backend1] = 2
+				backendToIndex[backend3] = 0
+				m.Unlock()
+
+				Eventually(subscriberA).Should(Receive(Equal(backend3)))
+				Eventually(sharingSubscriber).Should(Receive(Equal(backend1)))
+			})
+
+			It("records each check in the metrics once", func() {
+				switchboardMetrics := metrics.New()
+				clusterMonitor.SetMetrics(switchboardMetrics)
+				sharingMonitor.SetMetrics(switchboardMetrics)
+				clusterMonitor.SetInterval(time.Hour, 0)
+
+				clusterMonitor.Monitor(stopMonitoringChan)
+				sharingMonitor.Monitor(stopMonitoringChan)
+				sharingMonitor.RecheckBackend(backend2)
+
+				scrape := func() string {
+					recorder := httptest.NewRecorder()
+					switchboardMetrics.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+					return recorder.Body.String()
+				}
+				Eventually(scrape).Should(ContainSubstring(`switchboard_healthchecks_total{backend="backend-2",result="healthy"} 1`))
+				Consistently(scrape, 100*time.Millisecond).ShouldNot(ContainSubstring(`switchboard_healthchecks_total{backend="backend-2",result="healthy"} 2`))
+			})
+
+			It("rechecks backends through the other monitor", func() {
+				urlGetter.GetStub = func(url string) (*http.Response, error) {
+					return unhealthyResponse(0), nil
+				}
+
+				slowMonitor := monitor.NewClusterMonitor(backends, 10*time.Second, logger, useLowestIndex)
+				sharingMonitor.ShareHealthChecks(slowMonitor)
+				slowMonitor.Monitor(stopMonitoringChan)
+				sharingMonitor.Monitor(stopMonitoringChan)
+
+				sharingMonitor.RecheckBackend(backend2)
+
+				Eventually(backend2.Healthy, time.Second).Should(BeFalse())
+				Expect(backend1.Healthy()).To(BeTrue())
+			})
+		})
+
+		Context("when a backend stops responding to health checks", func() {
+			var release chan struct{}
+
+			BeforeEach(func() {
+				release = make(chan struct{})
+			})
+
+			AfterEach(func() {
+				close(release)
+			})
+
+			It("keeps checking the other backends", func() {
+				checkedBackend2 := false
+				backend1Healthy := true
+				urlGetter.GetStub = func(url string) (*http.Response, error) {
+					m.Lock()
+					if url == backend2.HealthcheckUrl() && checkedBackend2 {
+						m.Unlock()
+						<-release
+						return nil, errors.New("timeout")
+					}
+					if url == backend2.HealthcheckUrl() {
+						checkedBackend2 = true
+					}
+					defer m.Unlock()
+
+					for backend, index := range backendToIndex {
+						if url != backend.HealthcheckUrl() {
+							continue
+						}
+						if backend == backend1 && !backend1Healthy {
+							return unhealthyResponse(index), nil
+						}
+						return healthyResponse(index), nil
+					}
+					panic("Unexpected backend")
+				}
+
+				clusterMonitor.Monitor(stopMonitoringChan)
+
+				Eventually(subscriberA).Should(Receive(Equal(backend1)))
+
+				m.Lock()
+				backend1Healthy = false
+				m.Unlock()
+
+				Eventually(subscriberA).Should(Receive(Equal(backend2)))
+				Expect(backend1.Healthy()).To(BeFalse())
+			})
+		})
+
+		Describe("HealthyBackends", func() {
+			It("lists the healthy backends in order of preference", func() {
+				urlGetter.GetStub = func(url string) (*http.Response, error) {
+					m.RLock()
+					defer m.RUnlock()
+
+					if url == backend2.HealthcheckUrl() {
+						return unhealthyResponse(1), nil
+					}
+					if url == backend1.HealthcheckUrl() {
+						return healthyResponse(2), nil
+					}
+					return healthyResponse(0), nil
+				}
+
+				clusterMonitor.Monitor(stopMonitoringChan)
+
+				Eventually(clusterMonitor.HealthyBackends).Should(Equal([]*domain.Backend{backend3, backend1}))
+			})
+		})
 	})
 
 	Describe("QueryBackendHealth", func() {
@@ -305,7 +609,7 @@ var _ = Describe("ClusterMonitor", func() {
 				backendHost,
 				3306,
 				backendStatusPort,
-				"",
+				"custom/status",
 				logger,
 			)
 		})
@@ -337,7 +641,7 @@ var _ = Describe("ClusterMonitor", func() {
 			Expect(urlGetter.GetCallCount()).To(Equal(1))
 
 			expectedURL := fmt.Sprintf(
-				"http://%s:%d/api/v1/status",
+				"http://%s:%d/custom/status",
 				backendHost,
 				backendStatusPort,
 			)
@@ -388,6 +692,180 @@ var _ = Describe("ClusterMonitor", func() {
 				Expect(backendStatus.Healthy).To(BeFalse())
 			})
 		})
+
+		Context("when rise and fall thresholds are configured", func() {
+			var healthChecker *monitorfakes.FakeHealthChecker
+
+			JustBeforeEach(func() {
+				clusterMonitor.SetRiseAndFall(2, 3)
+				backendStatus.Counters = clusterMonitor.SetupCounters()
+
+				healthChecker = new(monitorfakes.FakeHealthChecker)
+				clusterMonitor.SetHealthChecker(backend, healthChecker)
+			})
+
+			check := func(healthy bool) bool {
+				healthChecker.CheckReturns(healthy, nil, nil)
+				clusterMonitor.QueryBackendHealth(backend, backendStatus, urlGetter)
+				Expect(backend.Healthy()).To(Equal(backendStatus.Healthy))
+				return backendStatus.Healthy
+			}
+
+			It("decides the state on the first check", func() {
+				Expect(check(true)).To(BeTrue())
+			})
+
+			It("marks a backend unhealthy only after fall consecutive failures", func() {
+				Expect(check(true)).To(BeTrue())
+
+				Expect(check(false)).To(BeTrue())
+				Expect(check(false)).To(BeTrue())
+				Expect(check(true)).To(BeTrue(), "a passing check starts the count again")
+				Expect(check(false)).To(BeTrue())
+				Expect(check(false)).To(BeTrue())
+				Expect(check(false)).To(BeFalse())
+			})
+
+			It("marks a backend healthy only after rise consecutive passes", func() {
+				Expect(check(false)).To(BeFalse())
+
+				Expect(check(true)).To(BeFalse())
+				Expect(check(false)).To(BeFalse())
+				Expect(check(true)).To(BeFalse())
+				Expect(check(true)).To(BeTrue())
+			})
+		})
+
+		Context("when flap damping is enabled", func() {
+			var healthChecker *monitorfakes.FakeHealthChecker
+
+			JustBeforeEach(func() {
+				clusterMonitor.SetFlapDamping(3, time.Minute, time.Minute)
+
+				backend = backend1
+				healthChecker = new(monitorfakes.FakeHealthChecker)
+				clusterMonitor.SetHealthChecker(backend, healthChecker)
+			})
+
+			check := func(healthy bool) {
+				healthChecker.CheckReturns(healthy, nil, nil)
+				clusterMonitor.QueryBackendHealth(backend, backendStatus, urlGetter)
+				Expect(backendStatus.Healthy).To(Equal(healthy))
+			}
+
+			It("marks a backend as flapping once its state changes threshold times", func() {
+				check(true)
+				check(false)
+				check(true)
+				Expect(backendStatus.Flapping).To(BeFalse())
+				Expect(backend.AsJSON().Flapping).To(BeFalse())
+
+				check(true)
+				Expect(backendStatus.Flapping).To(BeFalse(), "only state changes count")
+
+				check(false)
+				Expect(backendStatus.Flapping).To(BeTrue())
+				Expect(backend.AsJSON().Flapping).To(BeTrue())
+				Expect(*backend.AsJSON().FlapPenaltyDeadline).To(BeTemporally("~", time.Now().Add(time.Minute), time.Second))
+
+				check(true)
+				Expect(backendStatus.Flapping).To(BeTrue(), "stays held out while the penalty lasts")
+			})
+
+			It("does not count the first check as a state change", func() {
+				check(false)
+				check(true)
+				check(false)
+				Expect(backendStatus.Flapping).To(BeFalse())
+			})
+		})
+
+		Context("when an error tracker is set", func() {
+			var (
+				healthChecker *monitorfakes.FakeHealthChecker
+				errorTracker  *monitor.ErrorTracker
+			)
+
+			JustBeforeEach(func() {
+				errorTracker = monitor.NewErrorTracker(2, time.Minute, logger)
+				clusterMonitor.SetErrorTracker(errorTracker)
+
+				healthChecker = new(monitorfakes.FakeHealthChecker)
+				healthChecker.CheckReturns(true, nil, nil)
+				clusterMonitor.SetHealthChecker(backend, healthChecker)
+			})
+
+			It("counts a passing check on a suspect backend as failed", func() {
+				errorTracker.RecordError(backend, domain.BackendErrorDial)
+				clusterMonitor.QueryBackendHealth(backend, backendStatus, urlGetter)
+				Expect(backendStatus.Healthy).To(BeTrue())
+
+				errorTracker.RecordError(backend, domain.BackendErrorReset)
+				clusterMonitor.QueryBackendHealth(backend, backendStatus, urlGetter)
+				Expect(backendStatus.Healthy).To(BeFalse())
+				Expect(backend.Healthy()).To(BeFalse())
+			})
+		})
+
+		Context("when the backend has its own health checker", func() {
+			var healthChecker *monitorfakes.FakeHealthChecker
+
+			JustBeforeEach(func() {
+				healthChecker = new(monitorfakes.FakeHealthChecker)
+				healthChecker.CheckReturns(true, nil, nil)
+				clusterMonitor.SetHealthChecker(backend, healthChecker)
+			})
+
+			It("uses it instead of galera-healthcheck", func() {
+				clusterMonitor.QueryBackendHealth(backend, backendStatus, urlGetter)
+
+				Expect(healthChecker.CheckCallCount()).To(Equal(1))
+				Expect(healthChecker.CheckArgsForCall(0)).To(Equal(backend))
+				Expect(urlGetter.GetCallCount()).To(Equal(0))
+
+				Expect(backendStatus.Healthy).To(BeTrue())
+				Expect(backendStatus.Index).To(Equal(2), "keeps its index when the check reports none")
+			})
+		})
+	})
+
+	Describe("HealthyBackends", func() {
+		It("orders the healthy backends by index", func() {
+			backend4 := domain.NewBackend("backend-4", "10.10.4.2", 1337, 1338, "healthcheck", logger)
+			statuses := map[*domain.Backend]*monitor.BackendStatus{
+				backend1: {Healthy: true, Index: 1},
+				backend2: {Healthy: false, Index: 0},
+				backend3: {Healthy: true, Index: 3},
+				backend4: {Healthy: true, Index: 2},
+			}
+
+			Expect(monitor.HealthyBackends(statuses, monitor.NewIndexPolicy(true), nil)).To(Equal([]*domain.Backend{backend1, backend4, backend3}))
+			Expect(monitor.HealthyBackends(statuses, monitor.NewIndexPolicy(false), nil)).To(Equal([]*domain.Backend{backend3, backend4, backend1}))
+		})
+
+		It("leaves out flapping backends while others are healthy", func() {
+			statuses := map[*domain.Backend]*monitor.BackendStatus{
+				backend1: {Healthy: true, Flapping: true, Index: 0},
+				backend2: {Healthy: true, Index: 1},
+				backend3: {Healthy: false, Index: 2},
+			}
+
+			Expect(monitor.HealthyBackends(statuses, monitor.NewIndexPolicy(true), nil)).To(Equal([]*domain.Backend{backend2}))
+
+			statuses[backend2].Healthy = false
+			Expect(monitor.HealthyBackends(statuses, monitor.NewIndexPolicy(true), nil)).To(Equal([]*domain.Backend{backend1}))
+		})
+		It("leaves out backends taken out of rotation", func() {
+			statuses := map[*domain.Backend]*monitor.BackendStatus{
+				backend1: {Healthy: true, Index: 0},
+				backend2: {Healthy: true, Flapping: true, Index: 1},
+				backend3: {Healthy: true, Index: 2},
+			}
+			Expect(backend1.SetAdminState(domain.AdminStateMaintenance, "upgrading", "operator")).To(Succeed())
+			Expect(backend3.SetAdminState(domain.AdminStateDrain, "upgrading", "operator")).To(Succeed())
+
+			Expect(monitor.HealthyBackends(statuses, monitor.NewIndexPolicy(true), nil)).To(Equal([]*domain.Backend{backend2}), "selects a flapping backend over none")
+		})
 	})
 
 	Describe("ChooseActiveBackend", func() {
@@ -429,7 +907,7 @@ var _ = Describe("ClusterMonitor", func() {
 
 		Context("When there are no backends", func() {
 			It("returns nil", func() {
-				Expect(monitor.ChooseActiveBackend(statuses, useLowestIndex)).To(BeNil())
+				Expect(monitor.ChooseActiveBackend(statuses, monitor.NewIndexPolicy(useLowestIndex), nil)).To(BeNil())
 			})
 		})
 		Context("If none of the backends are healthy", func() {
@@ -449,7 +927,7 @@ var _ = Describe("ClusterMonitor", func() {
 					Index:   2,
 				}
 
-				Expect(monitor.ChooseActiveBackend(statuses, useLowestIndex)).To(BeNil())
+				Expect(monitor.ChooseActiveBackend(statuses, monitor.NewIndexPolicy(useLowestIndex), nil)).To(BeNil())
 			})
 		})
 
@@ -470,7 +948,7 @@ var _ = Describe("ClusterMonitor", func() {
 					Index:   2,
 				}
 
-				Expect(monitor.ChooseActiveBackend(statuses, useLowestIndex)).To(Equal(backend3))
+				Expect(monitor.ChooseActiveBackend(statuses, monitor.NewIndexPolicy(useLowestIndex), nil)).To(Equal(backend3))
 			})
 		})
 
@@ -492,7 +970,7 @@ var _ = Describe("ClusterMonitor", func() {
 						Index:   0,
 					}
 
-					Expect(monitor.ChooseActiveBackend(statuses, useLowestIndex)).To(Equal(backend3))
+					Expect(monitor.ChooseActiveBackend(statuses, monitor.NewIndexPolicy(useLowestIndex), nil)).To(Equal(backend3))
 				})
 			})
 
@@ -517,10 +995,42 @@ var _ = Describe("ClusterMonitor", func() {
 						Index:   0,
 					}
 
-					Expect(monitor.ChooseActiveBackend(statuses, useLowestIndex)).To(Equal(backend2))
+					Expect(monitor.ChooseActiveBackend(statuses, monitor.NewIndexPolicy(useLowestIndex), nil)).To(Equal(backend2))
 				})
 			})
 		})
+
+		Context("If a healthy backend is flapping", func() {
+			It("chooses another healthy one", func() {
+				statuses[backend1] = &monitor.BackendStatus{
+					Healthy:  true,
+					Flapping: true,
+					Index:    0,
+				}
+
+				statuses[backend2] = &monitor.BackendStatus{
+					Healthy: true,
+					Index:   1,
+				}
+
+				Expect(monitor.ChooseActiveBackend(statuses, monitor.NewIndexPolicy(useLowestIndex), nil)).To(Equal(backend2))
+			})
+
+			It("chooses it when no other backend is healthy", func() {
+				statuses[backend1] = &monitor.BackendStatus{
+					Healthy:  true,
+					Flapping: true,
+					Index:    0,
+				}
+
+				statuses[backend2] = &monitor.BackendStatus{
+					Healthy: false,
+					Index:   1,
+				}
+
+				Expect(monitor.ChooseActiveBackend(statuses, monitor.NewIndexPolicy(useLowestIndex), nil)).To(Equal(backend1))
+			})
+		})
 	})
 })
 
@@ -541,3 +1051,13 @@ func unhealthyResponse(index int) *http.Response {
 		StatusCode: http.StatusOK,
 	}
 }
+
+func backendForURL(backends []*domain.Backend, url string) *domain.Backend {
+	for _, backend := range backends {
+		if backend.HealthcheckUrl() == url {
+			return backend
+		}
+	}
+
+	panic("Unexpected backend")
+}
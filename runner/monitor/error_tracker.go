@@ -0,0 +1,88 @@
+package monitor
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/cloudfoundry-incubator/switchboard/domain"
+)
+
+// ErrorTracker counts the errors clients hit on each backend - failed dials,
+// connect timeouts and resets - over a sliding window. Once threshold errors
+// fall within the window the backend is suspect: its health is checked at
+// once, and a health check that passes while it is suspect counts as failed.
+// This catches a backend whose health check agent reports it healthy while
+// its SQL port refuses connections.
+type ErrorTracker struct {
+	threshold uint
+	window    time.Duration
+	logger    lager.Logger
+
+	// BackendSuspect is told about each error on a suspect backend, e.g. to
+	// recheck it.
+	BackendSuspect func(*domain.Backend)
+
+	mutex  sync.Mutex
+	errors map[*domain.Backend][]time.Time
+}
+
+func NewErrorTracker(threshold uint, window time.Duration, logger lager.Logger) *ErrorTracker {
+	return &ErrorTracker{
+		threshold: threshold,
+		window:    window,
+		logger:    logger,
+		errors:    map[*domain.Backend][]time.Time{},
+	}
+}
+
+// RecordError notes that a client hit an error of the given kind on the
+// backend. It can be set as the backend's error observer.
+func (t *ErrorTracker) RecordError(backend *domain.Backend, kind string) {
+	now := time.Now()
+
+	t.mutex.Lock()
+	errors := append(t.unsafeErrorsSince(backend, now), now)
+	t.errors[backend] = errors
+	suspect := t.unsafeSuspect(errors)
+	t.mutex.Unlock()
+
+	if !suspect {
+		t.logger.Debug("Client hit an error on backend", lager.Data{"backend": backend.AsJSON(), "kind": kind})
+		return
+	}
+
+	t.logger.Info("Backend is suspect after clients hit errors on it", lager.Data{
+		"backend":      backend.AsJSON(),
+		"kind":         kind,
+		"recentErrors": len(errors),
+	})
+	if t.BackendSuspect != nil {
+		t.BackendSuspect(backend)
+	}
+}
+
+// Suspect reports whether threshold errors were hit on the backend within
+// the window before now.
+func (t *ErrorTracker) Suspect(backend *domain.Backend, now time.Time) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	errors := t.unsafeErrorsSince(backend, now)
+	t.errors[backend] = errors
+	return t.unsafeSuspect(errors)
+}
+
+func (t *ErrorTracker) unsafeSuspect(errors []time.Time) bool {
+	return t.threshold > 0 && uint(len(errors)) >= t.threshold
+}
+
+func (t *ErrorTracker) unsafeErrorsSince(backend *domain.Backend, now time.Time) []time.Time {
+	errors := t.errors[backend]
+
+	windowStart := now.Add(-t.window)
+	for len(errors) > 0 && errors[0].Before(windowStart) {
+		errors = errors[1:]
+	}
+	return errors
+}
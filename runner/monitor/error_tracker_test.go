@@ -0,0 +1,67 @@
+package monitor_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry-incubator/switchboard/domain"
+	"github.com/cloudfoundry-incubator/switchboard/runner/monitor"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ErrorTracker", func() {
+	var (
+		errorTracker       *monitor.ErrorTracker
+		backend0, backend1 *domain.Backend
+		suspects           []*domain.Backend
+	)
+
+	BeforeEach(func() {
+		logger := lagertest.NewTestLogger("ErrorTracker test")
+		backend0 = domain.NewBackend("backend-0", "10.10.0.2", 3306, 9200, "", logger)
+		backend1 = domain.NewBackend("backend-1", "10.10.1.2", 3306, 9200, "", logger)
+
+		suspects = nil
+		errorTracker = monitor.NewErrorTracker(2, 100*time.Millisecond, logger)
+		errorTracker.BackendSuspect = func(backend *domain.Backend) {
+			suspects = append(suspects, backend)
+		}
+	})
+
+	It("is not suspect below the threshold", func() {
+		errorTracker.RecordError(backend0, domain.BackendErrorDial)
+
+		Expect(errorTracker.Suspect(backend0, time.Now())).To(BeFalse())
+		Expect(suspects).To(BeEmpty())
+	})
+
+	It("makes the backend suspect at the threshold", func() {
+		errorTracker.RecordError(backend0, domain.BackendErrorDial)
+		errorTracker.RecordError(backend0, domain.BackendErrorReset)
+
+		Expect(errorTracker.Suspect(backend0, time.Now())).To(BeTrue())
+		Expect(suspects).To(Equal([]*domain.Backend{backend0}))
+
+		Expect(errorTracker.Suspect(backend1, time.Now())).To(BeFalse(), "counts each backend separately")
+	})
+
+	It("only counts errors within the window", func() {
+		errorTracker.RecordError(backend0, domain.BackendErrorConnectTimeout)
+		errorTracker.RecordError(backend0, domain.BackendErrorConnectTimeout)
+
+		Expect(errorTracker.Suspect(backend0, time.Now().Add(200*time.Millisecond))).To(BeFalse())
+	})
+
+	Context("when the threshold is zero", func() {
+		BeforeEach(func() {
+			errorTracker = monitor.NewErrorTracker(0, time.Minute, lagertest.NewTestLogger("ErrorTracker test"))
+		})
+
+		It("never makes a backend suspect", func() {
+			errorTracker.RecordError(backend0, domain.BackendErrorDial)
+
+			Expect(errorTracker.Suspect(backend0, time.Now())).To(BeFalse())
+		})
+	})
+})
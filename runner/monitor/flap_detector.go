@@ -0,0 +1,72 @@
+package monitor
+
+import "time"
+
+// FlapDetector counts a backend's health state changes over a sliding window.
+// Once threshold changes fall within the window the backend is flapping, and
+// it is held out of selection for up to penalty after its latest change. The
+// penalty decays as changes slide out of the window: it shrinks in proportion
+// to how many of the threshold changes are still within it, so a backend
+// that settles down works its way out of the penalty early.
+type FlapDetector struct {
+	threshold uint
+	window    time.Duration
+	penalty   time.Duration
+
+	transitions []time.Time
+	flapping    bool
+}
+
+func NewFlapDetector(threshold uint, window, penalty time.Duration) *FlapDetector {
+	return &FlapDetector{
+		threshold: threshold,
+		window:    window,
+		penalty:   penalty,
+	}
+}
+
+// RecordTransition notes that the backend changed state at now.
+func (f *FlapDetector) RecordTransition(now time.Time) {
+	f.expire(now)
+	f.transitions = append(f.transitions, now)
+
+	if uint(len(f.transitions)) >= f.threshold {
+		f.flapping = true
+	}
+}
+
+// PenaltyDeadline is when the backend may be selected again, or the zero time
+// if it is not flapping at now.
+func (f *FlapDetector) PenaltyDeadline(now time.Time) time.Time {
+	f.expire(now)
+	if !f.flapping {
+		return time.Time{}
+	}
+	return f.deadline()
+}
+
+// expire drops the changes that slid out of the window, and ends the penalty
+// once its decayed deadline has passed.
+func (f *FlapDetector) expire(now time.Time) {
+	windowStart := now.Add(-f.window)
+	for len(f.transitions) > 0 && f.transitions[0].Before(windowStart) {
+		f.transitions = f.transitions[1:]
+	}
+
+	if f.flapping && !now.Before(f.deadline()) {
+		f.flapping = false
+	}
+}
+
+func (f *FlapDetector) deadline() time.Time {
+	if len(f.transitions) == 0 {
+		return time.Time{}
+	}
+
+	inWindow := uint(len(f.transitions))
+	if inWindow > f.threshold {
+		inWindow = f.threshold
+	}
+	penalty := f.penalty * time.Duration(inWindow) / time.Duration(f.threshold)
+	return f.transitions[len(f.transitions)-1].Add(penalty)
+}
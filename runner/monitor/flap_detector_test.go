@@ -0,0 +1,69 @@
+package monitor_test
+
+import (
+	"time"
+
+	"github.com/cloudfoundry-incubator/switchboard/runner/monitor"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FlapDetector", func() {
+	var (
+		flapDetector *monitor.FlapDetector
+		start        time.Time
+	)
+
+	BeforeEach(func() {
+		flapDetector = monitor.NewFlapDetector(3, time.Minute, 2*time.Minute)
+		start = time.Now()
+	})
+
+	It("is not flapping below the threshold", func() {
+		flapDetector.RecordTransition(start)
+		flapDetector.RecordTransition(start.Add(10 * time.Second))
+
+		Expect(flapDetector.PenaltyDeadline(start.Add(10 * time.Second)).IsZero()).To(BeTrue())
+	})
+
+	It("holds the backend out for the penalty after the latest change", func() {
+		flapDetector.RecordTransition(start)
+		flapDetector.RecordTransition(start.Add(10 * time.Second))
+		flapDetector.RecordTransition(start.Add(20 * time.Second))
+
+		deadline := start.Add(20 * time.Second).Add(2 * time.Minute)
+		Expect(flapDetector.PenaltyDeadline(start.Add(30 * time.Second))).To(Equal(deadline))
+
+		flapDetector.RecordTransition(start.Add(40 * time.Second))
+		Expect(flapDetector.PenaltyDeadline(start.Add(40*time.Second))).To(Equal(deadline.Add(20*time.Second)), "flapping on extends the penalty")
+
+		Expect(flapDetector.PenaltyDeadline(deadline.Add(20 * time.Second)).IsZero()).To(BeTrue())
+	})
+
+	It("shortens the penalty as changes slide out of the window", func() {
+		flapDetector.RecordTransition(start)
+		flapDetector.RecordTransition(start.Add(10 * time.Second))
+		flapDetector.RecordTransition(start.Add(20 * time.Second))
+
+		Expect(flapDetector.PenaltyDeadline(start.Add(65*time.Second))).To(Equal(start.Add(100*time.Second)), "two of three changes are left")
+		Expect(flapDetector.PenaltyDeadline(start.Add(75 * time.Second)).IsZero()).To(BeTrue())
+	})
+
+	It("needs threshold changes again once the penalty is over", func() {
+		flapDetector.RecordTransition(start)
+		flapDetector.RecordTransition(start.Add(10 * time.Second))
+		flapDetector.RecordTransition(start.Add(20 * time.Second))
+		Expect(flapDetector.PenaltyDeadline(start.Add(75 * time.Second)).IsZero()).To(BeTrue())
+
+		flapDetector.RecordTransition(start.Add(76 * time.Second))
+		Expect(flapDetector.PenaltyDeadline(start.Add(76 * time.Second)).IsZero()).To(BeTrue())
+	})
+
+	It("only counts changes within the window", func() {
+		flapDetector.RecordTransition(start)
+		flapDetector.RecordTransition(start.Add(50 * time.Second))
+		flapDetector.RecordTransition(start.Add(70 * time.Second))
+
+		Expect(flapDetector.PenaltyDeadline(start.Add(70 * time.Second)).IsZero()).To(BeTrue())
+	})
+})
@@ -0,0 +1,160 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/cloudfoundry-incubator/galera-healthcheck/api"
+	"github.com/cloudfoundry-incubator/switchboard/config"
+	"github.com/cloudfoundry-incubator/switchboard/domain"
+)
+
+//go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 . HealthChecker
+
+// HealthChecker decides whether a backend can take traffic. Checks that know
+// the backend's index within its cluster return it, others return a nil
+// index and the backend keeps its position in the config as its index. The
+// error explains why a backend is unhealthy.
+type HealthChecker interface {
+	Check(backend *domain.Backend) (healthy bool, index *int, err error)
+}
+
+// NewHealthChecker returns the health check selected by the backend's config.
+func NewHealthChecker(backendConfig config.Backend, healthcheckTimeout time.Duration) (HealthChecker, error) {
+	switch backendConfig.HealthCheck {
+	case "", config.HealthCheckGalera:
+		return NewGaleraHealthChecker(UrlGetterProvider(healthcheckTimeout)), nil
+	case config.HealthCheckHTTP:
+		return NewHTTPHealthChecker(UrlGetterProvider(healthcheckTimeout)), nil
+	case config.HealthCheckTCP:
+		return NewTCPHealthChecker(healthcheckTimeout), nil
+	case config.HealthCheckExec:
+		return NewExecHealthChecker(backendConfig.HealthCheckCommand, healthcheckTimeout), nil
+	case config.HealthCheckMySQL:
+		return NewMySQLHealthChecker(backendConfig.HealthCheckUsername, backendConfig.HealthCheckPassword, healthcheckTimeout), nil
+	default:
+		return nil, errors.New(fmt.Sprintf("Unknown health check %q for backend %s", backendConfig.HealthCheck, backendConfig.Name))
+	}
+}
+
+// GaleraHealthChecker reads the status reported by galera-healthcheck.
+type GaleraHealthChecker struct {
+	client UrlGetter
+}
+
+func NewGaleraHealthChecker(client UrlGetter) *GaleraHealthChecker {
+	return &GaleraHealthChecker{client: client}
+}
+
+func (g *GaleraHealthChecker) Check(backend *domain.Backend) (bool, *int, error) {
+	url := backend.HealthcheckUrl()
+
+	resp, err := g.client.Get(url)
+	if err != nil {
+		return false, nil, errors.New(fmt.Sprintf("Error during healthcheck http get of %s: %s", url, err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, nil, errors.New(fmt.Sprintf("Healthcheck %s responded with status %d", url, resp.StatusCode))
+	}
+
+	var v1StatusResponse api.V1StatusResponse
+	_ = json.NewDecoder(resp.Body).Decode(&v1StatusResponse)
+
+	index := int(v1StatusResponse.WsrepLocalIndex)
+	if !v1StatusResponse.Healthy {
+		return false, &index, errors.New("Backend reported as unhealthy")
+	}
+
+	return true, &index, nil
+}
+
+// HTTPHealthChecker passes backends whose status endpoint responds with a
+// 2xx status code.
+type HTTPHealthChecker struct {
+	client UrlGetter
+}
+
+func NewHTTPHealthChecker(client UrlGetter) *HTTPHealthChecker {
+	return &HTTPHealthChecker{client: client}
+}
+
+func (h *HTTPHealthChecker) Check(backend *domain.Backend) (bool, *int, error) {
+	url := backend.HealthcheckUrl()
+
+	resp, err := h.client.Get(url)
+	if err != nil {
+		return false, nil, errors.New(fmt.Sprintf("Error during healthcheck http get of %s: %s", url, err))
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return false, nil, errors.New(fmt.Sprintf("Healthcheck %s responded with status %d", url, resp.StatusCode))
+	}
+
+	return true, nil, nil
+}
+
+// TCPHealthChecker passes backends that accept a connection on their proxied
+// port.
+type TCPHealthChecker struct {
+	timeout time.Duration
+}
+
+func NewTCPHealthChecker(timeout time.Duration) *TCPHealthChecker {
+	return &TCPHealthChecker{timeout: timeout}
+}
+
+func (t *TCPHealthChecker) Check(backend *domain.Backend) (bool, *int, error) {
+	j := backend.AsJSON()
+	addr := net.JoinHostPort(j.Host, fmt.Sprint(j.Port))
+
+	conn, err := net.DialTimeout("tcp", addr, t.timeout)
+	if err != nil {
+		return false, nil, errors.New(fmt.Sprintf("Error connecting to %s: %s", addr, err))
+	}
+	conn.Close()
+
+	return true, nil, nil
+}
+
+// ExecHealthChecker passes backends for which a shell command exits with
+// status 0. The command finds the backend in the BACKEND_NAME, BACKEND_HOST,
+// BACKEND_PORT and BACKEND_STATUS_PORT environment variables.
+type ExecHealthChecker struct {
+	command string
+	timeout time.Duration
+}
+
+func NewExecHealthChecker(command string, timeout time.Duration) *ExecHealthChecker {
+	return &ExecHealthChecker{command: command, timeout: timeout}
+}
+
+func (e *ExecHealthChecker) Check(backend *domain.Backend) (bool, *int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	j := backend.AsJSON()
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", e.command)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("BACKEND_NAME=%s", j.Name),
+		fmt.Sprintf("BACKEND_HOST=%s", j.Host),
+		fmt.Sprintf("BACKEND_PORT=%d", j.Port),
+		fmt.Sprintf("BACKEND_STATUS_PORT=%d", j.StatusPort),
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return false, nil, errors.New(fmt.Sprintf("Health check command %q failed: %s: %s", e.command, err, output))
+	}
+
+	return true, nil, nil
+}
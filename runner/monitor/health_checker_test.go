@@ -0,0 +1,189 @@
+package monitor_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry-incubator/switchboard/config"
+	"github.com/cloudfoundry-incubator/switchboard/domain"
+	"github.com/cloudfoundry-incubator/switchboard/runner/monitor"
+	"github.com/cloudfoundry-incubator/switchboard/runner/monitor/monitorfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HealthChecker", func() {
+	var (
+		logger    *lagertest.TestLogger
+		backend   *domain.Backend
+		urlGetter *monitorfakes.FakeUrlGetter
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("HealthChecker test")
+		backend = domain.NewBackend("backend-0", "192.0.2.10", 3306, 9200, "custom/status", logger)
+		urlGetter = new(monitorfakes.FakeUrlGetter)
+	})
+
+	statusResponse := func(statusCode int) *http.Response {
+		return &http.Response{
+			Body:       ioutil.NopCloser(bytes.NewBuffer(nil)),
+			StatusCode: statusCode,
+		}
+	}
+
+	Describe("NewHealthChecker", func() {
+		It("defaults to galera-healthcheck", func() {
+			healthChecker, err := monitor.NewHealthChecker(config.Backend{}, time.Second)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(healthChecker).To(BeAssignableToTypeOf(&monitor.GaleraHealthChecker{}))
+		})
+
+		It("returns the configured health check", func() {
+			healthChecker, err := monitor.NewHealthChecker(config.Backend{HealthCheck: "http"}, time.Second)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(healthChecker).To(BeAssignableToTypeOf(&monitor.HTTPHealthChecker{}))
+
+			healthChecker, err = monitor.NewHealthChecker(config.Backend{HealthCheck: "tcp"}, time.Second)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(healthChecker).To(BeAssignableToTypeOf(&monitor.TCPHealthChecker{}))
+
+			healthChecker, err = monitor.NewHealthChecker(config.Backend{HealthCheck: "exec", HealthCheckCommand: "true"}, time.Second)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(healthChecker).To(BeAssignableToTypeOf(&monitor.ExecHealthChecker{}))
+
+			healthChecker, err = monitor.NewHealthChecker(config.Backend{HealthCheck: "mysql", HealthCheckUsername: "monitor"}, time.Second)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(healthChecker).To(BeAssignableToTypeOf(&monitor.MySQLHealthChecker{}))
+		})
+
+		It("returns an error for an unknown health check", func() {
+			_, err := monitor.NewHealthChecker(config.Backend{Name: "backend-0", HealthCheck: "ping"}, time.Second)
+			Expect(err).To(MatchError(ContainSubstring("Unknown health check")))
+		})
+	})
+
+	Describe("GaleraHealthChecker", func() {
+		It("reports the health and index from the backend's status endpoint", func() {
+			urlGetter.GetReturns(healthyResponse(2), nil)
+
+			healthy, index, err := monitor.NewGaleraHealthChecker(urlGetter).Check(backend)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(healthy).To(BeTrue())
+			Expect(*index).To(Equal(2))
+
+			Expect(urlGetter.GetArgsForCall(0)).To(Equal("http://192.0.2.10:9200/custom/status"))
+		})
+
+		It("reports the index of an unhealthy backend", func() {
+			urlGetter.GetReturns(unhealthyResponse(1), nil)
+
+			healthy, index, err := monitor.NewGaleraHealthChecker(urlGetter).Check(backend)
+			Expect(err).To(MatchError("Backend reported as unhealthy"))
+			Expect(healthy).To(BeFalse())
+			Expect(*index).To(Equal(1))
+		})
+
+		It("fails when the status endpoint does not respond with 200", func() {
+			urlGetter.GetReturns(statusResponse(http.StatusServiceUnavailable), nil)
+
+			healthy, index, err := monitor.NewGaleraHealthChecker(urlGetter).Check(backend)
+			Expect(err).To(MatchError(ContainSubstring("responded with status 503")))
+			Expect(healthy).To(BeFalse())
+			Expect(index).To(BeNil())
+		})
+	})
+
+	Describe("HTTPHealthChecker", func() {
+		It("passes on a 2xx status code", func() {
+			urlGetter.GetReturns(statusResponse(http.StatusNoContent), nil)
+
+			healthy, index, err := monitor.NewHTTPHealthChecker(urlGetter).Check(backend)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(healthy).To(BeTrue())
+			Expect(index).To(BeNil())
+
+			Expect(urlGetter.GetArgsForCall(0)).To(Equal("http://192.0.2.10:9200/custom/status"))
+		})
+
+		It("fails on any other status code", func() {
+			urlGetter.GetReturns(statusResponse(http.StatusInternalServerError), nil)
+
+			healthy, _, err := monitor.NewHTTPHealthChecker(urlGetter).Check(backend)
+			Expect(err).To(MatchError(ContainSubstring("responded with status 500")))
+			Expect(healthy).To(BeFalse())
+		})
+
+		It("fails when the status endpoint is unreachable", func() {
+			urlGetter.GetReturns(nil, errors.New("connection refused"))
+
+			healthy, _, err := monitor.NewHTTPHealthChecker(urlGetter).Check(backend)
+			Expect(err).To(MatchError(ContainSubstring("connection refused")))
+			Expect(healthy).To(BeFalse())
+		})
+	})
+
+	Describe("TCPHealthChecker", func() {
+		var listener net.Listener
+
+		BeforeEach(func() {
+			var err error
+			listener, err = net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+
+			port := listener.Addr().(*net.TCPAddr).Port
+			backend = domain.NewBackend("backend-0", "127.0.0.1", uint(port), 0, "", logger)
+		})
+
+		AfterEach(func() {
+			listener.Close()
+		})
+
+		It("passes when the backend accepts a connection", func() {
+			healthy, index, err := monitor.NewTCPHealthChecker(time.Second).Check(backend)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(healthy).To(BeTrue())
+			Expect(index).To(BeNil())
+		})
+
+		It("fails when the backend refuses connections", func() {
+			listener.Close()
+
+			healthy, _, err := monitor.NewTCPHealthChecker(time.Second).Check(backend)
+			Expect(err).To(HaveOccurred())
+			Expect(healthy).To(BeFalse())
+		})
+	})
+
+	Describe("ExecHealthChecker", func() {
+		It("passes when the command exits with status 0", func() {
+			command := `test "$BACKEND_NAME:$BACKEND_HOST:$BACKEND_PORT:$BACKEND_STATUS_PORT" = backend-0:192.0.2.10:3306:9200`
+
+			healthy, index, err := monitor.NewExecHealthChecker(command, time.Second).Check(backend)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(healthy).To(BeTrue())
+			Expect(index).To(BeNil())
+		})
+
+		It("fails with the command's output when it exits with another status", func() {
+			command := "echo not synced; exit 1"
+
+			healthy, _, err := monitor.NewExecHealthChecker(command, time.Second).Check(backend)
+			Expect(err).To(MatchError(ContainSubstring("not synced")))
+			Expect(healthy).To(BeFalse())
+		})
+
+		It("fails when the command times out", func() {
+			command := "exec sleep 5"
+
+			healthy, _, err := monitor.NewExecHealthChecker(command, 100*time.Millisecond).Check(backend)
+			Expect(err).To(HaveOccurred())
+			Expect(healthy).To(BeFalse())
+		})
+	})
+})
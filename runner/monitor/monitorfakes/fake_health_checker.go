@@ -0,0 +1,121 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package monitorfakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/switchboard/domain"
+	"github.com/cloudfoundry-incubator/switchboard/runner/monitor"
+)
+
+type FakeHealthChecker struct {
+	CheckStub        func(*domain.Backend) (bool, *int, error)
+	checkMutex       sync.RWMutex
+	checkArgsForCall []struct {
+		arg1 *domain.Backend
+	}
+	checkReturns struct {
+		result1 bool
+		result2 *int
+		result3 error
+	}
+	checkReturnsOnCall map[int]struct {
+		result1 bool
+		result2 *int
+		result3 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeHealthChecker) Check(arg1 *domain.Backend) (bool, *int, error) {
+	fake.checkMutex.Lock()
+	ret, specificReturn := fake.checkReturnsOnCall[len(fake.checkArgsForCall)]
+	fake.checkArgsForCall = append(fake.checkArgsForCall, struct {
+		arg1 *domain.Backend
+	}{arg1})
+	fake.recordInvocation("Check", []interface{}{arg1})
+	fake.checkMutex.Unlock()
+	if fake.CheckStub != nil {
+		return fake.CheckStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	fakeReturns := fake.checkReturns
+	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3
+}
+
+func (fake *FakeHealthChecker) CheckCallCount() int {
+	fake.checkMutex.RLock()
+	defer fake.checkMutex.RUnlock()
+	return len(fake.checkArgsForCall)
+}
+
+func (fake *FakeHealthChecker) CheckCalls(stub func(*domain.Backend) (bool, *int, error)) {
+	fake.checkMutex.Lock()
+	defer fake.checkMutex.Unlock()
+	fake.CheckStub = stub
+}
+
+func (fake *FakeHealthChecker) CheckArgsForCall(i int) *domain.Backend {
+	fake.checkMutex.RLock()
+	defer fake.checkMutex.RUnlock()
+	argsForCall := fake.checkArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeHealthChecker) CheckReturns(result1 bool, result2 *int, result3 error) {
+	fake.checkMutex.Lock()
+	defer fake.checkMutex.Unlock()
+	fake.CheckStub = nil
+	fake.checkReturns = struct {
+		result1 bool
+		result2 *int
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeHealthChecker) CheckReturnsOnCall(i int, result1 bool, result2 *int, result3 error) {
+	fake.checkMutex.Lock()
+	defer fake.checkMutex.Unlock()
+	fake.CheckStub = nil
+	if fake.checkReturnsOnCall == nil {
+		fake.checkReturnsOnCall = make(map[int]struct {
+			result1 bool
+			result2 *int
+			result3 error
+		})
+	}
+	fake.checkReturnsOnCall[i] = struct {
+		result1 bool
+		result2 *int
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeHealthChecker) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.checkMutex.RLock()
+	defer fake.checkMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeHealthChecker) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ monitor.HealthChecker = new(FakeHealthChecker)
@@ -0,0 +1,367 @@
+package monitor
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/cloudfoundry-incubator/switchboard/domain"
+	"github.com/cloudfoundry-incubator/switchboard/mysql"
+)
+
+const (
+	// the largest packet the health check accepts, sent in its handshake
+	mysqlMaxPacketSize    = 1 << 24
+	mysqlWsrepStateSynced = "4"
+)
+
+// MySQLHealthChecker logs in to a backend over the MySQL protocol and derives
+// its health from the Galera status variables, for clusters that do not run
+// galera-healthcheck. A backend is healthy when it belongs to the primary
+// component, is synced and is writable; its index is wsrep_local_index.
+type MySQLHealthChecker struct {
+	username string
+	password string
+	timeout  time.Duration
+}
+
+func NewMySQLHealthChecker(username, password string, timeout time.Duration) *MySQLHealthChecker {
+	return &MySQLHealthChecker{
+		username: username,
+		password: password,
+		timeout:  timeout,
+	}
+}
+
+func (m *MySQLHealthChecker) Check(backend *domain.Backend) (bool, *int, error) {
+	j := backend.AsJSON()
+	addr := net.JoinHostPort(j.Host, fmt.Sprint(j.Port))
+
+	// the timeout bounds the whole check, connecting included
+	deadline := time.Now().Add(m.timeout)
+
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		return false, nil, errors.New(fmt.Sprintf("Error connecting to %s: %s", addr, err))
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(deadline)
+	if err != nil {
+		return false, nil, err
+	}
+
+	client := &mysqlClient{conn: conn}
+	err = client.logIn(m.username, m.password)
+	if err != nil {
+		return false, nil, errors.New(fmt.Sprintf("Error logging in to %s: %s", addr, err))
+	}
+	defer client.quit()
+
+	status, err := client.queryVariables("SHOW GLOBAL STATUS LIKE 'wsrep_%'")
+	if err != nil {
+		return false, nil, errors.New(fmt.Sprintf("Error reading wsrep status from %s: %s", addr, err))
+	}
+
+	variables, err := client.queryVariables("SHOW GLOBAL VARIABLES LIKE 'read_only'")
+	if err != nil {
+		return false, nil, errors.New(fmt.Sprintf("Error reading read_only from %s: %s", addr, err))
+	}
+
+	return galeraHealth(status, variables["read_only"])
+}
+
+func galeraHealth(status map[string]string, readOnly string) (bool, *int, error) {
+	var index *int
+	// a node outside the primary component reports an index of -1 as an
+	// unsigned integer, which does not parse
+	if localIndex, err := strconv.Atoi(status["wsrep_local_index"]); err == nil {
+		index = &localIndex
+	}
+
+	if clusterStatus := status["wsrep_cluster_status"]; clusterStatus != "Primary" {
+		return false, index, errors.New(fmt.Sprintf("Backend is not in the primary component, wsrep_cluster_status is %q", clusterStatus))
+	}
+	if status["wsrep_local_state"] != mysqlWsrepStateSynced {
+		return false, index, errors.New(fmt.Sprintf("Backend is not synced, wsrep_local_state_comment is %q", status["wsrep_local_state_comment"]))
+	}
+	if readOnly == "ON" {
+		return false, index, errors.New("Backend is read only")
+	}
+
+	return true, index, nil
+}
+
+// mysqlClient speaks just enough of the MySQL client/server protocol to log
+// in with mysql_native_password and read the results of simple queries.
+type mysqlClient struct {
+	conn     net.Conn
+	sequence byte
+}
+
+func (c *mysqlClient) logIn(username, password string) error {
+	greeting, err := c.readPacket(mysql.MaxHandshakePacketSize)
+	if err != nil {
+		return err
+	}
+	if greeting[0] == mysql.ErrPacket {
+		return mysqlError(greeting)
+	}
+
+	salt, err := parseMySQLGreeting(greeting)
+	if err != nil {
+		return err
+	}
+
+	authResponse := scrambleMySQLPassword(password, salt)
+
+	response := new(bytes.Buffer)
+	binary.Write(response, binary.LittleEndian, uint32(mysql.Capabilities))
+	binary.Write(response, binary.LittleEndian, uint32(mysqlMaxPacketSize))
+	response.WriteByte(mysql.CharsetUTF8)
+	response.Write(make([]byte, 23)) // reserved
+	response.WriteString(username)
+	response.WriteByte(0)
+	response.WriteByte(byte(len(authResponse)))
+	response.Write(authResponse)
+	response.WriteString(mysql.NativePassword)
+	response.WriteByte(0)
+
+	err = c.writePacket(response.Bytes())
+	if err != nil {
+		return err
+	}
+
+	for {
+		packet, err := c.readPacket(mysql.MaxHandshakePacketSize)
+		if err != nil {
+			return err
+		}
+
+		switch packet[0] {
+		case mysql.OKPacket:
+			return nil
+		case mysql.ErrPacket:
+			return mysqlError(packet)
+		case mysql.EOFPacket:
+			// an auth switch request: the server asks for the password again, with a new salt
+			plugin, data := splitNullTerminated(packet[1:])
+			if plugin != mysql.NativePassword {
+				return errors.New(fmt.Sprintf("Unsupported authentication plugin %s", plugin))
+			}
+
+			err = c.writePacket(scrambleMySQLPassword(password, bytes.TrimSuffix(data, []byte{0})))
+			if err != nil {
+				return err
+			}
+		default:
+			return errors.New(fmt.Sprintf("Unexpected packet 0x%02x while logging in", packet[0]))
+		}
+	}
+}
+
+// queryVariables runs a SHOW STATUS or SHOW VARIABLES statement and returns
+// the values by name.
+func (c *mysqlClient) queryVariables(query string) (map[string]string, error) {
+	rows, err := c.query(query)
+	if err != nil {
+		return nil, err
+	}
+
+	variables := map[string]string{}
+	for _, row := range rows {
+		if len(row) == 2 {
+			variables[row[0]] = row[1]
+		}
+	}
+	return variables, nil
+}
+
+func (c *mysqlClient) query(query string) ([][]string, error) {
+	c.sequence = 0
+	err := c.writePacket(append([]byte{mysql.ComQuery}, query...))
+	if err != nil {
+		return nil, err
+	}
+
+	packet, err := c.readPacket(mysql.MaxPacketSize)
+	if err != nil {
+		return nil, err
+	}
+	switch packet[0] {
+	case mysql.OKPacket:
+		return nil, nil
+	case mysql.ErrPacket:
+		return nil, mysqlError(packet)
+	}
+
+	columnCount, _ := readLengthEncodedInt(packet)
+	for i := uint64(0); i < columnCount; i++ {
+		_, err = c.readPacket(mysql.MaxPacketSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// end of the column definitions
+	_, err = c.readPacket(mysql.MaxPacketSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for {
+		packet, err = c.readPacket(mysql.MaxPacketSize)
+		if err != nil {
+			return nil, err
+		}
+		if packet[0] == mysql.EOFPacket && len(packet) < 9 {
+			return rows, nil
+		}
+		if packet[0] == mysql.ErrPacket {
+			return nil, mysqlError(packet)
+		}
+
+		var row []string
+		for len(packet) > 0 {
+			var value []byte
+			value, packet = readLengthEncodedString(packet)
+			row = append(row, string(value))
+		}
+		rows = append(rows, row)
+	}
+}
+
+func (c *mysqlClient) quit() {
+	c.sequence = 0
+	_ = c.writePacket([]byte{mysql.ComQuit})
+}
+
+// readPacket reads the next packet from the server, refusing one longer than
+// maxLength.
+func (c *mysqlClient) readPacket(maxLength int) ([]byte, error) {
+	payload, sequence, err := mysql.ReadPacket(c.conn, maxLength)
+	if err != nil {
+		return nil, err
+	}
+	c.sequence = sequence + 1
+
+	if len(payload) == 0 {
+		return nil, errors.New("Empty packet from server")
+	}
+	return payload, nil
+}
+
+func (c *mysqlClient) writePacket(payload []byte) error {
+	err := mysql.WritePacket(c.conn, c.sequence, payload)
+	c.sequence++
+	return err
+}
+
+// parseMySQLGreeting returns the salt from a protocol version 10 handshake.
+func parseMySQLGreeting(greeting []byte) ([]byte, error) {
+	if len(greeting) == 0 || greeting[0] != 10 {
+		return nil, errors.New("Unsupported protocol version in server greeting")
+	}
+
+	_, rest := splitNullTerminated(greeting[1:]) // server version
+	// connection id, first part of the salt, filler, capabilities,
+	// character set, status flags, capabilities, salt length and reserved
+	if len(rest) < 4+8+1+2+1+2+2+1+10 {
+		return nil, errors.New("Server greeting is too short")
+	}
+
+	salt := append([]byte{}, rest[4:12]...)
+
+	// mysql_native_password needs a 20 byte salt; the length counts the
+	// null terminator, and is 0 from servers without plugin auth, whose
+	// salt is 20 bytes too
+	saltLength := int(rest[20])
+	if saltLength != 0 && saltLength < 21 {
+		return nil, errors.New(fmt.Sprintf("Server greeting has a salt of %d bytes, %s needs 20", saltLength-1, mysql.NativePassword))
+	}
+
+	// the second part of the salt is null terminated and at least 13 bytes
+	// with the terminator
+	secondPartLength := saltLength - 8
+	if secondPartLength < 13 {
+		secondPartLength = 13
+	}
+	rest = rest[31:]
+	if len(rest) < secondPartLength {
+		return nil, errors.New("Server greeting is too short for its salt")
+	}
+	return append(salt, rest[:12]...), nil
+}
+
+// scrambleMySQLPassword computes the mysql_native_password auth response
+// SHA1(password) XOR SHA1(salt + SHA1(SHA1(password))).
+func scrambleMySQLPassword(password string, salt []byte) []byte {
+	if password == "" {
+		return nil
+	}
+
+	passwordHash := sha1.Sum([]byte(password))
+	passwordHashHash := sha1.Sum(passwordHash[:])
+	saltedHash := sha1.Sum(append(append([]byte{}, salt...), passwordHashHash[:]...))
+
+	scrambled := make([]byte, len(passwordHash))
+	for i := range scrambled {
+		scrambled[i] = passwordHash[i] ^ saltedHash[i]
+	}
+	return scrambled
+}
+
+func mysqlError(packet []byte) error {
+	if len(packet) < 3 {
+		return errors.New("Malformed error packet from server")
+	}
+
+	code := binary.LittleEndian.Uint16(packet[1:3])
+	message := packet[3:]
+	if len(message) >= 6 && message[0] == '#' {
+		message = message[6:] // SQL state
+	}
+	return errors.New(fmt.Sprintf("Error %d: %s", code, message))
+}
+
+func splitNullTerminated(b []byte) (string, []byte) {
+	i := bytes.IndexByte(b, 0)
+	if i < 0 {
+		return string(b), nil
+	}
+	return string(b[:i]), b[i+1:]
+}
+
+func readLengthEncodedInt(b []byte) (uint64, []byte) {
+	switch {
+	case len(b) == 0:
+	case b[0] < 0xfb:
+		return uint64(b[0]), b[1:]
+	case b[0] == 0xfc && len(b) >= 3:
+		return uint64(binary.LittleEndian.Uint16(b[1:3])), b[3:]
+	case b[0] == 0xfd && len(b) >= 4:
+		return uint64(b[1]) | uint64(b[2])<<8 | uint64(b[3])<<16, b[4:]
+	case b[0] == 0xfe && len(b) >= 9:
+		return binary.LittleEndian.Uint64(b[1:9]), b[9:]
+	}
+	return 0, nil
+}
+
+func readLengthEncodedString(b []byte) ([]byte, []byte) {
+	if len(b) > 0 && b[0] == 0xfb { // NULL
+		return nil, b[1:]
+	}
+
+	length, rest := readLengthEncodedInt(b)
+	if uint64(len(rest)) < length {
+		return rest, nil
+	}
+	return rest[:length], rest[length:]
+}
@@ -0,0 +1,309 @@
+package monitor_test
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry-incubator/switchboard/domain"
+	"github.com/cloudfoundry-incubator/switchboard/mysql"
+	"github.com/cloudfoundry-incubator/switchboard/runner/monitor"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MySQLHealthChecker", func() {
+	var (
+		server  *fakeMySQLServer
+		backend *domain.Backend
+	)
+
+	BeforeEach(func() {
+		server = newFakeMySQLServer("monitor", "secret")
+		server.status = map[string]string{
+			"wsrep_cluster_status":      "Primary",
+			"wsrep_local_state":         "4",
+			"wsrep_local_state_comment": "Synced",
+			"wsrep_local_index":         "1",
+		}
+		server.readOnly = "OFF"
+
+		port := server.listener.Addr().(*net.TCPAddr).Port
+		backend = domain.NewBackend("backend-0", "127.0.0.1", uint(port), 0, "", lagertest.NewTestLogger("MySQLHealthChecker test"))
+	})
+
+	AfterEach(func() {
+		server.listener.Close()
+	})
+
+	check := func(password string) (bool, *int, error) {
+		return monitor.NewMySQLHealthChecker("monitor", password, time.Second).Check(backend)
+	}
+
+	It("passes a synced node in the primary component and reports its index", func() {
+		healthy, index, err := check("secret")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(healthy).To(BeTrue())
+		Expect(*index).To(Equal(1))
+	})
+
+	It("fails a node that is not synced", func() {
+		server.status["wsrep_local_state"] = "2"
+		server.status["wsrep_local_state_comment"] = "Donor/Desynced"
+
+		healthy, index, err := check("secret")
+		Expect(err).To(MatchError(ContainSubstring("Donor/Desynced")))
+		Expect(healthy).To(BeFalse())
+		Expect(*index).To(Equal(1))
+	})
+
+	It("fails a node outside the primary component", func() {
+		server.status["wsrep_cluster_status"] = "non-Primary"
+		server.status["wsrep_local_index"] = "18446744073709551615"
+
+		healthy, index, err := check("secret")
+		Expect(err).To(MatchError(ContainSubstring("non-Primary")))
+		Expect(healthy).To(BeFalse())
+		Expect(index).To(BeNil())
+	})
+
+	It("fails a read only node", func() {
+		server.readOnly = "ON"
+
+		healthy, _, err := check("secret")
+		Expect(err).To(MatchError(ContainSubstring("read only")))
+		Expect(healthy).To(BeFalse())
+	})
+
+	It("fails when the credentials are rejected", func() {
+		healthy, _, err := check("wrong")
+		Expect(err).To(MatchError(ContainSubstring("Error 1045: Access denied")))
+		Expect(healthy).To(BeFalse())
+	})
+
+	It("answers a request to switch to mysql_native_password", func() {
+		server.switchAuth = true
+
+		healthy, _, err := check("secret")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(healthy).To(BeTrue())
+	})
+
+	Describe("a malformed greeting", func() {
+		var greeting []byte
+
+		BeforeEach(func() {
+			greeting = []byte{10}
+			greeting = append(greeting, "5.7.0-fake\x00"...)
+			greeting = append(greeting, 1, 0, 0, 0)
+			greeting = append(greeting, "abcdefgh"...)
+			greeting = append(greeting, 0, 0x00, 0xa2, 33, 2, 0, 0x08, 0, 21)
+			greeting = append(greeting, make([]byte, 10)...)
+		})
+
+		It("fails when the salt is shorter than its length says", func() {
+			server.setGreeting(append(greeting, "ijkl"...))
+
+			healthy, _, err := check("secret")
+			Expect(err).To(MatchError(ContainSubstring("too short for its salt")))
+			Expect(healthy).To(BeFalse())
+		})
+
+		It("fails when the salt is too short for mysql_native_password", func() {
+			greeting[len(greeting)-11] = 9
+			server.setGreeting(append(greeting, "ijklmnopqrst\x00"...))
+
+			healthy, _, err := check("secret")
+			Expect(err).To(MatchError(ContainSubstring("salt of 8 bytes")))
+			Expect(healthy).To(BeFalse())
+		})
+
+		It("fails when it is empty", func() {
+			server.setGreeting([]byte{})
+
+			healthy, _, err := check("secret")
+			Expect(err).To(HaveOccurred())
+			Expect(healthy).To(BeFalse())
+		})
+
+		It("refuses a greeting longer than a handshake packet", func() {
+			server.setGreeting(append(greeting, make([]byte, mysql.MaxHandshakePacketSize)...))
+
+			healthy, _, err := check("secret")
+			Expect(err).To(MatchError(ContainSubstring("exceeds the limit")))
+			Expect(healthy).To(BeFalse())
+		})
+	})
+
+	It("fails when the backend is unreachable", func() {
+		server.listener.Close()
+
+		healthy, _, err := check("secret")
+		Expect(err).To(MatchError(ContainSubstring("Error connecting")))
+		Expect(healthy).To(BeFalse())
+	})
+})
+
+// fakeMySQLServer accepts mysql_native_password logins and answers the
+// queries of the health check from status and readOnly. A greeting set with
+// setGreeting is sent in place of the usual one, and the connection closed
+// after it.
+type fakeMySQLServer struct {
+	listener     net.Listener
+	greetingLock sync.Mutex
+	greeting     []byte
+	username     string
+	passwordHash [sha1.Size]byte
+	switchAuth   bool
+	status       map[string]string
+	readOnly     string
+}
+
+func newFakeMySQLServer(username, password string) *fakeMySQLServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+
+	passwordHash := sha1.Sum([]byte(password))
+	server := &fakeMySQLServer{
+		listener:     listener,
+		username:     username,
+		passwordHash: sha1.Sum(passwordHash[:]),
+	}
+
+	go func() {
+		defer GinkgoRecover()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	return server
+}
+
+func (s *fakeMySQLServer) setGreeting(greeting []byte) {
+	s.greetingLock.Lock()
+	defer s.greetingLock.Unlock()
+	s.greeting = greeting
+}
+
+func (s *fakeMySQLServer) serve(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	s.greetingLock.Lock()
+	greetingOverride := s.greeting
+	s.greetingLock.Unlock()
+	if greetingOverride != nil {
+		writeFakeMySQLPacket(conn, 0, greetingOverride)
+		return
+	}
+
+	salt := []byte("abcdefghijklmnopqrst")
+	greeting := []byte{10}
+	greeting = append(greeting, "5.7.0-fake\x00"...)
+	greeting = append(greeting, 1, 0, 0, 0)
+	greeting = append(greeting, salt[:8]...)
+	greeting = append(greeting, 0, 0x00, 0xa2, 33, 2, 0, 0x08, 0, 21)
+	greeting = append(greeting, make([]byte, 10)...)
+	greeting = append(greeting, salt[8:]...)
+	greeting = append(greeting, 0)
+	greeting = append(greeting, "mysql_native_password\x00"...)
+	writeFakeMySQLPacket(conn, 0, greeting)
+
+	response, sequence := readFakeMySQLPacket(conn)
+	username := string(response[32 : 32+bytes.IndexByte(response[32:], 0)])
+	rest := response[32+len(username)+1:]
+	authResponse := rest[1 : 1+rest[0]]
+
+	if s.switchAuth {
+		salt = []byte("tsrqponmlkjihgfedcba")
+		writeFakeMySQLPacket(conn, sequence+1, append(append([]byte("\xfemysql_native_password\x00"), salt...), 0))
+		authResponse, sequence = readFakeMySQLPacket(conn)
+	}
+
+	if username != s.username || !s.passwordMatches(salt, authResponse) {
+		writeFakeMySQLPacket(conn, sequence+1, append([]byte{0xff, 0x15, 0x04, '#', '2', '8', '0', '0', '0'}, "Access denied for user"...))
+		return
+	}
+	writeFakeMySQLPacket(conn, sequence+1, []byte{0, 0, 0, 2, 0, 0, 0})
+
+	for {
+		command, _ := readFakeMySQLPacket(conn)
+		if command == nil || command[0] != 0x03 {
+			return
+		}
+
+		query := string(command[1:])
+		rows := map[string]string{}
+		switch {
+		case strings.HasPrefix(query, "SHOW GLOBAL STATUS LIKE 'wsrep_%'"):
+			rows = s.status
+		case strings.HasPrefix(query, "SHOW GLOBAL VARIABLES LIKE 'read_only'"):
+			rows["read_only"] = s.readOnly
+		}
+		s.writeResultSet(conn, rows)
+	}
+}
+
+// passwordMatches checks a mysql_native_password auth response against the
+// stored SHA1(SHA1(password)).
+func (s *fakeMySQLServer) passwordMatches(salt, authResponse []byte) bool {
+	if len(authResponse) != sha1.Size {
+		return false
+	}
+
+	saltedHash := sha1.Sum(append(append([]byte{}, salt...), s.passwordHash[:]...))
+	passwordHash := make([]byte, sha1.Size)
+	for i := range passwordHash {
+		passwordHash[i] = authResponse[i] ^ saltedHash[i]
+	}
+	return sha1.Sum(passwordHash) == s.passwordHash
+}
+
+func (s *fakeMySQLServer) writeResultSet(conn net.Conn, rows map[string]string) {
+	eof := []byte{0xfe, 0, 0, 2, 0}
+
+	writeFakeMySQLPacket(conn, 1, []byte{2})
+	writeFakeMySQLPacket(conn, 2, []byte("\x03def\x00\x00\x00\x0dVariable_name"))
+	writeFakeMySQLPacket(conn, 3, []byte("\x03def\x00\x00\x00\x05Value"))
+	writeFakeMySQLPacket(conn, 4, eof)
+
+	sequence := byte(5)
+	for name, value := range rows {
+		row := append([]byte{byte(len(name))}, name...)
+		row = append(row, byte(len(value)))
+		row = append(row, value...)
+		writeFakeMySQLPacket(conn, sequence, row)
+		sequence++
+	}
+	writeFakeMySQLPacket(conn, sequence, eof)
+}
+
+func writeFakeMySQLPacket(conn net.Conn, sequence byte, payload []byte) {
+	length := len(payload)
+	conn.Write(append([]byte{byte(length), byte(length >> 8), byte(length >> 16), sequence}, payload...))
+}
+
+func readFakeMySQLPacket(conn net.Conn) ([]byte, byte) {
+	header := make([]byte, 4)
+	_, err := io.ReadFull(conn, header)
+	if err != nil {
+		return nil, 0
+	}
+
+	payload := make([]byte, int(header[0])|int(header[1])<<8|int(header[2])<<16)
+	_, err = io.ReadFull(conn, payload)
+	if err != nil {
+		return nil, 0
+	}
+	return payload, header[3]
+}
@@ -0,0 +1,193 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cloudfoundry-incubator/switchboard/config"
+	"github.com/cloudfoundry-incubator/switchboard/domain"
+)
+
+// SelectionPolicy ranks the healthy backends of a listener. The most preferred
+// becomes the listener's active backend, and the others are tried in order
+// when a connection is retried.
+type SelectionPolicy interface {
+	// Less reports whether a is preferred over b.
+	Less(a, b Candidate) bool
+}
+
+// Candidate is a healthy backend ranked by a SelectionPolicy.
+type Candidate struct {
+	Backend *domain.Backend
+	// Index is the backend's wsrep_local_index, or its position in the config
+	// when its health check does not report one.
+	Index int
+	// Active is set for the listener's current active backend.
+	Active bool
+	// Sessions is how many sessions the backend had when the candidates
+	// were ranked, read once so that every comparison agrees.
+	Sessions uint
+}
+
+// NewSelectionPolicy returns the policy selected by the listener's config,
+// made sticky if the listener's failback is. backendConfigs and backends are
+// in the same order. useLowestIndex sets the direction of the index order,
+// which the other policies fall back on to break ties.
+func NewSelectionPolicy(
+	listenerConfig config.Listener,
+	backendConfigs []config.Backend,
+	backends []*domain.Backend,
+	useLowestIndex bool,
+) (SelectionPolicy, error) {
+	selectionPolicy, err := newSelectionPolicy(listenerConfig, backendConfigs, backends, useLowestIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	if listenerConfig.Failback == config.FailbackSticky {
+		return NewStickyPolicy(selectionPolicy), nil
+	}
+	return selectionPolicy, nil
+}
+
+func newSelectionPolicy(
+	listenerConfig config.Listener,
+	backendConfigs []config.Backend,
+	backends []*domain.Backend,
+	useLowestIndex bool,
+) (SelectionPolicy, error) {
+	indexPolicy := NewIndexPolicy(useLowestIndex)
+
+	switch listenerConfig.SelectionPolicy {
+	case "", config.SelectionPolicyIndex:
+		return indexPolicy, nil
+	case config.SelectionPolicyPriority:
+		priorities := map[*domain.Backend]uint{}
+		for i, backend := range backends {
+			priorities[backend] = backendConfigs[i].Priority
+		}
+		return NewPriorityPolicy(priorities, indexPolicy), nil
+	case config.SelectionPolicyPreferred:
+		backendsByName := map[string]*domain.Backend{}
+		for i, backend := range backends {
+			backendsByName[backendConfigs[i].Name] = backend
+		}
+
+		var preferred []*domain.Backend
+		for _, name := range listenerConfig.PreferredBackends {
+			backend, ok := backendsByName[name]
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("Unknown preferred backend %s", name))
+			}
+			preferred = append(preferred, backend)
+		}
+		return NewPreferredPolicy(preferred, indexPolicy), nil
+	case config.SelectionPolicyLeastSessions:
+		return NewLeastSessionsPolicy(indexPolicy), nil
+	default:
+		return nil, errors.New(fmt.Sprintf("Unknown selection policy %q", listenerConfig.SelectionPolicy))
+	}
+}
+
+// IndexPolicy prefers the lowest index, or the highest.
+type IndexPolicy struct {
+	useLowestIndex bool
+}
+
+func NewIndexPolicy(useLowestIndex bool) *IndexPolicy {
+	return &IndexPolicy{useLowestIndex: useLowestIndex}
+}
+
+func (p *IndexPolicy) Less(a, b Candidate) bool {
+	if p.useLowestIndex {
+		return a.Index < b.Index
+	}
+	return a.Index > b.Index
+}
+
+// PriorityPolicy prefers the backend with the highest static priority, and
+// falls back on another policy for backends of equal priority.
+type PriorityPolicy struct {
+	priorities map[*domain.Backend]uint
+	fallback   SelectionPolicy
+}
+
+func NewPriorityPolicy(priorities map[*domain.Backend]uint, fallback SelectionPolicy) *PriorityPolicy {
+	return &PriorityPolicy{priorities: priorities, fallback: fallback}
+}
+
+func (p *PriorityPolicy) Less(a, b Candidate) bool {
+	aPriority, bPriority := p.priorities[a.Backend], p.priorities[b.Backend]
+	if aPriority != bPriority {
+		return aPriority > bPriority
+	}
+	return p.fallback.Less(a, b)
+}
+
+// PreferredPolicy prefers the backends in the given order, e.g. those in the
+// local availability zone, and falls back on another policy for the rest.
+type PreferredPolicy struct {
+	ranks    map[*domain.Backend]int
+	fallback SelectionPolicy
+}
+
+func NewPreferredPolicy(preferred []*domain.Backend, fallback SelectionPolicy) *PreferredPolicy {
+	ranks := map[*domain.Backend]int{}
+	for i, backend := range preferred {
+		ranks[backend] = i + 1
+	}
+	return &PreferredPolicy{ranks: ranks, fallback: fallback}
+}
+
+func (p *PreferredPolicy) Less(a, b Candidate) bool {
+	aRank, aPreferred := p.ranks[a.Backend]
+	bRank, bPreferred := p.ranks[b.Backend]
+	switch {
+	case aPreferred && bPreferred && aRank != bRank:
+		return aRank < bRank
+	case aPreferred != bPreferred:
+		return aPreferred
+	}
+	return p.fallback.Less(a, b)
+}
+
+// LeastSessionsPolicy prefers the backend with the fewest sessions. Moving
+// the active backend severs its sessions, so the current active backend is
+// kept while it is healthy and counts are only compared to replace it.
+type LeastSessionsPolicy struct {
+	fallback SelectionPolicy
+}
+
+func NewLeastSessionsPolicy(fallback SelectionPolicy) *LeastSessionsPolicy {
+	return &LeastSessionsPolicy{fallback: fallback}
+}
+
+func (p *LeastSessionsPolicy) Less(a, b Candidate) bool {
+	if a.Active != b.Active {
+		return a.Active
+	}
+
+	if a.Sessions != b.Sessions {
+		return a.Sessions < b.Sessions
+	}
+	return p.fallback.Less(a, b)
+}
+
+// StickyPolicy keeps the current active backend for as long as it is healthy,
+// instead of failing back to a backend the wrapped policy prefers, which
+// would move all of its clients. ClusterMonitor.Failback moves them at a time
+// of the operator's choosing.
+type StickyPolicy struct {
+	policy SelectionPolicy
+}
+
+func NewStickyPolicy(policy SelectionPolicy) *StickyPolicy {
+	return &StickyPolicy{policy: policy}
+}
+
+func (p *StickyPolicy) Less(a, b Candidate) bool {
+	if a.Active != b.Active {
+		return a.Active
+	}
+	return p.policy.Less(a, b)
+}
@@ -0,0 +1,194 @@
+package monitor_test
+
+import (
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry-incubator/switchboard/config"
+	"github.com/cloudfoundry-incubator/switchboard/domain"
+	"github.com/cloudfoundry-incubator/switchboard/domain/domainfakes"
+	"github.com/cloudfoundry-incubator/switchboard/runner/monitor"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SelectionPolicy", func() {
+	var (
+		logger                       *lagertest.TestLogger
+		backend0, backend1, backend2 *domain.Backend
+		backends                     []*domain.Backend
+		backendConfigs               []config.Backend
+		statuses                     map[*domain.Backend]*monitor.BackendStatus
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("SelectionPolicy test")
+
+		backend0 = domain.NewBackend("backend-0", "10.10.0.2", 3306, 9200, "", logger)
+		backend1 = domain.NewBackend("backend-1", "10.10.1.2", 3306, 9200, "", logger)
+		backend2 = domain.NewBackend("backend-2", "10.10.2.2", 3306, 9200, "", logger)
+		backends = []*domain.Backend{backend0, backend1, backend2}
+
+		backendConfigs = []config.Backend{
+			{Name: "backend-0"},
+			{Name: "backend-1", Priority: 10},
+			{Name: "backend-2", Priority: 10},
+		}
+
+		statuses = map[*domain.Backend]*monitor.BackendStatus{
+			backend0: {Healthy: true, Index: 0},
+			backend1: {Healthy: true, Index: 1},
+			backend2: {Healthy: true, Index: 2},
+		}
+	})
+
+	newPolicy := func(listenerConfig config.Listener, useLowestIndex bool) monitor.SelectionPolicy {
+		policy, err := monitor.NewSelectionPolicy(listenerConfig, backendConfigs, backends, useLowestIndex)
+		Expect(err).NotTo(HaveOccurred())
+		return policy
+	}
+
+	Describe("NewSelectionPolicy", func() {
+		It("defaults to the index order", func() {
+			policy := newPolicy(config.Listener{}, true)
+			Expect(policy).To(BeAssignableToTypeOf(&monitor.IndexPolicy{}))
+		})
+
+		It("makes the policy sticky when the listener's failback is", func() {
+			policy := newPolicy(config.Listener{SelectionPolicy: "priority", Failback: "sticky"}, true)
+			Expect(policy).To(BeAssignableToTypeOf(&monitor.StickyPolicy{}))
+		})
+
+		It("returns an error for an unknown policy", func() {
+			_, err := monitor.NewSelectionPolicy(config.Listener{SelectionPolicy: "random"}, backendConfigs, backends, true)
+			Expect(err).To(MatchError(ContainSubstring("Unknown selection policy")))
+		})
+
+		It("returns an error for an unknown preferred backend", func() {
+			listenerConfig := config.Listener{SelectionPolicy: "preferred", PreferredBackends: []string{"backend-9"}}
+
+			_, err := monitor.NewSelectionPolicy(listenerConfig, backendConfigs, backends, true)
+			Expect(err).To(MatchError(ContainSubstring("backend-9")))
+		})
+	})
+
+	Describe("IndexPolicy", func() {
+		It("prefers the lowest or highest index", func() {
+			Expect(monitor.HealthyBackends(statuses, newPolicy(config.Listener{SelectionPolicy: "index"}, true), nil)).To(Equal([]*domain.Backend{backend0, backend1, backend2}))
+			Expect(monitor.HealthyBackends(statuses, newPolicy(config.Listener{SelectionPolicy: "index"}, false), nil)).To(Equal([]*domain.Backend{backend2, backend1, backend0}))
+		})
+	})
+
+	Describe("PriorityPolicy", func() {
+		It("prefers the highest priority and breaks ties by index", func() {
+			policy := newPolicy(config.Listener{SelectionPolicy: "priority"}, true)
+			Expect(monitor.HealthyBackends(statuses, policy, nil)).To(Equal([]*domain.Backend{backend1, backend2, backend0}))
+
+			policy = newPolicy(config.Listener{SelectionPolicy: "priority"}, false)
+			Expect(monitor.HealthyBackends(statuses, policy, nil)).To(Equal([]*domain.Backend{backend2, backend1, backend0}))
+		})
+	})
+
+	Describe("PreferredPolicy", func() {
+		var policy monitor.SelectionPolicy
+
+		BeforeEach(func() {
+			policy = newPolicy(config.Listener{
+				SelectionPolicy:   "preferred",
+				PreferredBackends: []string{"backend-2", "backend-1"},
+			}, true)
+		})
+
+		It("prefers the backends in order, regardless of their index", func() {
+			Expect(monitor.ChooseActiveBackend(statuses, policy, nil)).To(Equal(backend2))
+			Expect(monitor.HealthyBackends(statuses, policy, nil)).To(Equal([]*domain.Backend{backend2, backend1, backend0}))
+		})
+
+		It("falls back on the index order when no preferred backend is healthy", func() {
+			statuses[backend1].Healthy = false
+			statuses[backend2].Healthy = false
+
+			Expect(monitor.ChooseActiveBackend(statuses, policy, nil)).To(Equal(backend0))
+		})
+	})
+
+	Describe("LeastSessionsPolicy", func() {
+		var policy monitor.SelectionPolicy
+
+		BeforeEach(func() {
+			policy = newPolicy(config.Listener{SelectionPolicy: "least-sessions"}, true)
+		})
+
+		withSessions := func(name string, sessions uint) *domain.Backend {
+			bridges := new(domainfakes.FakeBridges)
+			bridges.SizeReturns(sessions)
+			domain.BridgesProvider = func(lager.Logger) domain.Bridges {
+				return bridges
+			}
+			defer func() {
+				domain.BridgesProvider = domain.NewBridges
+			}()
+
+			return domain.NewBackend(name, "10.10.3.2", 3306, 9200, "", logger)
+		}
+
+		It("prefers the backend with the fewest sessions", func() {
+			busy := withSessions("busy", 5)
+			idle := withSessions("idle", 1)
+			statuses = map[*domain.Backend]*monitor.BackendStatus{
+				busy: {Healthy: true, Index: 0},
+				idle: {Healthy: true, Index: 1},
+			}
+
+			Expect(monitor.ChooseActiveBackend(statuses, policy, nil)).To(Equal(idle))
+		})
+
+		It("keeps the current active backend", func() {
+			busy := withSessions("busy", 5)
+			idle := withSessions("idle", 1)
+			statuses = map[*domain.Backend]*monitor.BackendStatus{
+				busy: {Healthy: true, Index: 0},
+				idle: {Healthy: true, Index: 1},
+			}
+
+			Expect(monitor.ChooseActiveBackend(statuses, policy, busy)).To(Equal(busy))
+		})
+
+		It("falls back on the index order when session counts are equal", func() {
+			Expect(monitor.ChooseActiveBackend(statuses, policy, nil)).To(Equal(backend0))
+		})
+
+		It("keeps the config order of backends that tie", func() {
+			statuses = map[*domain.Backend]*monitor.BackendStatus{
+				backend0: {Healthy: true, Position: 0},
+				backend1: {Healthy: true, Position: 1},
+				backend2: {Healthy: true, Position: 2},
+			}
+
+			for i := 0; i < 20; i++ {
+				Expect(monitor.HealthyBackends(statuses, policy, nil)).To(Equal([]*domain.Backend{backend0, backend1, backend2}))
+			}
+		})
+	})
+
+	Describe("StickyPolicy", func() {
+		var policy monitor.SelectionPolicy
+
+		BeforeEach(func() {
+			policy = monitor.NewStickyPolicy(monitor.NewIndexPolicy(true))
+		})
+
+		It("keeps the current active backend while it is healthy", func() {
+			Expect(monitor.HealthyBackends(statuses, policy, backend2)).To(Equal([]*domain.Backend{backend2, backend0, backend1}))
+		})
+
+		It("follows the wrapped policy without an active backend", func() {
+			Expect(monitor.ChooseActiveBackend(statuses, policy, nil)).To(Equal(backend0))
+		})
+
+		It("moves on when the active backend is unhealthy", func() {
+			statuses[backend2].Healthy = false
+
+			Expect(monitor.ChooseActiveBackend(statuses, policy, backend2)).To(Equal(backend0))
+		})
+	})
+})
@@ -0,0 +1,252 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/cloudfoundry-incubator/switchboard/config"
+	"github.com/cloudfoundry-incubator/switchboard/events"
+)
+
+// Headers sent with each notification. The signature is the hex HMAC-SHA256
+// of the body keyed with the webhook's secret, prefixed with sha256=.
+const (
+	EventHeader     = "X-Switchboard-Event"
+	SignatureHeader = "X-Switchboard-Signature"
+)
+
+const (
+	defaultQueueSize   = 100
+	defaultMaxAttempts = 5
+
+	// maxRetryBackoff caps the doubling wait between attempts.
+	maxRetryBackoff = time.Minute
+)
+
+// DefaultEvents are the kinds of event sent to a webhook that does not list
+// any: those worth paging someone about.
+var DefaultEvents = []string{
+	events.KindActiveBackendChanged,
+	events.KindTrafficEnabled,
+	events.KindTrafficDisabled,
+}
+
+// Payload is the JSON body POSTed for an event: its fields, plus a summary
+// that can be posted into chat as is.
+type Payload struct {
+	events.Event
+	Summary string `json:"summary"`
+}
+
+// WebhookNotifier POSTs events to the configured webhooks. Each webhook has
+// its own bounded queue and worker, so that one that is slow or down does
+// not hold up the others.
+type WebhookNotifier struct {
+	webhooks     []*webhook
+	client       *http.Client
+	maxAttempts  uint
+	retryBackoff time.Duration
+	logger       lager.Logger
+}
+
+type webhook struct {
+	url    string
+	host   string
+	secret []byte
+	events map[string]bool
+	queue  chan events.Event
+}
+
+func NewWebhookNotifier(notificationsConfig config.Notifications, logger lager.Logger) *WebhookNotifier {
+	queueSize := notificationsConfig.QueueSize
+	if queueSize == 0 {
+		queueSize = defaultQueueSize
+	}
+	maxAttempts := notificationsConfig.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+
+	var webhooks []*webhook
+	for _, webhookConfig := range notificationsConfig.Webhooks {
+		kinds := webhookConfig.Events
+		if len(kinds) == 0 {
+			kinds = DefaultEvents
+		}
+
+		w := &webhook{
+			url:    webhookConfig.URL,
+			secret: []byte(webhookConfig.Secret),
+			events: map[string]bool{},
+			queue:  make(chan events.Event, queueSize),
+		}
+		for _, kind := range kinds {
+			w.events[kind] = true
+		}
+		// webhook URLs often embed a token, so only the host is logged
+		if u, err := url.Parse(webhookConfig.URL); err == nil {
+			w.host = u.Host
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	return &WebhookNotifier{
+		webhooks:     webhooks,
+		client:       &http.Client{Timeout: notificationsConfig.Timeout()},
+		maxAttempts:  maxAttempts,
+		retryBackoff: notificationsConfig.RetryBackoff(),
+		logger:       logger,
+	}
+}
+
+// Notify queues the event for each webhook that is sent its kind. It never
+// blocks: while a webhook's queue is full, the event is dropped for it.
+func (n *WebhookNotifier) Notify(event events.Event) {
+	for _, w := range n.webhooks {
+		if !w.events[event.Kind] {
+			continue
+		}
+		select {
+		case w.queue <- event:
+		default:
+			n.logger.Error("Notification queue full, dropping event", errors.New("queue full"), lager.Data{
+				"webhook": w.host,
+				"event":   event,
+			})
+		}
+	}
+}
+
+// Deliver starts a worker for each webhook that sends the queued events
+// until shutdown is closed.
+func (n *WebhookNotifier) Deliver(shutdown <-chan interface{}) {
+	for _, w := range n.webhooks {
+		go func(w *webhook) {
+			for {
+				select {
+				case event := <-w.queue:
+					n.deliver(w, event, shutdown)
+				case <-shutdown:
+					return
+				}
+			}
+		}(w)
+	}
+}
+
+// deliver POSTs the event, retrying with exponential backoff until it is
+// accepted, the webhook rejects it outright or the attempts run out.
+func (n *WebhookNotifier) deliver(w *webhook, event events.Event, shutdown <-chan interface{}) {
+	body, err := json.Marshal(Payload{Event: event, Summary: event.Summary()})
+	if err != nil {
+		n.logger.Error("Failed to encode notification", err, lager.Data{"event": event})
+		return
+	}
+
+	backoff := n.retryBackoff
+	for attempt := uint(1); ; attempt++ {
+		err = n.post(w, event.Kind, body)
+		if err == nil {
+			return
+		}
+
+		data := lager.Data{
+			"webhook": w.host,
+			"event":   event,
+			"attempt": attempt,
+		}
+		if !retryable(err) || attempt >= n.maxAttempts {
+			n.logger.Error("Failed to deliver notification", err, data)
+			return
+		}
+		n.logger.Info("Retrying notification", lager.Data{
+			"webhook": w.host,
+			"attempt": attempt,
+			"error":   err.Error(),
+			"backoff": backoff.String(),
+		})
+
+		select {
+		case <-time.After(backoff):
+		case <-shutdown:
+			n.logger.Error("Failed to deliver notification before shutdown", err, data)
+			return
+		}
+
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+func (n *WebhookNotifier) post(w *webhook, kind string, body []byte) error {
+	req, err := http.NewRequest("POST", w.url, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, kind)
+	if len(w.secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(w.secret, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// reading the body lets the connection be reused
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return statusError{resp.StatusCode}
+	}
+	return nil
+}
+
+// Sign returns the signature header value for the body.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type statusError struct {
+	statusCode int
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("webhook responded with status %d", e.statusCode)
+}
+
+type permanentError struct {
+	error
+}
+
+// retryable reports whether the delivery may succeed if tried again. The
+// webhook rejecting the request, other than for timing out or rate limiting,
+// will not change on a retry.
+func retryable(err error) bool {
+	switch err := err.(type) {
+	case permanentError:
+		return false
+	case statusError:
+		return err.statusCode >= 500 ||
+			err.statusCode == http.StatusRequestTimeout ||
+			err.statusCode == http.StatusTooManyRequests
+	default:
+		return true
+	}
+}
@@ -0,0 +1,220 @@
+package notifier_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry-incubator/switchboard/config"
+	"github.com/cloudfoundry-incubator/switchboard/events"
+	"github.com/cloudfoundry-incubator/switchboard/runner/notifier"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("WebhookNotifier", func() {
+	type delivery struct {
+		header http.Header
+		body   []byte
+	}
+
+	var (
+		logger              *lagertest.TestLogger
+		receiver            *httptest.Server
+		deliveries          chan delivery
+		statusCodes         []int
+		attempts            int32
+		notificationsConfig config.Notifications
+		webhookNotifier     *notifier.WebhookNotifier
+		shutdown            chan interface{}
+		event               events.Event
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("WebhookNotifier test")
+
+		deliveries = make(chan delivery, 10)
+		statusCodes = nil
+		attempts = 0
+		receiver = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			defer GinkgoRecover()
+			Expect(req.Method).To(Equal("POST"))
+
+			// the receiver answers with each of statusCodes in turn, then 200
+			attempt := int(atomic.AddInt32(&attempts, 1))
+			if attempt <= len(statusCodes) {
+				w.WriteHeader(statusCodes[attempt-1])
+				return
+			}
+
+			body, err := ioutil.ReadAll(req.Body)
+			Expect(err).NotTo(HaveOccurred())
+			deliveries <- delivery{header: req.Header, body: body}
+		}))
+
+		notificationsConfig = config.Notifications{
+			Webhooks:           []config.Webhook{{URL: receiver.URL + "/hook", Secret: "secret"}},
+			RetryBackoffMillis: 1,
+		}
+
+		event = events.Event{
+			Time:            time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			Kind:            events.KindActiveBackendChanged,
+			Listener:        "active",
+			Backend:         "backend-1",
+			PreviousBackend: "backend-0",
+			Reason:          events.ReasonUnhealthy,
+		}
+	})
+
+	JustBeforeEach(func() {
+		webhookNotifier = notifier.NewWebhookNotifier(notificationsConfig, logger)
+		shutdown = make(chan interface{})
+		webhookNotifier.Deliver(shutdown)
+	})
+
+	AfterEach(func() {
+		close(shutdown)
+		receiver.Close()
+	})
+
+	It("POSTs the event and its summary as signed JSON", func() {
+		webhookNotifier.Notify(event)
+
+		var d delivery
+		Eventually(deliveries).Should(Receive(&d))
+		Expect(d.header.Get("Content-Type")).To(Equal("application/json"))
+		Expect(d.header.Get(notifier.EventHeader)).To(Equal(events.KindActiveBackendChanged))
+		Expect(d.header.Get(notifier.SignatureHeader)).To(Equal(notifier.Sign([]byte("secret"), d.body)))
+
+		var payload notifier.Payload
+		Expect(json.Unmarshal(d.body, &payload)).To(Succeed())
+		Expect(payload.Event).To(Equal(event))
+		Expect(payload.Summary).To(Equal("active listener moved from backend-0 to backend-1 (unhealthy)"))
+	})
+
+	It("only sends the default kinds of event", func() {
+		webhookNotifier.Notify(events.Event{Kind: events.KindBackendUnhealthy, Backend: "backend-0"})
+		webhookNotifier.Notify(events.Event{Kind: events.KindTrafficDisabled, Message: "upgrading"})
+
+		var d delivery
+		Eventually(deliveries).Should(Receive(&d))
+		Expect(d.header.Get(notifier.EventHeader)).To(Equal(events.KindTrafficDisabled))
+		Consistently(deliveries, 50*time.Millisecond).ShouldNot(Receive())
+	})
+
+	Context("when the webhook lists the events it is sent", func() {
+		BeforeEach(func() {
+			notificationsConfig.Webhooks[0].Events = []string{events.KindBackendUnhealthy}
+		})
+
+		It("only sends those", func() {
+			webhookNotifier.Notify(event)
+			webhookNotifier.Notify(events.Event{Kind: events.KindBackendUnhealthy, Backend: "backend-0"})
+
+			var d delivery
+			Eventually(deliveries).Should(Receive(&d))
+			Expect(d.header.Get(notifier.EventHeader)).To(Equal(events.KindBackendUnhealthy))
+			Consistently(deliveries, 50*time.Millisecond).ShouldNot(Receive())
+		})
+	})
+
+	Context("when the webhook has no secret", func() {
+		BeforeEach(func() {
+			notificationsConfig.Webhooks[0].Secret = ""
+		})
+
+		It("does not sign the body", func() {
+			webhookNotifier.Notify(event)
+
+			var d delivery
+			Eventually(deliveries).Should(Receive(&d))
+			Expect(d.header).NotTo(HaveKey(notifier.SignatureHeader))
+		})
+	})
+
+	Context("when the webhook fails", func() {
+		BeforeEach(func() {
+			statusCodes = []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}
+		})
+
+		It("retries until it succeeds", func() {
+			webhookNotifier.Notify(event)
+
+			Eventually(deliveries).Should(Receive())
+			Expect(atomic.LoadInt32(&attempts)).To(Equal(int32(3)))
+			Expect(logger).To(gbytes.Say("Retrying notification"))
+		})
+
+		Context("more times than it may be attempted", func() {
+			BeforeEach(func() {
+				notificationsConfig.MaxAttempts = 2
+			})
+
+			It("gives up", func() {
+				webhookNotifier.Notify(event)
+
+				Eventually(logger).Should(gbytes.Say("Failed to deliver notification"))
+				Consistently(deliveries, 50*time.Millisecond).ShouldNot(Receive())
+				Expect(atomic.LoadInt32(&attempts)).To(Equal(int32(2)))
+			})
+		})
+	})
+
+	Context("when the webhook rejects the request", func() {
+		BeforeEach(func() {
+			statusCodes = []int{http.StatusNotFound}
+		})
+
+		It("does not retry", func() {
+			webhookNotifier.Notify(event)
+
+			Eventually(logger).Should(gbytes.Say("Failed to deliver notification"))
+			Expect(logger).NotTo(gbytes.Say("Retrying notification"))
+			Expect(atomic.LoadInt32(&attempts)).To(Equal(int32(1)))
+		})
+	})
+
+	Context("when a webhook's queue is full", func() {
+		var (
+			blocked  *httptest.Server
+			received chan struct{}
+			release  chan struct{}
+		)
+
+		BeforeEach(func() {
+			received = make(chan struct{}, 10)
+			release = make(chan struct{})
+			blocked = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				received <- struct{}{}
+				<-release
+			}))
+
+			notificationsConfig.QueueSize = 1
+			notificationsConfig.Webhooks = append(notificationsConfig.Webhooks, config.Webhook{URL: blocked.URL})
+		})
+
+		AfterEach(func() {
+			close(release)
+			blocked.Close()
+		})
+
+		It("drops events for it without holding up the others", func() {
+			webhookNotifier.Notify(event)
+			Eventually(received).Should(Receive())
+			Eventually(deliveries).Should(Receive())
+
+			// one event waits in the queue, the next is dropped
+			webhookNotifier.Notify(event)
+			webhookNotifier.Notify(event)
+
+			Expect(logger).To(gbytes.Say("Notification queue full, dropping event"))
+			Eventually(deliveries).Should(Receive())
+		})
+	})
+})
@@ -0,0 +1,114 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package notifierfakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/switchboard/events"
+	"github.com/cloudfoundry-incubator/switchboard/runner/notifier"
+)
+
+type FakeNotifier struct {
+	DeliverStub        func(<-chan interface{})
+	deliverMutex       sync.RWMutex
+	deliverArgsForCall []struct {
+		arg1 <-chan interface{}
+	}
+	NotifyStub        func(events.Event)
+	notifyMutex       sync.RWMutex
+	notifyArgsForCall []struct {
+		arg1 events.Event
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeNotifier) Deliver(arg1 <-chan interface{}) {
+	fake.deliverMutex.Lock()
+	fake.deliverArgsForCall = append(fake.deliverArgsForCall, struct {
+		arg1 <-chan interface{}
+	}{arg1})
+	fake.recordInvocation("Deliver", []interface{}{arg1})
+	fake.deliverMutex.Unlock()
+	if fake.DeliverStub != nil {
+		fake.DeliverStub(arg1)
+	}
+}
+
+func (fake *FakeNotifier) DeliverCallCount() int {
+	fake.deliverMutex.RLock()
+	defer fake.deliverMutex.RUnlock()
+	return len(fake.deliverArgsForCall)
+}
+
+func (fake *FakeNotifier) DeliverCalls(stub func(<-chan interface{})) {
+	fake.deliverMutex.Lock()
+	defer fake.deliverMutex.Unlock()
+	fake.DeliverStub = stub
+}
+
+func (fake *FakeNotifier) DeliverArgsForCall(i int) <-chan interface{} {
+	fake.deliverMutex.RLock()
+	defer fake.deliverMutex.RUnlock()
+	argsForCall := fake.deliverArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeNotifier) Notify(arg1 events.Event) {
+	fake.notifyMutex.Lock()
+	fake.notifyArgsForCall = append(fake.notifyArgsForCall, struct {
+		arg1 events.Event
+	}{arg1})
+	fake.recordInvocation("Notify", []interface{}{arg1})
+	fake.notifyMutex.Unlock()
+	if fake.NotifyStub != nil {
+		fake.NotifyStub(arg1)
+	}
+}
+
+func (fake *FakeNotifier) NotifyCallCount() int {
+	fake.notifyMutex.RLock()
+	defer fake.notifyMutex.RUnlock()
+	return len(fake.notifyArgsForCall)
+}
+
+func (fake *FakeNotifier) NotifyCalls(stub func(events.Event)) {
+	fake.notifyMutex.Lock()
+	defer fake.notifyMutex.Unlock()
+	fake.NotifyStub = stub
+}
+
+func (fake *FakeNotifier) NotifyArgsForCall(i int) events.Event {
+	fake.notifyMutex.RLock()
+	defer fake.notifyMutex.RUnlock()
+	argsForCall := fake.notifyArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeNotifier) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.deliverMutex.RLock()
+	defer fake.deliverMutex.RUnlock()
+	fake.notifyMutex.RLock()
+	defer fake.notifyMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeNotifier) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ notifier.Notifier = new(FakeNotifier)
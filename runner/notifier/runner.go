@@ -0,0 +1,52 @@
+package notifier
+
+import (
+	"os"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/cloudfoundry-incubator/switchboard/events"
+)
+
+//go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 . Notifier
+type Notifier interface {
+	Notify(events.Event)
+	Deliver(<-chan interface{})
+}
+
+// Runner passes each event recorded in the log, such as the monitors
+// changing the active backend or traffic being disabled through the API, to
+// the notifier.
+type Runner struct {
+	notifier Notifier
+	eventLog *events.Log
+	logger   lager.Logger
+}
+
+func NewRunner(notifier Notifier, eventLog *events.Log, logger lager.Logger) Runner {
+	return Runner{
+		notifier: notifier,
+		eventLog: eventLog,
+		logger:   logger,
+	}
+}
+
+func (r Runner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	subscription, unsubscribe := r.eventLog.Subscribe()
+	defer unsubscribe()
+
+	shutdown := make(chan interface{})
+	r.notifier.Deliver(shutdown)
+
+	close(ready)
+
+	for {
+		select {
+		case event := <-subscription:
+			r.notifier.Notify(event)
+		case signal := <-signals:
+			r.logger.Info("Received signal", lager.Data{"signal": signal})
+			close(shutdown)
+			return nil
+		}
+	}
+}
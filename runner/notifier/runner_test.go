@@ -0,0 +1,58 @@
+package notifier_test
+
+import (
+	"os"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry-incubator/switchboard/events"
+	"github.com/cloudfoundry-incubator/switchboard/runner/notifier"
+	"github.com/cloudfoundry-incubator/switchboard/runner/notifier/notifierfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("Notifier Runner", func() {
+	var (
+		fakeNotifier *notifierfakes.FakeNotifier
+		eventLog     *events.Log
+		process      ifrit.Process
+	)
+
+	BeforeEach(func() {
+		logger := lagertest.NewTestLogger("Notifier Runner test")
+		fakeNotifier = new(notifierfakes.FakeNotifier)
+
+		var err error
+		eventLog, err = events.NewLog(10, "", logger)
+		Expect(err).NotTo(HaveOccurred())
+
+		process = ifrit.Invoke(notifier.NewRunner(fakeNotifier, eventLog, logger))
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Kill)
+		Eventually(process.Wait()).Should(Receive())
+	})
+
+	It("starts delivering", func() {
+		Expect(fakeNotifier.DeliverCallCount()).To(Equal(1))
+	})
+
+	It("passes each recorded event to the notifier", func() {
+		eventLog.ActiveBackendChanged("active", "backend-0", "backend-1", events.ReasonUnhealthy)
+		eventLog.TrafficChanged(false, "upgrading")
+
+		Eventually(fakeNotifier.NotifyCallCount).Should(Equal(2))
+		Expect(fakeNotifier.NotifyArgsForCall(0).Kind).To(Equal(events.KindActiveBackendChanged))
+		Expect(fakeNotifier.NotifyArgsForCall(1).Message).To(Equal("upgrading"))
+	})
+
+	It("stops delivering when signalled", func() {
+		shutdown := fakeNotifier.DeliverArgsForCall(0)
+
+		process.Signal(os.Kill)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+		Expect(shutdown).To(BeClosed())
+	})
+})
@@ -0,0 +1,54 @@
+package statsd
+
+import (
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+//go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 . Emitter
+type Emitter interface {
+	Emit() error
+}
+
+// Runner pushes metrics every interval, and once more when it is signalled
+// so that the last counts are not lost on shutdown.
+type Runner struct {
+	emitter  Emitter
+	interval time.Duration
+	logger   lager.Logger
+}
+
+func NewRunner(emitter Emitter, interval time.Duration, logger lager.Logger) Runner {
+	return Runner{
+		emitter:  emitter,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+func (r Runner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	close(ready)
+
+	for {
+		select {
+		case <-ticker.C:
+			r.emit()
+		case signal := <-signals:
+			r.logger.Info("Received signal", lager.Data{"signal": signal})
+			r.emit()
+			return nil
+		}
+	}
+}
+
+func (r Runner) emit() {
+	err := r.emitter.Emit()
+	if err != nil {
+		r.logger.Error("Failed to push metrics to StatsD", err)
+	}
+}
@@ -0,0 +1,68 @@
+package statsd_test
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry-incubator/switchboard/runner/statsd"
+	"github.com/cloudfoundry-incubator/switchboard/runner/statsd/statsdfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("StatsD Runner", func() {
+	var (
+		emitter  *statsdfakes.FakeEmitter
+		logger   *lagertest.TestLogger
+		interval time.Duration
+		process  ifrit.Process
+	)
+
+	BeforeEach(func() {
+		emitter = new(statsdfakes.FakeEmitter)
+		logger = lagertest.NewTestLogger("StatsD Runner test")
+		interval = 10 * time.Millisecond
+	})
+
+	JustBeforeEach(func() {
+		process = ifrit.Invoke(statsd.NewRunner(emitter, interval, logger))
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Kill)
+		Eventually(process.Wait()).Should(Receive())
+	})
+
+	It("pushes metrics every interval", func() {
+		Eventually(emitter.EmitCallCount).Should(BeNumerically(">=", 3))
+	})
+
+	Context("when signalled before the interval elapses", func() {
+		BeforeEach(func() {
+			interval = time.Hour
+		})
+
+		It("pushes once more and exits", func() {
+			Expect(emitter.EmitCallCount()).To(Equal(0))
+
+			process.Signal(os.Kill)
+			Eventually(process.Wait()).Should(Receive(BeNil()))
+			Expect(emitter.EmitCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("when a push fails", func() {
+		BeforeEach(func() {
+			emitter.EmitReturns(errors.New("connection refused"))
+		})
+
+		It("logs the error and keeps pushing", func() {
+			Eventually(emitter.EmitCallCount).Should(BeNumerically(">=", 2))
+			Eventually(logger).Should(gbytes.Say("Failed to push metrics to StatsD"))
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package statsd_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestStatsd(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Switchboard StatsD Runner Suite")
+}
@@ -0,0 +1,101 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package statsdfakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/switchboard/runner/statsd"
+)
+
+type FakeEmitter struct {
+	EmitStub        func() error
+	emitMutex       sync.RWMutex
+	emitArgsForCall []struct {
+	}
+	emitReturns struct {
+		result1 error
+	}
+	emitReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeEmitter) Emit() error {
+	fake.emitMutex.Lock()
+	ret, specificReturn := fake.emitReturnsOnCall[len(fake.emitArgsForCall)]
+	fake.emitArgsForCall = append(fake.emitArgsForCall, struct {
+	}{})
+	fake.recordInvocation("Emit", []interface{}{})
+	fake.emitMutex.Unlock()
+	if fake.EmitStub != nil {
+		return fake.EmitStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.emitReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeEmitter) EmitCallCount() int {
+	fake.emitMutex.RLock()
+	defer fake.emitMutex.RUnlock()
+	return len(fake.emitArgsForCall)
+}
+
+func (fake *FakeEmitter) EmitCalls(stub func() error) {
+	fake.emitMutex.Lock()
+	defer fake.emitMutex.Unlock()
+	fake.EmitStub = stub
+}
+
+func (fake *FakeEmitter) EmitReturns(result1 error) {
+	fake.emitMutex.Lock()
+	defer fake.emitMutex.Unlock()
+	fake.EmitStub = nil
+	fake.emitReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeEmitter) EmitReturnsOnCall(i int, result1 error) {
+	fake.emitMutex.Lock()
+	defer fake.emitMutex.Unlock()
+	fake.EmitStub = nil
+	if fake.emitReturnsOnCall == nil {
+		fake.emitReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.emitReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeEmitter) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.emitMutex.RLock()
+	defer fake.emitMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeEmitter) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ statsd.Emitter = new(FakeEmitter)
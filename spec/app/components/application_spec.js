@@ -1,8 +1,12 @@
 require('../spec_helper');
 
 describe('Application', function() {
-  var Application, Backends, request, backends, subject;
+  var Application, Backends, request, backends, subject, eventSource, originalEventSource;
   beforeEach(function() {
+    eventSource = jasmine.createSpyObj('eventSource', ['addEventListener', 'close']);
+    originalEventSource = window.EventSource;
+    window.EventSource = jasmine.createSpy('EventSource').and.returnValue(eventSource);
+
     backends = [
       {
         "host": "localhost",
@@ -38,6 +42,7 @@ describe('Application', function() {
 
   afterEach(function() {
     React.unmountComponentAtNode(root);
+    window.EventSource = originalEventSource;
   });
 
   it('makes an ajax request', function() {
@@ -45,6 +50,37 @@ describe('Application', function() {
     expect(request.url).toEqual('/v0/backends');
   });
 
+  it('opens the event stream', function() {
+    expect(window.EventSource).toHaveBeenCalledWith('/v0/events/stream');
+    expect(eventSource.addEventListener).toHaveBeenCalledWith('activeBackendChanged', jasmine.any(Function));
+  });
+
+  describe('when an event is pushed', function() {
+    beforeEach(function() {
+      jasmine.Ajax.requests.reset();
+      var listener = eventSource.addEventListener.calls.all().find(c => c.args[0] === 'backendUnhealthy').args[1];
+      listener({data: JSON.stringify({kind: 'backendUnhealthy', backend: 'backend - 1', time: '2020-01-01T00:00:00Z'})});
+    });
+
+    it('adds it to the events', function() {
+      expect(subject.state.events.map(e => e.kind)).toEqual(['backendUnhealthy']);
+    });
+
+    it('refreshes the backends', function() {
+      expect(jasmine.Ajax.requests.mostRecent().url).toEqual('/v0/backends');
+    });
+  });
+
+  it('closes the event stream when unmounted', function() {
+    React.unmountComponentAtNode(root);
+    expect(eventSource.close).toHaveBeenCalled();
+  });
+
+  it('requests the events', function() {
+    var urls = jasmine.Ajax.requests.filter(/\/v0\/events/).map(r => r.url);
+    expect(urls).toEqual(['/v0/events']);
+  });
+
 
   describe('when some of the backends are unhealthy', function() {
     beforeEach(function() {
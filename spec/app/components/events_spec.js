@@ -0,0 +1,42 @@
+require('../spec_helper');
+
+describe('Events', function() {
+  var events;
+  beforeEach(function() {
+    var Events = require('../../../app/components/events');
+    events = [
+      {
+        "time": "2020-01-01T00:00:00Z",
+        "kind": "backendUnhealthy",
+        "backend": "backend - 1"
+      },
+      {
+        "time": "2020-01-01T00:00:01Z",
+        "kind": "activeBackendChanged",
+        "listener": "active",
+        "backend": "backend - 2",
+        "previousBackend": "backend - 1",
+        "reason": "unhealthy"
+      }
+    ];
+    React.render(<Events events={events}/>, root);
+  });
+
+  afterEach(function() {
+    React.unmountComponentAtNode(root);
+  });
+
+  it('renders the events newest first', function() {
+    expect($('li .media-body').map(function() {
+      return $(this).contents().last().text();
+    }).toArray()).toEqual([
+      'active listener moved from backend - 1 to backend - 2 (unhealthy)',
+      'backend - 1 became unhealthy'
+    ]);
+  });
+
+  it('marks events that need attention', function() {
+    expect($('li:eq(1) .fa-exclamation-circle')).toExist();
+    expect($('li:eq(0) .fa-exclamation-circle')).not.toExist();
+  });
+});
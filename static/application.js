@@ -48,6 +48,7 @@
 	
 	__webpack_require__(1);
 	var Backends = __webpack_require__(5);
+	var Events = __webpack_require__(189);
 	var React = __webpack_require__(7);
 	var Layout = __webpack_require__(183);
 	var request = __webpack_require__(185);
@@ -62,33 +63,76 @@
 	  displayName: "Application",
 	
 	  getInitialState: function getInitialState() {
-	    return { backends: [] };
+	    return { backends: [], events: [] };
 	  },
 	
 	  statics: {
-	    POLL_INTERVAL: 1 * 1000
+	    // changes in health and active backend are pushed over the event
+	    // stream; polling only keeps the session counts current
+	    POLL_INTERVAL: 10 * 1000,
+	    FALLBACK_POLL_INTERVAL: 1 * 1000,
+	    MAX_EVENTS: 1000,
+	    EVENT_KINDS: ["backendHealthy", "backendUnhealthy", "activeBackendChanged", "trafficEnabled", "trafficDisabled", "sessionsSevered"]
 	  },
 	
 	  componentDidMount: function componentDidMount() {
-	    this.pollBackends();
+	    this.updateEvents();
+	    if (typeof EventSource === "undefined") {
+	      this.pollBackends(Application.FALLBACK_POLL_INTERVAL);
+	      return;
+	    }
+	    this.streamEvents();
+	    this.pollBackends(Application.POLL_INTERVAL);
+	  },
+	
+	  componentWillUnmount: function componentWillUnmount() {
+	    if (this.eventSource) this.eventSource.close();
+	  },
+	
+	  streamEvents: function streamEvents() {
+	    this.eventSource = new EventSource("/v0/events/stream");
+	    Application.EVENT_KINDS.forEach(function (kind) {
+	      this.eventSource.addEventListener(kind, this.receiveEvent);
+	    }, this);
+	  },
+	
+	  receiveEvent: function receiveEvent(_ref) {
+	    var data = _ref.data;
+	
+	    var event = JSON.parse(data);
+	    this.setState({ events: this.state.events.concat([event]).slice(-Application.MAX_EVENTS) });
+	    if (event.kind !== "trafficEnabled" && event.kind !== "trafficDisabled") {
+	      this.updateBackends();
+	    }
 	  },
 	
 	  updateBackends: function updateBackends() {
-	    request.get("/v0/backends").accept("json").end((function (err, _ref) {
-	      var backends = _ref.body;
+	    request.get("/v0/backends").accept("json").end((function (err, _ref2) {
+	      var backends = _ref2.body;
 	
 	      if (err) return;
 	      this.setState({ backends: backends });
 	    }).bind(this));
 	  },
 	
-	  pollBackends: function pollBackends() {
+	  updateEvents: function updateEvents() {
+	    request.get("/v0/events").accept("json").end((function (err, _ref3) {
+	      var events = _ref3.body;
+	
+	      if (err) return;
+	      this.setState({ events: events });
+	    }).bind(this));
+	  },
+	
+	  pollBackends: function pollBackends(interval) {
 	    this.updateBackends();
-	    setCorrectingInterval(this.updateBackends, Application.POLL_INTERVAL);
+	    setCorrectingInterval(this.updateBackends, interval);
 	  },
 	
 	  render: function render() {
-	    var backends = this.state.backends;
+	    var _state = this.state;
+	    var backends = _state.backends;
+	    var events = _state.events;
 	
 	    var healthyCount = backends && backends.reduce(function (memo, b) {
 	      return memo + (b.healthy ? 1 : 0);
@@ -160,6 +204,15 @@
 	              { className: "col-sm-24 mtl" },
 	              React.createElement(Backends, { backends: backends })
 	            )
+	          ),
+	          React.createElement(
+	            "div",
+	            { className: "row mtxl" },
+	            React.createElement(
+	              "div",
+	              { className: "col-sm-24 mtl" },
+	              React.createElement(Events, { events: events })
+	            )
 	          )
 	        )
 	      )
@@ -27525,6 +27578,102 @@
 	  };
 	}));
 
+/***/ }),
+/* 189 */
+/***/ (function(module, exports, __webpack_require__) {
+
+	"use strict";
+	
+	var React = __webpack_require__(7);
+	
+	var cx = React.addons.classSet;
+	var types = React.PropTypes;
+	
+	function describe(event) {
+	  var kind = event.kind;
+	  var listener = event.listener;
+	  var backend = event.backend;
+	  var previousBackend = event.previousBackend;
+	  var reason = event.reason;
+	  var message = event.message;
+	  var sessions = event.sessions;
+	
+	  switch (kind) {
+	    case "backendHealthy":
+	      return "" + backend + " became healthy";
+	    case "backendUnhealthy":
+	      return "" + backend + " became unhealthy";
+	    case "activeBackendChanged":
+	      var from = previousBackend || "none";
+	      var to = backend || "none";
+	      return "" + listener + " listener moved from " + from + " to " + to + " (" + reason + ")";
+	    case "trafficEnabled":
+	      return "Traffic enabled" + (message ? ": " + message : "");
+	    case "trafficDisabled":
+	      return "Traffic disabled" + (message ? ": " + message : "");
+	    case "sessionsSevered":
+	      return "Severed " + sessions + " " + (listener ? "" + listener + " " : "") + "sessions to " + backend + " (" + reason + ")";
+	    default:
+	      return kind;
+	  }
+	}
+	
+	var Events = React.createClass({
+	  displayName: "Events",
+	
+	  propTypes: {
+	    events: types.array.isRequired
+	  },
+	
+	  renderEvents: function renderEvents() {
+	    var events = this.props.events;
+	
+	    return events.slice().reverse().map(function (event, i) {
+	      var warning = ["backendUnhealthy", "trafficDisabled", "sessionsSevered"].indexOf(event.kind) !== -1;
+	      return React.createElement(
+	        "li",
+	        { className: "media mvm", key: i },
+	        React.createElement(
+	          "div",
+	          { className: "media-left" },
+	          React.createElement("i", { className: cx({ "fa fa-fw": true, "fa-circle type-brand-4": !warning, "fa-exclamation-circle type-error-2": warning }) })
+	        ),
+	        React.createElement(
+	          "div",
+	          { className: "media-body" },
+	          React.createElement(
+	            "span",
+	            { className: "type-neutral-4 mrm" },
+	            new Date(event.time).toLocaleString()
+	          ),
+	          describe(event)
+	        )
+	      );
+	    });
+	  },
+	
+	  render: function render() {
+	    return React.createElement(
+	      "div",
+	      null,
+	      React.createElement(
+	        "h5",
+	        { className: "em-max mlm" },
+	        "Events"
+	      ),
+	      React.createElement(
+	        "ul",
+	        { className: "list-unstyled timeline mlm" },
+	        this.renderEvents()
+	      )
+	    );
+	  }
+	});
+	
+	Events.describe = describe;
+	
+	module.exports = Events;
+
 /***/ })
 /******/ ]);
 //# sourceMappingURL=application.js.map
\ No newline at end of file